@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/services"
+)
+
+func accountCommand() *command {
+	return &command{
+		name:    "account",
+		summary: "open accounts and freeze or unfreeze them",
+		commands: []*command{
+			accountCreateCommand(),
+			accountStatusCommand("freeze", "freeze an account so that no money can leave it", models.AccountStatusFrozen),
+			accountStatusCommand("unfreeze", "make a frozen account active again", models.AccountStatusActive),
+		},
+	}
+}
+
+func accountCreateCommand() *command {
+	var userID, reason string
+	var req services.OpenAccountRequest
+	return &command{
+		name:    "create",
+		summary: "open an empty account for a registered user",
+		setFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&userID, "user", "", "owner user ID")
+			fs.StringVar(&req.Currency, "currency", "", "ISO currency code")
+			fs.StringVar((*string)(&req.Type), "type", string(models.AccountTypeCurrent), "current or savings")
+			fs.StringVar(&req.Name, "name", "", "optional account name")
+			fs.StringVar(&reason, "reason", "", "why the account is opened for the user, kept in the audit log")
+		},
+		required: []string{"user", "currency", "reason"},
+		run: func(app *app) error {
+			app.actor.OverrideReason = reason
+			account, err := services.NewAccountService(app.db).OpenAccount(app.actor, userID, req)
+			if err != nil {
+				return err
+			}
+			return app.printJSON(account)
+		},
+	}
+}
+
+func accountStatusCommand(name, summary string, status models.AccountStatus) *command {
+	var accountID uint
+	var reason string
+	return &command{
+		name:    name,
+		summary: summary,
+		setFlags: func(fs *flag.FlagSet) {
+			fs.UintVar(&accountID, "account", 0, "account ID")
+			fs.StringVar(&reason, "reason", "", "why, kept in the audit log")
+		},
+		required: []string{"account", "reason"},
+		run: func(app *app) error {
+			account, err := services.NewAccountService(app.db).ChangeStatus(app.actor, accountID, services.ChangeStatusRequest{
+				Status: status,
+				Reason: reason,
+			})
+			if err != nil {
+				return err
+			}
+			return app.printJSON(account)
+		},
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/services"
+
+	"gorm.io/gorm"
+)
+
+func adminCommand() *command {
+	var userID, password, name string
+	return &command{
+		name:    "admin",
+		summary: "create administrators",
+		commands: []*command{{
+			name:    "create",
+			summary: "register a new user with the admin role; an existing user ID is refused",
+			setFlags: func(fs *flag.FlagSet) {
+				fs.StringVar(&userID, "user", "", "user ID of the new administrator")
+				fs.StringVar(&password, "password", "", "initial password")
+				fs.StringVar(&name, "name", "", "optional display name")
+			},
+			required: []string{"user", "password"},
+			run: func(app *app) error {
+				user, err := createAdmin(app, userID, password, name)
+				if err != nil {
+					return err
+				}
+				return app.printJSON(user)
+			},
+		}},
+	}
+}
+
+// createAdmin registers userID as an administrator. Existing users are never
+// promoted here: whoever registered the ID first would otherwise become
+// admin. An admin grants roles to existing users through the API.
+func createAdmin(app *app, userID, password, name string) (*models.User, error) {
+	if models.IsReservedUserID(userID) {
+		return nil, services.ErrReservedUserID
+	}
+	hash, err := newPasswordService(app).Hash(userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{ID: userID, PasswordHash: hash, Role: models.RoleAdmin, DisplayName: name}
+	audit := services.NewAuditService(app.db)
+	err = app.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ?", userID).First(&models.User{}).Error
+		if err == nil {
+			return fmt.Errorf("user %s already exists; grant it the admin role through the API", userID)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit.Record(tx, app.actor, services.AuditRecord{
+			Action:     models.AuditAuthRegister,
+			EntityType: "user",
+			EntityID:   user.ID,
+			After:      user,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"bank-ledger-core/services"
+)
+
+var errUnbalanced = errors.New("ledger does not reconcile")
+
+func adjustCommand() *command {
+	var req services.AdjustmentRequest
+	return &command{
+		name:    "adjust",
+		summary: "post a manual balance adjustment against the issuance account",
+		setFlags: func(fs *flag.FlagSet) {
+			fs.UintVar(&req.AccountID, "account", 0, "account ID")
+			fs.StringVar(&req.Amount, "amount", "", "signed amount: positive credits the account, negative debits it")
+			fs.StringVar(&req.Reason, "reason", "", "why, kept as the entry description")
+		},
+		required: []string{"account", "amount", "reason"},
+		run: func(app *app) error {
+			entry, err := services.NewJournalService(app.db).Adjust(app.actor, req)
+			if err != nil {
+				return err
+			}
+			return app.printJSON(entry)
+		},
+	}
+}
+
+func reconcileCommand() *command {
+	return &command{
+		name:    "reconcile",
+		summary: "check that the journal balances and matches every cached balance",
+		run: func(app *app) error {
+			check, err := services.NewJournalService(app.db).Check()
+			if err != nil {
+				return err
+			}
+			for _, total := range check.Currencies {
+				state := "ok"
+				if !total.Balanced {
+					state = "UNBALANCED"
+				}
+				fmt.Fprintf(app.stdout, "%s  debits %s  credits %s  %s\n", total.Currency, total.Debits, total.Credits, state)
+			}
+			for _, mismatch := range check.Mismatches {
+				fmt.Fprintf(app.stdout, "account %d (%s): cached %s, journal %s %s\n",
+					mismatch.AccountID, mismatch.UserID, mismatch.Cached, mismatch.Projected, mismatch.Currency)
+			}
+			if !check.Balanced {
+				return errUnbalanced
+			}
+			fmt.Fprintln(app.stdout, "ledger reconciles")
+			return nil
+		},
+	}
+}
+
+func closeCommand() *command {
+	var dateFlag string
+	return &command{
+		name:    "close",
+		summary: "close every day through --date, snapshotting account balances",
+		setFlags: func(fs *flag.FlagSet) {
+			fs.StringVar(&dateFlag, "date", "", "last day to close, YYYY-MM-DD (default: yesterday)")
+		},
+		run: func(app *app) error {
+			date := time.Now().UTC().AddDate(0, 0, -1)
+			if dateFlag != "" {
+				parsed, err := services.ParseBusinessDate(dateFlag)
+				if err != nil {
+					return err
+				}
+				date = parsed
+			}
+
+			closes, err := services.NewCloseService(app.db, services.SystemClock).Close(app.actor, date)
+			if err != nil {
+				return err
+			}
+			for _, closed := range closes {
+				fmt.Fprintf(app.stdout, "closed %s: %d accounts\n", closed.Date.UTC().Format(services.BusinessDateLayout), closed.Accounts)
+			}
+			return nil
+		},
+	}
+}
+
+func systemCommand() *command {
+	var reason string
+	return &command{
+		name:    "system",
+		summary: "manage the marketplace system account",
+		commands: []*command{{
+			name:    "rotate",
+			summary: "move the system account balance to a new account and close the old one",
+			setFlags: func(fs *flag.FlagSet) {
+				fs.StringVar(&reason, "reason", "", "why, kept in the audit log")
+			},
+			required: []string{"reason"},
+			run: func(app *app) error {
+				rotation, err := services.NewAccountService(app.db).RotateSystemAccount(app.actor, reason)
+				if err != nil {
+					return err
+				}
+				return app.printJSON(rotation)
+			},
+		}},
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := execute(rootCommand(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(dir, "ledger.db"))
+
+	if code, _, stderr := run(t, "seed", "--users", "2", "--balance", "500.00", "--products", "1"); code != 0 {
+		t.Fatalf("seed exited %d: %s", code, stderr)
+	}
+	if code, stdout, _ := run(t, "seed", "--users", "2", "--products", "1"); code != 0 || !strings.Contains(stdout, "demo01 exists") {
+		t.Fatalf("second seed exited %d: %s", code, stdout)
+	}
+
+	// The first admin is created, never promoted from an existing user
+	if code, stdout, stderr := run(t, "admin", "create", "--user", "root", "--password", "correct horse battery 42"); code != 0 || !strings.Contains(stdout, `"admin"`) {
+		t.Fatalf("admin create exited %d: %s%s", code, stdout, stderr)
+	}
+	if code, _, stderr := run(t, "admin", "create", "--user", "demo01", "--password", "correct horse battery 42"); code != 1 || !strings.Contains(stderr, "already exists") {
+		t.Fatalf("admin create for an existing user exited %d: %s", code, stderr)
+	}
+
+	code, stdout, stderr := run(t, "account", "create", "--user", "demo01", "--currency", "usd", "--type", "savings", "--reason", "customer request")
+	if code != 0 {
+		t.Fatalf("account create exited %d: %s", code, stderr)
+	}
+	var account struct {
+		ID       uint   `json:"id"`
+		Currency string `json:"currency"`
+		Status   string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &account); err != nil || account.Currency != "USD" {
+		t.Fatalf("account create printed %q (%v)", stdout, err)
+	}
+
+	if code, stdout, _ := run(t, "account", "freeze", "--account", "2", "--reason", "chargeback"); code != 0 || !strings.Contains(stdout, `"frozen"`) {
+		t.Fatalf("freeze exited %d: %s", code, stdout)
+	}
+	if code, _, stderr := run(t, "adjust", "--account", "2", "--amount", "-1.00", "--reason", "fee"); code != 1 || !strings.Contains(stderr, "frozen") {
+		t.Fatalf("debit adjustment of a frozen account exited %d: %s", code, stderr)
+	}
+	if code, _, stderr := run(t, "account", "unfreeze", "--account", "2", "--reason", "resolved"); code != 0 {
+		t.Fatalf("unfreeze exited %d: %s", code, stderr)
+	}
+	if code, _, stderr := run(t, "adjust", "--account", "2", "--amount", "-1.00", "--reason", "fee"); code != 0 {
+		t.Fatalf("adjust exited %d: %s", code, stderr)
+	}
+
+	if code, stdout, _ := run(t, "reconcile"); code != 0 || !strings.Contains(stdout, "ledger reconciles") {
+		t.Fatalf("reconcile exited %d: %s", code, stdout)
+	}
+
+	if code, stdout, stderr := run(t, "close"); code != 0 || !strings.Contains(stdout, "closed ") {
+		t.Fatalf("close exited %d: %s%s", code, stdout, stderr)
+	}
+	if code, _, stderr := run(t, "close"); code != 1 || !strings.Contains(stderr, "already closed") {
+		t.Fatalf("second close exited %d: %s", code, stderr)
+	}
+
+	out := filepath.Join(dir, "statement.csv")
+	if code, _, stderr := run(t, "statement", "export", "--account", "2", "--from", "2000-01-01", "--to", "2100-01-01", "--out", out, "--reason", "audit request"); code != 0 {
+		t.Fatalf("statement export exited %d: %s", code, stderr)
+	}
+
+	if code, stdout, stderr := run(t, "system", "rotate", "--reason", "test"); code != 0 || !strings.Contains(stdout, `"previous"`) {
+		t.Fatalf("rotate exited %d: %s%s", code, stdout, stderr)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if code, _, stderr := run(t); code != 2 || !strings.Contains(stderr, "reconcile") {
+		t.Fatalf("no arguments exited %d: %s", code, stderr)
+	}
+	if code, _, stderr := run(t, "account", "delete"); code != 2 || !strings.Contains(stderr, `unknown command "ledgerctl account delete"`) {
+		t.Fatalf("unknown command exited %d: %s", code, stderr)
+	}
+	if code, _, stderr := run(t, "account", "freeze", "--account", "1"); code != 2 || !strings.Contains(stderr, "--reason is required") {
+		t.Fatalf("missing flag exited %d: %s", code, stderr)
+	}
+}
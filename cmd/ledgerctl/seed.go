@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"bank-ledger-core/config"
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/notify"
+	"bank-ledger-core/services"
+
+	"gorm.io/gorm"
+)
+
+func seedCommand() *command {
+	var users, products int
+	var prefix, currency, balance, password string
+	return &command{
+		name:    "seed",
+		summary: "create funded test users and products; existing ones are left alone",
+		setFlags: func(fs *flag.FlagSet) {
+			fs.IntVar(&users, "users", 3, "number of users to create")
+			fs.StringVar(&prefix, "prefix", "demo", "user IDs are <prefix>01, <prefix>02, ...")
+			fs.StringVar(&currency, "currency", "UZS", "currency of the users' current accounts")
+			fs.StringVar(&balance, "balance", "1000000.00", "opening balance of each account")
+			fs.StringVar(&password, "password", "demo1234", "password of every seeded user")
+			fs.IntVar(&products, "products", 3, "number of products to create")
+		},
+		run: func(app *app) error {
+			currency = strings.ToUpper(currency)
+			opening, err := money.ParsePositive(balance, currency)
+			if err != nil {
+				return fmt.Errorf("invalid --balance: %w", err)
+			}
+
+			passwords := newPasswordService(app)
+			for i := 1; i <= users; i++ {
+				userID := fmt.Sprintf("%s%02d", prefix, i)
+				account, err := seedUser(app, passwords, userID, password, currency, opening)
+				if err != nil {
+					return fmt.Errorf("failed to seed %s: %w", userID, err)
+				}
+				if account == nil {
+					fmt.Fprintf(app.stdout, "user %s exists, skipped\n", userID)
+					continue
+				}
+				fmt.Fprintf(app.stdout, "user %s: account %d with %s %s\n", userID, account.ID, opening, currency)
+			}
+
+			for i := 1; i <= products; i++ {
+				product, err := seedProduct(app, i)
+				if err != nil {
+					return fmt.Errorf("failed to seed product %d: %w", i, err)
+				}
+				if product != nil {
+					fmt.Fprintf(app.stdout, "product %d: %s for %s\n", product.ID, product.Name, product.Price)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// newPasswordService hashes passwords under the configured policy, as
+// registration does.
+func newPasswordService(app *app) *services.PasswordService {
+	passwordConfig := config.GetPasswordConfig()
+	return services.NewPasswordService(app.db, services.SystemClock, notify.LogNotifier{}, services.PasswordPolicy{
+		MinLength:        passwordConfig.MinLength,
+		RequireMixedCase: passwordConfig.RequireMixedCase,
+		RequireDigit:     passwordConfig.RequireDigit,
+		RequireSymbol:    passwordConfig.RequireSymbol,
+	}, passwordConfig.ResetTTL)
+}
+
+// seedUser registers userID with a funded current account the way sign-up
+// does. It returns nil when the user already exists.
+func seedUser(app *app, passwords *services.PasswordService, userID, password, currency string, opening money.Amount) (*models.Account, error) {
+	var existing models.User
+	err := app.db.Where("id = ?", userID).First(&existing).Error
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hash, err := passwords.Hash(userID, password)
+	if err != nil {
+		return nil, err
+	}
+	user := models.User{ID: userID, PasswordHash: hash, DisplayName: "Seeded user " + userID}
+	account := models.Account{
+		UserID:   userID,
+		Type:     models.AccountTypeCurrent,
+		Currency: currency,
+		Balance:  money.FromMinor(0, currency),
+	}
+
+	accounts := services.NewAccountService(app.db)
+	journal := services.NewJournalService(app.db)
+	audit := services.NewAuditService(app.db)
+	err = app.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := accounts.Create(tx, &account); err != nil {
+			return err
+		}
+		if _, err := journal.Fund(tx, models.EntryTypeOpeningBalance, &account, opening, "Seed balance"); err != nil {
+			return err
+		}
+		return audit.Record(tx, app.actor, services.AuditRecord{
+			Action:     models.AuditAuthRegister,
+			EntityType: "user",
+			EntityID:   user.ID,
+			After:      map[string]interface{}{"user": user, "account": account},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// seedProduct creates "Demo product n" unless a product of that name exists.
+func seedProduct(app *app, n int) (*models.Product, error) {
+	product := models.Product{
+		Name:        fmt.Sprintf("Demo product %d", n),
+		Description: "Created by ledgerctl seed",
+		Price:       money.New(int64(n)*1000000, 2),
+		Stock:       100,
+	}
+	var count int64
+	if err := app.db.Model(&models.Product{}).Where("name = ?", product.Name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, nil
+	}
+
+	err := app.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return services.NewAuditService(app.db).Record(tx, app.actor, services.AuditRecord{
+			Action:     models.AuditProductCreate,
+			EntityType: "product",
+			EntityID:   product.ID,
+			After:      product,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
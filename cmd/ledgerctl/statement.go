@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"bank-ledger-core/services"
+	"bank-ledger-core/statement"
+)
+
+func statementCommand() *command {
+	var accountID uint
+	var fromFlag, toFlag, formatName, out, reason string
+	return &command{
+		name:    "statement",
+		summary: "export account statements",
+		commands: []*command{{
+			name:    "export",
+			summary: "write an account statement for [from, to) as CSV, OFX or camt.053",
+			setFlags: func(fs *flag.FlagSet) {
+				fs.UintVar(&accountID, "account", 0, "account ID")
+				fs.StringVar(&fromFlag, "from", "", "start date, YYYY-MM-DD or RFC 3339 (default: start of this month)")
+				fs.StringVar(&toFlag, "to", "", "end date, exclusive (default: now)")
+				fs.StringVar(&formatName, "format", statement.CSV.Name, "csv, ofx or camt053")
+				fs.StringVar(&out, "out", "", "file to write (default: stdout)")
+				fs.StringVar(&reason, "reason", "", "why the statement is exported, kept in the audit log")
+			},
+			required: []string{"account", "reason"},
+			run: func(app *app) error {
+				format, ok := statement.Negotiate(formatName, "")
+				if !ok {
+					return fmt.Errorf("unsupported format %q, expected csv, ofx or camt053", formatName)
+				}
+
+				now := time.Now().UTC()
+				from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+				to := now
+				for name, raw := range map[string]string{"from": fromFlag, "to": toFlag} {
+					if raw == "" {
+						continue
+					}
+					parsed, err := parseDate(raw)
+					if err != nil {
+						return fmt.Errorf("invalid --%s: %w", name, err)
+					}
+					if name == "from" {
+						from = parsed
+					} else {
+						to = parsed
+					}
+				}
+				if !from.Before(to) {
+					return fmt.Errorf("--from must be before --to")
+				}
+
+				app.actor.OverrideReason = reason
+				history := services.NewHistoryService(app.db, services.NewJournalService(app.db))
+				stmt, err := history.Statement(app.actor, accountID, from, to)
+				if err != nil {
+					return err
+				}
+
+				if out == "" {
+					return format.Write(app.stdout, stmt)
+				}
+				file, err := os.Create(out)
+				if err != nil {
+					return err
+				}
+				if err := format.Write(file, stmt); err != nil {
+					file.Close()
+					return err
+				}
+				if err := file.Close(); err != nil {
+					return err
+				}
+				fmt.Fprintf(app.stdout, "wrote %d lines to %s\n", len(stmt.Lines), out)
+				return nil
+			},
+		}},
+	}
+}
+
+func parseDate(raw string) (time.Time, error) {
+	if parsed, err := time.Parse("2006-01-02", raw); err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
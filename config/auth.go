@@ -0,0 +1,47 @@
+package config
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"bank-ledger-core/money"
+)
+
+type AuthConfig struct {
+	TOTPIssuer       string
+	StepUpThresholds map[string]money.Amount
+	StepUpWindow     time.Duration
+}
+
+// GetAuthConfig reads TOTP_ISSUER, the name authenticator apps show;
+// STEP_UP_THRESHOLDS, per-currency amounts such as
+// "UZS:10000000,USD:1000" above which transfers need a fresh two-factor
+// check; and STEP_UP_WINDOW, how long such a check stays fresh.
+func GetAuthConfig() *AuthConfig {
+	thresholds := map[string]money.Amount{}
+	for _, entry := range strings.Split(getEnv("STEP_UP_THRESHOLDS", ""), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		currency, value, _ := strings.Cut(entry, ":")
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		amount, err := money.ParsePositive(strings.TrimSpace(value), currency)
+		if err != nil {
+			log.Printf("Ignoring step-up threshold %q: %v", entry, err)
+			continue
+		}
+		thresholds[currency] = amount
+	}
+
+	window, err := time.ParseDuration(getEnv("STEP_UP_WINDOW", "5m"))
+	if err != nil || window <= 0 {
+		window = 5 * time.Minute
+	}
+
+	return &AuthConfig{
+		TOTPIssuer:       getEnv("TOTP_ISSUER", "Bank Ledger"),
+		StepUpThresholds: thresholds,
+		StepUpWindow:     window,
+	}
+}
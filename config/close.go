@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+type CloseConfig struct {
+	Interval time.Duration
+}
+
+// GetCloseConfig reads CLOSE_INTERVAL, how often the server checks for
+// finished days to close; 0 turns the automatic close off.
+func GetCloseConfig() *CloseConfig {
+	interval, err := time.ParseDuration(getEnv("CLOSE_INTERVAL", "1h"))
+	if err != nil || interval < 0 {
+		interval = time.Hour
+	}
+
+	return &CloseConfig{
+		Interval: interval,
+	}
+}
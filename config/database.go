@@ -2,12 +2,15 @@ package config
 
 import (
 	"fmt"
+	"log"
 	"os"
 
-	"gorm.io/driver/postgres"
+	"bank-ledger-core/migrations"
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
 	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"bank-ledger-core/models"
 )
 
 type DatabaseConfig struct {
@@ -31,7 +34,7 @@ func GetDatabaseConfig() *DatabaseConfig {
 			SSLMode:  "",
 		}
 	}
-	
+
 	// Fallback to individual env vars (for development)
 	return &DatabaseConfig{
 		Host:     getEnv("DB_HOST", "localhost"),
@@ -50,7 +53,29 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// InitDatabase connects, brings the schema up to date (or, with
+// DB_MIGRATE_ON_START=false, refuses to start while migrations are pending)
+// and makes sure the system account exists.
 func InitDatabase(driver string, config *DatabaseConfig) (*gorm.DB, error) {
+	db, err := OpenDatabase(driver, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrateOnStart(db, driver); err != nil {
+		return nil, err
+	}
+
+	err = createSystemAccount(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create system account: %w", err)
+	}
+
+	return db, nil
+}
+
+// OpenDatabase only connects; the migrate command uses it directly.
+func OpenDatabase(driver string, config *DatabaseConfig) (*gorm.DB, error) {
 	var db *gorm.DB
 	var err error
 
@@ -77,55 +102,65 @@ func InitDatabase(driver string, config *DatabaseConfig) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Configure SQLite connection pool
-	sqlDB, err := db.DB()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	// SQLite allows one writer at a time, so it gets a single connection;
+	// Postgres keeps the default pool and relies on row locks
+	if driver == "sqlite" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
 	}
-	sqlDB.SetMaxOpenConns(1)
-	sqlDB.SetMaxIdleConns(1)
-
-	// Auto-migrate all models with error handling for SQLite
-	err = db.AutoMigrate(
-		&models.Session{},
-		&models.Account{},
-		&models.Product{},
-		&models.Order{},
-		&models.Transfer{},
-	)
+
+	return db, nil
+}
+
+// migrateOnStart applies pending migrations unless DB_MIGRATE_ON_START is
+// false, in which case they must be applied with the migrate command first.
+func migrateOnStart(db *gorm.DB, driver string) error {
+	migrator, err := migrations.New(db, driver)
 	if err != nil {
-		// For SQLite, this might be a migration conflict
-		if driver == "sqlite" {
-			return nil, fmt.Errorf("SQLite migration failed: %w. This might be due to schema conflicts. Try removing the database file and restarting.", err)
+		return err
+	}
+	if !getBool("DB_MIGRATE_ON_START", true) {
+		pending, err := migrator.Pending()
+		if err != nil {
+			return fmt.Errorf("failed to check migrations: %w", err)
 		}
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, starting with %d_%s; run the migrate up command first",
+				len(pending), pending[0].Version, pending[0].Name)
+		}
+		return nil
 	}
-
-	err = createSystemAccount(db)
+	applied, err := migrator.Up(0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create system account: %w", err)
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
-
-	return db, nil
+	for _, migration := range applied {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	}
+	return nil
 }
 
 func createSystemAccount(db *gorm.DB) error {
 	var systemAccount models.Account
-	result := db.Where("user_id = ?", "0").First(&systemAccount)
-	
+	result := db.Where("user_id = ?", models.SystemUserID).First(&systemAccount)
+
 	if result.Error == gorm.ErrRecordNotFound {
 		systemAccount = models.Account{
-			UserID:   "0",
+			UserID:   models.SystemUserID,
 			Currency: "UZS",
-			Balance:  "0.00",
+			Balance:  money.MustParse("0.00"),
 		}
-		
+
 		if err := db.Create(&systemAccount).Error; err != nil {
 			return fmt.Errorf("failed to create system account: %w", err)
 		}
 	} else if result.Error != nil {
 		return fmt.Errorf("failed to check system account: %w", result.Error)
 	}
-	
+
 	return nil
 }
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+type FXConfig struct {
+	SpreadBps int
+	QuoteTTL  time.Duration
+	RatesFile string
+}
+
+// GetFXConfig reads FX settings: FX_SPREAD_BPS is the margin applied to the
+// mid-market rate in basis points, FX_QUOTE_TTL how long a quote stays
+// valid and FX_RATES_FILE an optional CSV of rates loaded at startup.
+func GetFXConfig() *FXConfig {
+	spreadBps, err := strconv.Atoi(getEnv("FX_SPREAD_BPS", "50"))
+	if err != nil || spreadBps < 0 || spreadBps >= 10000 {
+		spreadBps = 50
+	}
+
+	quoteTTL, err := time.ParseDuration(getEnv("FX_QUOTE_TTL", "60s"))
+	if err != nil || quoteTTL <= 0 {
+		quoteTTL = 60 * time.Second
+	}
+
+	return &FXConfig{
+		SpreadBps: spreadBps,
+		QuoteTTL:  quoteTTL,
+		RatesFile: getEnv("FX_RATES_FILE", ""),
+	}
+}
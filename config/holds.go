@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+type HoldConfig struct {
+	TTL            time.Duration
+	ExpiryInterval time.Duration
+}
+
+// GetHoldConfig reads hold settings: HOLD_TTL is how long an authorization
+// reserves funds unless the request sets its own expiry, and
+// HOLD_EXPIRY_INTERVAL how often expired holds are released.
+func GetHoldConfig() *HoldConfig {
+	ttl, err := time.ParseDuration(getEnv("HOLD_TTL", "168h"))
+	if err != nil || ttl <= 0 {
+		ttl = 168 * time.Hour
+	}
+
+	interval, err := time.ParseDuration(getEnv("HOLD_EXPIRY_INTERVAL", "1m"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+
+	return &HoldConfig{
+		TTL:            ttl,
+		ExpiryInterval: interval,
+	}
+}
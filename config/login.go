@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+type LoginConfig struct {
+	MaxFailures   int
+	IPMaxFailures int
+	Lockout       time.Duration
+	BackoffBase   time.Duration
+	BackoffMax    time.Duration
+}
+
+// GetLoginConfig reads brute-force protection settings:
+// LOGIN_MAX_FAILURES and LOGIN_IP_MAX_FAILURES are the failed logins that
+// lock a user or an address, LOGIN_LOCKOUT how long the lock lasts, and
+// LOGIN_BACKOFF_BASE and LOGIN_BACKOFF_MAX bound the growing delay between
+// attempts before that.
+func GetLoginConfig() *LoginConfig {
+	maxFailures, err := strconv.Atoi(getEnv("LOGIN_MAX_FAILURES", "10"))
+	if err != nil || maxFailures <= 0 {
+		maxFailures = 10
+	}
+
+	ipMaxFailures, err := strconv.Atoi(getEnv("LOGIN_IP_MAX_FAILURES", "50"))
+	if err != nil || ipMaxFailures <= 0 {
+		ipMaxFailures = 50
+	}
+
+	lockout, err := time.ParseDuration(getEnv("LOGIN_LOCKOUT", "15m"))
+	if err != nil || lockout <= 0 {
+		lockout = 15 * time.Minute
+	}
+
+	backoffBase, err := time.ParseDuration(getEnv("LOGIN_BACKOFF_BASE", "1s"))
+	if err != nil || backoffBase <= 0 {
+		backoffBase = time.Second
+	}
+
+	backoffMax, err := time.ParseDuration(getEnv("LOGIN_BACKOFF_MAX", "1m"))
+	if err != nil || backoffMax < backoffBase {
+		backoffMax = time.Minute
+	}
+
+	return &LoginConfig{
+		MaxFailures:   maxFailures,
+		IPMaxFailures: ipMaxFailures,
+		Lockout:       lockout,
+		BackoffBase:   backoffBase,
+		BackoffMax:    backoffMax,
+	}
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strconv"
+	"time"
+)
+
+type PasswordConfig struct {
+	MinLength        int
+	RequireMixedCase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	ResetTTL         time.Duration
+	Notifier         string
+	NotifierFile     string
+}
+
+// GetPasswordConfig reads the password policy (PASSWORD_MIN_LENGTH,
+// PASSWORD_REQUIRE_MIXED_CASE, PASSWORD_REQUIRE_DIGIT,
+// PASSWORD_REQUIRE_SYMBOL), PASSWORD_RESET_TTL, how long a reset token is
+// valid, and how reset tokens are delivered: NOTIFIER is "log" or "file",
+// the latter appending to NOTIFIER_FILE.
+func GetPasswordConfig() *PasswordConfig {
+	minLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil || minLength < 1 {
+		minLength = 8
+	}
+
+	resetTTL, err := time.ParseDuration(getEnv("PASSWORD_RESET_TTL", "30m"))
+	if err != nil || resetTTL <= 0 {
+		resetTTL = 30 * time.Minute
+	}
+
+	return &PasswordConfig{
+		MinLength:        minLength,
+		RequireMixedCase: getBool("PASSWORD_REQUIRE_MIXED_CASE", false),
+		RequireDigit:     getBool("PASSWORD_REQUIRE_DIGIT", true),
+		RequireSymbol:    getBool("PASSWORD_REQUIRE_SYMBOL", false),
+		ResetTTL:         resetTTL,
+		Notifier:         getEnv("NOTIFIER", "log"),
+		NotifierFile:     getEnv("NOTIFIER_FILE", "notifications.log"),
+	}
+}
+
+func getBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, strconv.FormatBool(defaultValue)))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
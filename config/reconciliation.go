@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+type ReconciliationConfig struct {
+	SigningKey string
+	Interval   time.Duration
+}
+
+// GetReconciliationConfig reads RECONCILIATION_SIGNING_KEY, the secret that
+// signs stored reconciliation reports, and RECONCILIATION_INTERVAL, how often
+// the scheduled run happens; 0 turns the schedule off.
+func GetReconciliationConfig() *ReconciliationConfig {
+	interval, err := time.ParseDuration(getEnv("RECONCILIATION_INTERVAL", "24h"))
+	if err != nil || interval < 0 {
+		interval = 24 * time.Hour
+	}
+
+	return &ReconciliationConfig{
+		SigningKey: getEnv("RECONCILIATION_SIGNING_KEY", ""),
+		Interval:   interval,
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+type ScheduledTransferConfig struct {
+	Interval time.Duration
+	MaxAhead time.Duration
+}
+
+// GetScheduledTransferConfig reads SCHEDULED_TRANSFER_INTERVAL, how often due
+// scheduled transfers are executed, and SCHEDULED_TRANSFER_MAX_AHEAD, how far
+// in the future a transfer may be scheduled.
+func GetScheduledTransferConfig() *ScheduledTransferConfig {
+	interval, err := time.ParseDuration(getEnv("SCHEDULED_TRANSFER_INTERVAL", "30s"))
+	if err != nil || interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	maxAhead, err := time.ParseDuration(getEnv("SCHEDULED_TRANSFER_MAX_AHEAD", "8760h"))
+	if err != nil || maxAhead <= 0 {
+		maxAhead = 8760 * time.Hour
+	}
+
+	return &ScheduledTransferConfig{
+		Interval: interval,
+		MaxAhead: maxAhead,
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import "time"
+
+type SessionConfig struct {
+	IdleTimeout   time.Duration
+	MaxLifetime   time.Duration
+	MFATimeout    time.Duration
+	SweepInterval time.Duration
+}
+
+// GetSessionConfig reads session settings: SESSION_IDLE_TIMEOUT is how long
+// a session survives without use, SESSION_MAX_LIFETIME how long it can be
+// extended, SESSION_MFA_TIMEOUT how long a login may wait for its two-factor
+// code and SESSION_SWEEP_INTERVAL how often expired sessions are purged.
+func GetSessionConfig() *SessionConfig {
+	idle, err := time.ParseDuration(getEnv("SESSION_IDLE_TIMEOUT", "24h"))
+	if err != nil || idle <= 0 {
+		idle = 24 * time.Hour
+	}
+
+	maxLifetime, err := time.ParseDuration(getEnv("SESSION_MAX_LIFETIME", "168h"))
+	if err != nil || maxLifetime <= 0 {
+		maxLifetime = 168 * time.Hour
+	}
+
+	mfaTimeout, err := time.ParseDuration(getEnv("SESSION_MFA_TIMEOUT", "5m"))
+	if err != nil || mfaTimeout <= 0 {
+		mfaTimeout = 5 * time.Minute
+	}
+
+	sweepInterval, err := time.ParseDuration(getEnv("SESSION_SWEEP_INTERVAL", "10m"))
+	if err != nil || sweepInterval <= 0 {
+		sweepInterval = 10 * time.Minute
+	}
+
+	return &SessionConfig{
+		IdleTimeout:   idle,
+		MaxLifetime:   maxLifetime,
+		MFATimeout:    mfaTimeout,
+		SweepInterval: sweepInterval,
+	}
+}
@@ -1,59 +1,164 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
-	"bank-ledger-core/models"
 )
 
 type AccountHandler struct {
-	db *gorm.DB
+	db             *gorm.DB
+	journal        *services.JournalService
+	accountService *services.AccountService
+	audit          *services.AuditService
+}
+
+func NewAccountHandler(db *gorm.DB, journal *services.JournalService, accountService *services.AccountService, audit *services.AuditService) *AccountHandler {
+	return &AccountHandler{db: db, journal: journal, accountService: accountService, audit: audit}
 }
 
-func NewAccountHandler(db *gorm.DB) *AccountHandler {
-	return &AccountHandler{db: db}
+type CreateAccountRequest struct {
+	UserID   string             `json:"user_id" binding:"required"`
+	Currency string             `json:"currency" binding:"required,len=3"`
+	Type     models.AccountType `json:"type"`
+	Name     string             `json:"name" binding:"max=100"`
+	Balance  money.Amount       `json:"balance"`
 }
 
 func (h *AccountHandler) CreateAccount(c *gin.Context) {
-	var account models.Account
-	if err := c.ShouldBindJSON(&account); err != nil {
+	var req CreateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request format",
+			"error":   "Invalid request format",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	if err := h.db.Create(&account).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create account",
+	account := models.Account{UserID: req.UserID, Type: req.Type, Name: req.Name, Currency: strings.ToUpper(req.Currency)}
+	if account.Type != "" && !account.Type.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid account type",
+		})
+		return
+	}
+
+	if req.Balance.IsNegative() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid balance",
+			"details": money.ErrNegativeAmount.Error(),
+		})
+		return
+	}
+
+	balance, err := req.Balance.In(account.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid balance",
+			"details": err.Error(),
+		})
+		return
+	}
+	account.Balance = money.FromMinor(0, account.Currency)
+
+	// The initial balance enters the ledger as an opening journal entry
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.accountService.Create(tx, &account); err != nil {
+			return err
+		}
+		if !balance.IsZero() {
+			if _, err := h.journal.Fund(tx, models.EntryTypeOpeningBalance, &account, balance, "Opening balance"); err != nil {
+				return err
+			}
+		}
+		return h.audit.Record(tx, currentActor(c), services.AuditRecord{
+			Action:     models.AuditAccountCreate,
+			EntityType: "account",
+			EntityID:   account.ID,
+			After:      account,
+		})
+	})
+	if err != nil {
+		respondOpenAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// OpenAccount opens an empty account for the user in the path.
+func (h *AccountHandler) OpenAccount(c *gin.Context) {
+	var req services.OpenAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
 			"details": err.Error(),
 		})
 		return
 	}
 
+	account, err := h.accountService.OpenAccount(currentActor(c), c.Param("user_id"), req)
+	if err != nil {
+		respondOpenAccountError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusCreated, account)
 }
 
-func (h *AccountHandler) GetAccount(c *gin.Context) {
-	idStr := c.Param("id")
-	var account models.Account
-	var err error
+// ListUserAccounts returns every account of the user in the path.
+func (h *AccountHandler) ListUserAccounts(c *gin.Context) {
+	accounts, err := h.accountService.ListAccounts(currentActor(c), c.Param("user_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrUserNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, accounts)
+}
 
-	// Try to parse as numeric ID first
-	id, parseErr := strconv.ParseUint(idStr, 10, 32)
-	if parseErr == nil {
-		// Search by numeric ID
-		err = h.db.First(&account, id).Error
-	} else {
-		// Search by user_id (string)
-		err = h.db.Where("user_id = ?", idStr).First(&account).Error
+func respondOpenAccountError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrUserNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrAccountExists):
+		status = http.StatusConflict
 	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
 
+func (h *AccountHandler) GetAccount(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid account ID",
+		})
+		return
+	}
+
+	var account models.Account
+	if err := h.db.First(&account, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"error": "Account not found",
@@ -65,6 +170,9 @@ func (h *AccountHandler) GetAccount(c *gin.Context) {
 		})
 		return
 	}
+	if !authorizeOwner(c, h.db, account.UserID, "view an account") {
+		return
+	}
 
 	c.JSON(http.StatusOK, account)
 }
@@ -73,7 +181,7 @@ func (h *AccountHandler) GetAccounts(c *gin.Context) {
 	var accounts []models.Account
 	if err := h.db.Find(&accounts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve accounts",
+			"error":   "Failed to retrieve accounts",
 			"details": err.Error(),
 		})
 		return
@@ -81,3 +189,69 @@ func (h *AccountHandler) GetAccounts(c *gin.Context) {
 
 	c.JSON(http.StatusOK, accounts)
 }
+
+// ChangeStatus freezes, unfreezes or closes an account. A reason is required
+// and stored on the account.
+func (h *AccountHandler) ChangeStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid account ID",
+		})
+		return
+	}
+
+	var req services.ChangeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	account, err := h.accountService.ChangeStatus(currentActor(c), uint(id), req)
+	if err != nil {
+		status := http.StatusConflict
+		switch {
+		case errors.Is(err, services.ErrAccountNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrRejected):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+func (h *AccountHandler) SetRole(c *gin.Context) {
+	var req services.SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	userID := c.Param("user_id")
+	if err := h.accountService.SetRole(currentActor(c), userID, req.Role); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrUserNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userID,
+		"role":    req.Role,
+	})
+}
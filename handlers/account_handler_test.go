@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A status the ledger does not know is a bad request, while a known status
+// the account cannot move to is a conflict with its current state.
+func TestChangeStatusErrors(t *testing.T) {
+	db := newTestDB(t)
+	journal := services.NewJournalService(db)
+	h := NewAccountHandler(db, journal, services.NewAccountService(db), services.NewAuditService(db))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", "operator")
+		c.Set("role", models.RoleOperator)
+	})
+	r.PUT("/accounts/:id/status", h.ChangeStatus)
+
+	alice := createAccount(t, db, "alice", "100.00")
+	changeStatus := func(status string) *httptest.ResponseRecorder {
+		body := `{"status":"` + status + `","reason":"review"}`
+		req := httptest.NewRequest(http.MethodPut, "/accounts/"+strconv.Itoa(int(alice.ID))+"/status", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tc := range []struct {
+		status string
+		want   int
+	}{
+		{"suspended", http.StatusBadRequest},
+		{"active", http.StatusConflict},
+		{"closed", http.StatusConflict},
+		{"frozen", http.StatusOK},
+	} {
+		if w := changeStatus(tc.status); w.Code != tc.want {
+			t.Fatalf("status %q = %d %s, want %d", tc.status, w.Code, w.Body, tc.want)
+		}
+	}
+}
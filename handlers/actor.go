@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"bank-ledger-core/middleware"
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OverrideHeader carries the reason an operator or admin gives for acting on
+// another user's resources. Without it ownership is enforced for every role.
+const OverrideHeader = "X-Ownership-Override"
+
+// currentActor identifies the authenticated caller for ownership checks.
+func currentActor(c *gin.Context) services.Actor {
+	return services.Actor{
+		UserID:         middleware.GetUserID(c),
+		Role:           middleware.GetRole(c),
+		Scopes:         middleware.GetScopes(c),
+		SessionID:      middleware.GetSessionID(c),
+		APIKeyID:       middleware.GetAPIKeyID(c),
+		IP:             c.ClientIP(),
+		RequestID:      middleware.GetRequestID(c),
+		StepUpAt:       middleware.GetStepUpAt(c),
+		OverrideReason: strings.TrimSpace(c.GetHeader(OverrideHeader)),
+	}
+}
+
+// authorizeOwner responds with 403 and returns false when the caller may not
+// act on resources owned by ownerUserID.
+func authorizeOwner(c *gin.Context, db *gorm.DB, ownerUserID, action string) bool {
+	err := currentActor(c).Authorize(db, ownerUserID, action)
+	if errors.Is(err, services.ErrForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record ownership override",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bank-ledger-core/middleware"
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// Create returns the new key with its token, which cannot be retrieved later.
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	var req services.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	key, err := h.apiKeyService.Create(currentActor(c), req)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *APIKeyHandler) List(c *gin.Context) {
+	keys, err := h.apiKeyService.List(currentActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve API keys",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"api_keys": keys,
+	})
+}
+
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+	id, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeyService.Revoke(currentActor(c), id)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+func (h *APIKeyHandler) Rotate(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+	id, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+
+	var req services.RotateAPIKeyRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	key, err := h.apiKeyService.Rotate(currentActor(c), id, req)
+	if err != nil {
+		respondAPIKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// requireSession stops API keys from managing credentials such as API keys,
+// sessions and two-factor settings, so a leaked key cannot be used to keep
+// access after it is revoked.
+func requireSession(c *gin.Context) bool {
+	if middleware.GetAPIKeyID(c) != "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "This can only be done from a logged-in session",
+		})
+		return false
+	}
+	return true
+}
+
+func apiKeyID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid API key ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondAPIKeyError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
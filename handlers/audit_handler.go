@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// GetEvents supports the query parameters actor, action, entity_type,
+// entity_id, request_id, from, to (YYYY-MM-DD or RFC 3339; to is
+// exclusive), before (a sequence, for paging) and limit.
+func (h *AuditHandler) GetEvents(c *gin.Context) {
+	filter := services.AuditFilter{
+		ActorUserID: c.Query("actor"),
+		Action:      c.Query("action"),
+		EntityType:  c.Query("entity_type"),
+		EntityID:    c.Query("entity_id"),
+		RequestID:   c.Query("request_id"),
+	}
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := c.Query(name); raw != "" {
+			parsed, err := parseHistoryTime(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("invalid %s: %v", name, err),
+				})
+				return
+			}
+			*target = &parsed
+		}
+	}
+	if raw := c.Query("before"); raw != "" {
+		before, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid before: expected an event sequence",
+			})
+			return
+		}
+		filter.BeforeSequence = before
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit: expected a positive integer",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	page, err := h.auditService.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to query audit log",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Verify recomputes the hash chain and reports the first broken event.
+func (h *AuditHandler) Verify(c *gin.Context) {
+	result, err := h.auditService.Verify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to verify audit log",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Valid {
+		status = http.StatusConflict
+	}
+	c.JSON(status, result)
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/services"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -14,13 +19,23 @@ import (
 )
 
 type AuthHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	audit     *services.AuditService
+	totp      *services.TOTPService
+	throttle  *services.LoginThrottleService
+	sessions  *services.SessionService
+	passwords *services.PasswordService
 }
 
+// RegisterRequest creates a user with an empty current account in Currency.
+// Signing up never creates money; accounts are funded by transfers or by an
+// operator.
 type RegisterRequest struct {
-	UserID   string `json:"user_id" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
-	Currency string `json:"currency" binding:"required"`
+	UserID      string `json:"user_id" binding:"required,max=255"`
+	Password    string `json:"password" binding:"required"`
+	Currency    string `json:"currency" binding:"required,len=3"`
+	DisplayName string `json:"display_name" binding:"max=255"`
+	Email       string `json:"email" binding:"omitempty,email,max=255"`
 }
 
 type LoginRequest struct {
@@ -29,14 +44,17 @@ type LoginRequest struct {
 }
 
 type AuthResponse struct {
-	Success bool   `json:"success"`
-	Message  string `json:"message"`
-	UserID   string `json:"user_id,omitempty"`
-	SessionID string `json:"session_id,omitempty"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
+	UserID      string `json:"user_id,omitempty"`
+	AccountID   uint   `json:"account_id,omitempty"`
+	SessionID   string `json:"session_id,omitempty"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	RetryAfter  int    `json:"retry_after,omitempty"`
 }
 
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
-	return &AuthHandler{db: db}
+func NewAuthHandler(db *gorm.DB, audit *services.AuditService, totp *services.TOTPService, throttle *services.LoginThrottleService, sessions *services.SessionService, passwords *services.PasswordService) *AuthHandler {
+	return &AuthHandler{db: db, audit: audit, totp: totp, throttle: throttle, sessions: sessions, passwords: passwords}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -49,9 +67,26 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// The marketplace and system users own the ledger's internal accounts
+	if models.IsReservedUserID(req.UserID) {
+		c.JSON(http.StatusBadRequest, AuthResponse{
+			Success: false,
+			Message: "User ID is reserved",
+		})
+		return
+	}
+
+	if err := money.CheckCurrency(req.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, AuthResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	// Check if user already exists
-	var existingAccount models.Account
-	if err := h.db.Where("user_id = ?", req.UserID).First(&existingAccount).Error; err == nil {
+	var existingUser models.User
+	if err := h.db.Where("id = ?", req.UserID).First(&existingUser).Error; err == nil {
 		c.JSON(http.StatusConflict, AuthResponse{
 			Success: false,
 			Message: "User already exists",
@@ -60,24 +95,48 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := h.passwords.Hash(req.UserID, req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, AuthResponse{
+		status, message := http.StatusInternalServerError, "Failed to hash password"
+		if errors.Is(err, services.ErrWeakPassword) {
+			status, message = http.StatusBadRequest, err.Error()
+		}
+		c.JSON(status, AuthResponse{
 			Success: false,
-			Message: "Failed to hash password",
+			Message: message,
 		})
 		return
 	}
 
-	// Create account
+	// Create the user and an empty current account
+	user := models.User{
+		ID:           req.UserID,
+		PasswordHash: hashedPassword,
+		DisplayName:  req.DisplayName,
+		Email:        req.Email,
+	}
 	account := models.Account{
-		UserID:       req.UserID,
-		PasswordHash: string(hashedPassword),
-		Currency:     req.Currency,
-		Balance:      "100000.00", // Default balance
+		UserID:   req.UserID,
+		Type:     models.AccountTypeCurrent,
+		Currency: strings.ToUpper(req.Currency),
 	}
+	account.Balance = money.FromMinor(0, account.Currency)
 
-	if err := h.db.Create(&account).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&account).Error; err != nil {
+			return err
+		}
+		return h.audit.Record(tx, authActor(c, user.ID), services.AuditRecord{
+			Action:     models.AuditAuthRegister,
+			EntityType: "user",
+			EntityID:   user.ID,
+			After:      map[string]interface{}{"user": user, "account": account},
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, AuthResponse{
 			Success: false,
 			Message: "Failed to create account",
@@ -86,9 +145,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, AuthResponse{
-		Success: true,
-		Message: "Account created successfully",
-		UserID:  account.UserID,
+		Success:   true,
+		Message:   "Account created successfully",
+		UserID:    user.ID,
+		AccountID: account.ID,
 	})
 }
 
@@ -102,9 +162,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if !h.checkThrottle(c, req.UserID) {
+		return
+	}
+
 	// Find user
-	var account models.Account
-	if err := h.db.Where("user_id = ?", req.UserID).First(&account).Error; err != nil {
+	var user models.User
+	if err := h.db.Where("id = ?", req.UserID).First(&user).Error; err != nil {
+		h.recordLoginFailure(c, req.UserID, "unknown user")
 		c.JSON(http.StatusUnauthorized, AuthResponse{
 			Success: false,
 			Message: "Invalid credentials",
@@ -113,7 +178,8 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(req.Password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		h.recordLoginFailure(c, req.UserID, "wrong password")
 		c.JSON(http.StatusUnauthorized, AuthResponse{
 			Success: false,
 			Message: "Invalid credentials",
@@ -121,8 +187,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Create session
-	sessionID, err := generateSessionID()
+	mfaRequired, err := h.totp.IsEnabled(user.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, AuthResponse{
 			Success: false,
@@ -131,13 +196,121 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	session := models.Session{
-		ID:        sessionID,
-		UserID:    account.UserID,
-		ExpiresAt: time.Now().Add(24 * time.Hour), // 24 hours
+	// With two-factor authentication on, the password only earns a partial
+	// session that VerifyLogin upgrades
+	var session *models.Session
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		session, err = h.sessions.Create(tx, user.ID, c.Request.UserAgent(), c.ClientIP(), mfaRequired)
+		if err != nil {
+			return err
+		}
+		actor := authActor(c, user.ID)
+		actor.Role = user.Role
+		actor.SessionID = session.ID
+		return h.audit.Record(tx, actor, services.AuditRecord{
+			Action:     models.AuditAuthLogin,
+			EntityType: "user",
+			EntityID:   user.ID,
+			After:      map[string]bool{"mfa_pending": mfaRequired},
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, AuthResponse{
+			Success: false,
+			Message: "Failed to create session",
+		})
+		return
+	}
+
+	setSessionCookie(c, session)
+
+	if mfaRequired {
+		c.JSON(http.StatusOK, AuthResponse{
+			Success:     true,
+			Message:     "Enter the code from your authenticator app",
+			UserID:      user.ID,
+			MFARequired: true,
+		})
+		return
+	}
+
+	h.resetThrottle(user.ID)
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Success:   true,
+		Message:   "Login successful",
+		UserID:    user.ID,
+		SessionID: session.ID,
+	})
+}
+
+// VerifyLogin completes a two-factor login. The partial session is replaced
+// by a full one with a new ID, which also counts as a fresh step-up.
+func (h *AuthHandler) VerifyLogin(c *gin.Context) {
+	var req services.TOTPCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, AuthResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
 	}
 
-	if err := h.db.Create(&session).Error; err != nil {
+	var pending models.Session
+	sessionID, err := c.Cookie("session_id")
+	if err != nil || h.db.Where("id = ? AND mfa_pending = ?", sessionID, true).First(&pending).Error != nil || pending.IsExpired() {
+		c.JSON(http.StatusUnauthorized, AuthResponse{
+			Success: false,
+			Message: "No two-factor login in progress",
+		})
+		return
+	}
+
+	if !h.checkThrottle(c, pending.UserID) {
+		return
+	}
+
+	actor := authActor(c, pending.UserID)
+	actor.SessionID = pending.ID
+	if err := h.totp.Verify(pending.UserID, req.Code); err != nil {
+		status := http.StatusUnauthorized
+		switch {
+		case errors.Is(err, services.ErrInvalidTOTPCode):
+			h.recordMFAFailure(c, actor, &pending)
+		case !errors.Is(err, services.ErrTOTPNotEnabled):
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, AuthResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var session *models.Session
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", pending.ID).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		var err error
+		session, err = h.sessions.Create(tx, pending.UserID, c.Request.UserAgent(), c.ClientIP(), false)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		session.StepUpAt = &now
+		if err := tx.Model(session).Update("step_up_at", now).Error; err != nil {
+			return err
+		}
+		actor.SessionID = session.ID
+		return h.audit.Record(tx, actor, services.AuditRecord{
+			Action:     models.AuditAuthMFAVerified,
+			EntityType: "user",
+			EntityID:   pending.UserID,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, AuthResponse{
 			Success: false,
 			Message: "Failed to create session",
@@ -145,21 +318,55 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Set cookie
-	c.SetCookie("session_id", sessionID, 86400, "/", "", false, true)
+	setSessionCookie(c, session)
+
+	h.resetThrottle(session.UserID)
 
 	c.JSON(http.StatusOK, AuthResponse{
 		Success:   true,
 		Message:   "Login successful",
-		UserID:    account.UserID,
-		SessionID: sessionID,
+		UserID:    session.UserID,
+		SessionID: session.ID,
 	})
 }
 
+// Me returns the profile of the logged-in user.
+func (h *AuthHandler) Me(c *gin.Context) {
+	var user models.User
+	if err := h.db.Where("id = ?", currentActor(c).UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "User not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
 	sessionID, err := c.Cookie("session_id")
 	if err == nil {
-		h.db.Where("id = ?", sessionID).Delete(&models.Session{})
+		var session models.Session
+		if h.db.Where("id = ?", sessionID).First(&session).Error == nil {
+			h.db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Where("id = ?", sessionID).Delete(&models.Session{}).Error; err != nil {
+					return err
+				}
+				actor := authActor(c, session.UserID)
+				actor.SessionID = session.ID
+				return h.audit.Record(tx, actor, services.AuditRecord{
+					Action:     models.AuditAuthLogout,
+					EntityType: "user",
+					EntityID:   session.UserID,
+				})
+			})
+		}
 	}
 
 	c.SetCookie("session_id", "", -1, "/", "", false, true)
@@ -169,10 +376,120 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	})
 }
 
-func generateSessionID() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+// setSessionCookie keeps the cookie for the session's whole lifetime; the
+// server enforces idle expiry.
+func setSessionCookie(c *gin.Context, session *models.Session) {
+	c.SetCookie("session_id", session.ID, int(time.Until(session.AbsoluteExpiresAt).Seconds()), "/", "", false, true)
+}
+
+// authActor identifies the caller of the public auth endpoints, which run
+// before a session exists.
+func authActor(c *gin.Context, userID string) services.Actor {
+	actor := currentActor(c)
+	actor.UserID = userID
+	return actor
+}
+
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, userID, reason string) {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return h.audit.Record(tx, authActor(c, userID), services.AuditRecord{
+			Action:     models.AuditAuthLoginFailed,
+			EntityType: "user",
+			EntityID:   userID,
+			After:      map[string]string{"reason": reason},
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to audit login failure for %s: %v", userID, err)
+	}
+	if err := h.throttle.RecordFailure(authActor(c, userID), userID, c.ClientIP()); err != nil {
+		log.Printf("Failed to throttle logins for %s: %v", userID, err)
+	}
+}
+
+// recordMFAFailure counts a wrong code against the partial session and ends
+// the session once it runs out of attempts.
+func (h *AuthHandler) recordMFAFailure(c *gin.Context, actor services.Actor, session *models.Session) {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		attempts := session.MFAAttempts + 1
+		var err error
+		if attempts >= services.MaxMFAAttempts {
+			err = tx.Where("id = ?", session.ID).Delete(&models.Session{}).Error
+		} else {
+			err = tx.Model(session).Update("mfa_attempts", attempts).Error
+		}
+		if err != nil {
+			return err
+		}
+		return h.audit.Record(tx, actor, services.AuditRecord{
+			Action:     models.AuditAuthMFAFailed,
+			EntityType: "user",
+			EntityID:   session.UserID,
+			After:      map[string]int{"attempts": attempts},
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to record two-factor failure for %s: %v", session.UserID, err)
+	}
+	if err := h.throttle.RecordFailure(actor, session.UserID, c.ClientIP()); err != nil {
+		log.Printf("Failed to throttle logins for %s: %v", session.UserID, err)
+	}
+}
+
+// checkThrottle responds with 429 and returns false while the user or the
+// client address has to wait after failed logins.
+func (h *AuthHandler) checkThrottle(c *gin.Context, userID string) bool {
+	block, err := h.throttle.Check(userID, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, AuthResponse{
+			Success: false,
+			Message: "Failed to check login attempts",
+		})
+		return false
+	}
+	if block == nil {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(block.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	message := "Too many failed login attempts, try again later"
+	if block.Locked {
+		message = "Login temporarily locked after too many failed attempts"
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, AuthResponse{
+		Success:    false,
+		Message:    message,
+		RetryAfter: retryAfter,
+	})
+	return false
+}
+
+func (h *AuthHandler) resetThrottle(userID string) {
+	if err := h.throttle.RecordSuccess(userID); err != nil {
+		log.Printf("Failed to reset login throttle for %s: %v", userID, err)
 	}
-	return hex.EncodeToString(bytes), nil
+}
+
+// Unlock lets an operator lift a user's login lockout early.
+func (h *AuthHandler) Unlock(c *gin.Context) {
+	userID := c.Param("user_id")
+	if err := h.throttle.Unlock(currentActor(c), userID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrNotLocked) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Login unlocked",
+		"user_id": userID,
+	})
 }
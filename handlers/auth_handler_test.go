@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/notify"
+	"bank-ledger-core/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func newRegisterRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t)
+
+	passwords := services.NewPasswordService(db, services.SystemClock, notify.LogNotifier{}, services.PasswordPolicy{}, time.Hour)
+	h := NewAuthHandler(db, services.NewAuditService(db), nil, nil, nil, passwords)
+	r := gin.New()
+	r.POST("/register", h.Register)
+	return r, db
+}
+
+func register(r *gin.Engine, userID, currency string) *httptest.ResponseRecorder {
+	body := `{"user_id":"` + userID + `","password":"correct horse battery","currency":"` + currency + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// Registering the marketplace or a system user would hand the caller the
+// accounts that receive order payments or may go negative.
+func TestRegisterRejectsReservedUserIDs(t *testing.T) {
+	r, db := newRegisterRouter(t)
+
+	for _, userID := range []string{models.SystemUserID, models.IssuanceUserID, models.FXUserID, "system:other"} {
+		if w := register(r, userID, "UZS"); w.Code != http.StatusBadRequest {
+			t.Fatalf("registering %q = %d %s, want 400", userID, w.Code, w.Body)
+		}
+	}
+	var accounts int64
+	db.Model(&models.Account{}).Count(&accounts)
+	if accounts != 0 {
+		t.Fatalf("%d accounts created for reserved users", accounts)
+	}
+
+	if w := register(r, "alice", "KWD"); w.Code != http.StatusBadRequest {
+		t.Fatalf("registering with KWD = %d %s, want 400", w.Code, w.Body)
+	}
+	if w := register(r, "alice", "UZS"); w.Code != http.StatusCreated {
+		t.Fatalf("registering alice = %d %s, want 201", w.Code, w.Body)
+	}
+
+	// Signing up does not create money
+	var account models.Account
+	db.Where("user_id = ?", "alice").First(&account)
+	var postings int64
+	db.Model(&models.Posting{}).Count(&postings)
+	if !account.Balance.IsZero() || postings != 0 {
+		t.Fatalf("new account balance = %s with %d postings, want an empty account", account.Balance, postings)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CloseHandler struct {
+	closeService *services.CloseService
+}
+
+func NewCloseHandler(closeService *services.CloseService) *CloseHandler {
+	return &CloseHandler{
+		closeService: closeService,
+	}
+}
+
+type CloseRequest struct {
+	Date string `json:"date"` // YYYY-MM-DD, defaults to yesterday
+}
+
+// Close closes every day through the requested one that is not closed yet.
+func (h *CloseHandler) Close(c *gin.Context) {
+	var req CloseRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	date := time.Now().UTC().AddDate(0, 0, -1)
+	if req.Date != "" {
+		parsed, err := services.ParseBusinessDate(req.Date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		date = parsed
+	}
+
+	closes, err := h.closeService.Close(currentActor(c), date)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrForbidden):
+			status = http.StatusForbidden
+		case errors.Is(err, services.ErrDayAlreadyClosed):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrDayNotOver):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"closed": closes,
+	})
+}
+
+// ListCloses supports the query parameter limit (at most 100).
+func (h *CloseHandler) ListCloses(c *gin.Context) {
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit: expected a positive integer",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	closes, err := h.closeService.ListCloses(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list closed days",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"closes": closes,
+	})
+}
+
+// GetClose returns a closed day with its totals per currency.
+func (h *CloseHandler) GetClose(c *gin.Context) {
+	date, err := services.ParseBusinessDate(c.Param("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	summary, err := h.closeService.GetClose(date)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrCloseNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetBalances returns the account's opening and closing balance, inflows
+// and outflows for ?date= (YYYY-MM-DD, default today).
+func (h *CloseHandler) GetBalances(c *gin.Context) {
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid account ID",
+		})
+		return
+	}
+
+	date := time.Now().UTC()
+	if raw := c.Query("date"); raw != "" {
+		if date, err = services.ParseBusinessDate(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	balance, err := h.closeService.DayBalance(currentActor(c), uint(accountID), date)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrAccountNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrForbidden):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
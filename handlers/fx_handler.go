@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"bank-ledger-core/middleware"
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type FXHandler struct {
+	fxService *services.FXService
+}
+
+func NewFXHandler(fxService *services.FXService) *FXHandler {
+	return &FXHandler{
+		fxService: fxService,
+	}
+}
+
+func (h *FXHandler) SetRate(c *gin.Context) {
+	var req services.SetRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+	if req.Source == "" {
+		req.Source = "api"
+	}
+
+	rate, err := h.fxService.SetRate(currentActor(c), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// ImportRates accepts a CSV either as a multipart "file" field or as the raw
+// request body.
+func (h *FXHandler) ImportRates(c *gin.Context) {
+	var reader io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "CSV file is required in the \"file\" field",
+			})
+			return
+		}
+		opened, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read uploaded file",
+			})
+			return
+		}
+		defer opened.Close()
+		reader = opened
+	}
+
+	imported, err := h.fxService.ImportRatesCSV(currentActor(c), reader, "csv")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"imported": imported,
+	})
+}
+
+func (h *FXHandler) GetRates(c *gin.Context) {
+	rates, err := h.fxService.ListRates(c.Query("base"), c.Query("quote"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve exchange rates",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rates": rates,
+	})
+}
+
+func (h *FXHandler) CreateQuote(c *gin.Context) {
+	var req services.QuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	quote, err := h.fxService.CreateQuote(currentActor(c), req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrRateNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, quote)
+}
+
+func (h *FXHandler) GetQuote(c *gin.Context) {
+	quote, err := h.fxService.GetQuote(c.Param("id"))
+	if err != nil || quote.UserID != middleware.GetUserID(c) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Quote not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"bank-ledger-core/money"
 	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
 )
 
 type HistoryHandler struct {
@@ -17,19 +22,37 @@ func NewHistoryHandler(historyService *services.HistoryService) *HistoryHandler
 	}
 }
 
+// GetAccountHistory supports the query parameters from, to (YYYY-MM-DD or
+// RFC 3339; to is exclusive), direction (in/out), min_amount, max_amount,
+// counterparty, type (transfer/order/refund/reversal/opening_balance/
+// adjustment/fee), cursor and limit.
 func (h *HistoryHandler) GetAccountHistory(c *gin.Context) {
-	userID := c.Param("user_id")
-	
-	if userID == "" {
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid account ID",
+		})
+		return
+	}
+
+	filter, err := parseHistoryFilter(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "User ID is required",
+			"error": err.Error(),
 		})
 		return
 	}
 
-	response, err := h.historyService.GetAccountHistory(userID)
+	response, err := h.historyService.GetAccountHistory(currentActor(c), uint(accountID), filter)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrAccountNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrForbidden):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -37,3 +60,49 @@ func (h *HistoryHandler) GetAccountHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+func parseHistoryFilter(c *gin.Context) (services.HistoryFilter, error) {
+	filter := services.HistoryFilter{
+		Direction:    c.Query("direction"),
+		Counterparty: c.Query("counterparty"),
+		Operation:    c.Query("type"),
+		Cursor:       c.Query("cursor"),
+	}
+
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := c.Query(name); raw != "" {
+			parsed, err := parseHistoryTime(raw)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = &parsed
+		}
+	}
+
+	for name, target := range map[string]**money.Amount{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if raw := c.Query(name); raw != "" {
+			parsed, err := money.Parse(raw)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = &parsed
+		}
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return filter, fmt.Errorf("invalid limit %q", raw)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+func parseHistoryTime(raw string) (time.Time, error) {
+	if parsed, err := time.Parse("2006-01-02", raw); err == nil {
+		return parsed, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
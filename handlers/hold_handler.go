@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type HoldHandler struct {
+	holdService *services.HoldService
+}
+
+func NewHoldHandler(holdService *services.HoldService) *HoldHandler {
+	return &HoldHandler{
+		holdService: holdService,
+	}
+}
+
+func (h *HoldHandler) Authorize(c *gin.Context) {
+	var req services.AuthorizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	hold, err := h.holdService.Authorize(currentActor(c), req)
+	if err != nil {
+		respondHoldError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+func (h *HoldHandler) GetHold(c *gin.Context) {
+	id, ok := holdID(c)
+	if !ok {
+		return
+	}
+
+	hold, err := h.holdService.GetHold(currentActor(c), id)
+	if err != nil {
+		respondHoldError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func (h *HoldHandler) Capture(c *gin.Context) {
+	id, ok := holdID(c)
+	if !ok {
+		return
+	}
+
+	var req services.CaptureRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	hold, err := h.holdService.Capture(currentActor(c), id, req)
+	if err != nil {
+		respondHoldError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func (h *HoldHandler) Void(c *gin.Context) {
+	id, ok := holdID(c)
+	if !ok {
+		return
+	}
+
+	hold, err := h.holdService.Void(currentActor(c), id)
+	if err != nil {
+		respondHoldError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func holdID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid hold ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondHoldError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrStepUpRequired) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            err.Error(),
+			"step_up_required": true,
+		})
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrHoldNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrHoldNotPending), errors.Is(err, services.ErrHoldExpired):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
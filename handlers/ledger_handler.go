@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	journal *services.JournalService
+}
+
+func NewLedgerHandler(journal *services.JournalService) *LedgerHandler {
+	return &LedgerHandler{
+		journal: journal,
+	}
+}
+
+func (h *LedgerHandler) Check(c *gin.Context) {
+	report, err := h.journal.Check()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to check ledger",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
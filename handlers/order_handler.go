@@ -1,20 +1,24 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"bank-ledger-core/middleware"
+	"bank-ledger-core/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
-	"bank-ledger-core/services"
 )
 
 type OrderHandler struct {
+	db           *gorm.DB
 	orderService *services.OrderService
 }
 
-func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
+func NewOrderHandler(db *gorm.DB, orderService *services.OrderService) *OrderHandler {
 	return &OrderHandler{
+		db:           db,
 		orderService: orderService,
 	}
 }
@@ -23,16 +27,17 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	var req services.CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request format",
+			"error":   "Invalid request format",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	result, err := h.orderService.CreateOrder(req)
+	result, err := h.orderService.CreateOrder(currentActor(c), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": result.Message,
+		status := movementFailureStatus(err)
+		c.JSON(status, gin.H{
+			"error": movementFailureMessage(err, status, "Failed to place order"),
 		})
 		return
 	}
@@ -47,16 +52,16 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 func (h *OrderHandler) GetOrders(c *gin.Context) {
 	userID := c.Query("user_id")
 	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "user_id query parameter is required",
-		})
+		userID = middleware.GetUserID(c)
+	}
+	if !authorizeOwner(c, h.db, userID, "view orders") {
 		return
 	}
 
 	orders, err := h.orderService.GetOrdersByUserID(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve orders",
+			"error":   "Failed to retrieve orders",
 			"details": err.Error(),
 		})
 		return
@@ -86,11 +91,49 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve order",
+			"error":   "Failed to retrieve order",
 			"details": err.Error(),
 		})
 		return
 	}
+	if !authorizeOwner(c, h.db, order.UserID, "view an order") {
+		return
+	}
 
 	c.JSON(http.StatusOK, order)
 }
+
+func (h *OrderHandler) RefundOrder(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid order ID",
+		})
+		return
+	}
+
+	var req services.RefundOrderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request format",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	result, err := h.orderService.RefundOrder(currentActor(c), uint(id), req)
+	if err != nil {
+		status := movementFailureStatus(err)
+		if errors.Is(err, services.ErrOrderNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": movementFailureMessage(err, status, "Failed to refund order"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordHandler struct {
+	passwordService *services.PasswordService
+}
+
+func NewPasswordHandler(passwordService *services.PasswordService) *PasswordHandler {
+	return &PasswordHandler{
+		passwordService: passwordService,
+	}
+}
+
+// Change sets a new password and logs out the user's other sessions.
+func (h *PasswordHandler) Change(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	var req services.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.passwordService.Change(currentActor(c), req); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password changed",
+	})
+}
+
+// RequestReset always answers 202 so it cannot be used to find users.
+func (h *PasswordHandler) RequestReset(c *gin.Context) {
+	var req services.PasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.passwordService.RequestReset(authActor(c, req.UserID), req.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to request a password reset",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the user exists, a reset token has been sent",
+	})
+}
+
+func (h *PasswordHandler) Reset(c *gin.Context) {
+	var req services.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.passwordService.Reset(currentActor(c), req); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Password reset, please log in",
+	})
+}
+
+func respondPasswordError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrWrongPassword), errors.Is(err, services.ErrInvalidResetToken):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrPasswordUnchanged):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
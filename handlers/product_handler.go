@@ -3,24 +3,27 @@ package handlers
 import (
 	"net/http"
 
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
-	"bank-ledger-core/models"
 )
 
 type ProductHandler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	audit *services.AuditService
 }
 
-func NewProductHandler(db *gorm.DB) *ProductHandler {
-	return &ProductHandler{db: db}
+func NewProductHandler(db *gorm.DB, audit *services.AuditService) *ProductHandler {
+	return &ProductHandler{db: db, audit: audit}
 }
 
 func (h *ProductHandler) GetProducts(c *gin.Context) {
 	var products []models.Product
 	if err := h.db.Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve products",
+			"error":   "Failed to retrieve products",
 			"details": err.Error(),
 		})
 		return
@@ -42,7 +45,7 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to retrieve product",
+			"error":   "Failed to retrieve product",
 			"details": err.Error(),
 		})
 		return
@@ -55,15 +58,36 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var product models.Product
 	if err := c.ShouldBindJSON(&product); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request format",
+			"error":   "Invalid request format",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	if err := h.db.Create(&product).Error; err != nil {
+	if err := money.Validate(product.Price, ""); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid price",
+			"details": err.Error(),
+		})
+		return
+	}
+	product.Price = product.Price.Normalize("")
+	product.MerchantID = currentActor(c).UserID
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return h.audit.Record(tx, currentActor(c), services.AuditRecord{
+			Action:     models.AuditProductCreate,
+			EntityType: "product",
+			EntityID:   product.ID,
+			After:      product,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create product",
+			"error":   "Failed to create product",
 			"details": err.Error(),
 		})
 		return
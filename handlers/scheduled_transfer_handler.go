@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduledTransferHandler struct {
+	scheduledTransferService *services.ScheduledTransferService
+}
+
+func NewScheduledTransferHandler(scheduledTransferService *services.ScheduledTransferService) *ScheduledTransferHandler {
+	return &ScheduledTransferHandler{
+		scheduledTransferService: scheduledTransferService,
+	}
+}
+
+func (h *ScheduledTransferHandler) Schedule(c *gin.Context) {
+	var req services.ScheduleTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	scheduled, err := h.scheduledTransferService.Schedule(currentActor(c), req)
+	if err != nil {
+		respondScheduledTransferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, scheduled)
+}
+
+// List supports the query parameters user_id (defaults to the caller),
+// status and limit.
+func (h *ScheduledTransferHandler) List(c *gin.Context) {
+	filter := services.ScheduledTransferFilter{
+		UserID: c.Query("user_id"),
+		Status: models.ScheduledTransferStatus(c.Query("status")),
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid limit: expected a positive integer",
+			})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	scheduled, err := h.scheduledTransferService.List(currentActor(c), filter)
+	if err != nil {
+		respondScheduledTransferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scheduled_transfers": scheduled,
+	})
+}
+
+func (h *ScheduledTransferHandler) Get(c *gin.Context) {
+	id, ok := scheduledTransferID(c)
+	if !ok {
+		return
+	}
+
+	scheduled, err := h.scheduledTransferService.Get(currentActor(c), id)
+	if err != nil {
+		respondScheduledTransferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduled)
+}
+
+func (h *ScheduledTransferHandler) Cancel(c *gin.Context) {
+	id, ok := scheduledTransferID(c)
+	if !ok {
+		return
+	}
+
+	scheduled, err := h.scheduledTransferService.Cancel(currentActor(c), id)
+	if err != nil {
+		respondScheduledTransferError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scheduled)
+}
+
+func scheduledTransferID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid scheduled transfer ID",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondScheduledTransferError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrStepUpRequired) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            err.Error(),
+			"step_up_required": true,
+		})
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrScheduledTransferNotFound), errors.Is(err, services.ErrAccountNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrScheduledTransferNotPending):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	sessions, err := h.sessionService.List(currentActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+	})
+}
+
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	actor := currentActor(c)
+	handle := c.Param("id")
+	if err := h.sessionService.Revoke(actor, handle); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if handle == services.SessionHandle(actor.SessionID) {
+		c.SetCookie("session_id", "", -1, "/", "", false, true)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeAll logs out everywhere. With keep_current=true the session making
+// the request stays logged in.
+func (h *SessionHandler) RevokeAll(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	keepCurrent := c.Query("keep_current") == "true"
+	revoked, err := h.sessionService.RevokeAll(currentActor(c), keepCurrent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+	if !keepCurrent {
+		c.SetCookie("session_id", "", -1, "/", "", false, true)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Sessions revoked",
+		"revoked": revoked,
+	})
+}
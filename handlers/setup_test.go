@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bank-ledger-core/migrations"
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "ledger.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createAccount(t *testing.T, db *gorm.DB, userID, balance string) *models.Account {
+	t.Helper()
+	account := &models.Account{UserID: userID, Currency: "UZS", Balance: money.FromMinor(0, "UZS")}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.FirstOrCreate(&models.User{}, models.User{ID: userID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(account).Error; err != nil {
+			return err
+		}
+		amount := money.MustParse(balance)
+		if amount.IsZero() {
+			return nil
+		}
+		_, err := services.NewJournalService(db).Fund(tx, models.EntryTypeOpeningBalance, account, amount, "test funding")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
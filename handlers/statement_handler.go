@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bank-ledger-core/services"
+	"bank-ledger-core/statement"
+	"github.com/gin-gonic/gin"
+)
+
+type StatementHandler struct {
+	historyService *services.HistoryService
+}
+
+func NewStatementHandler(historyService *services.HistoryService) *StatementHandler {
+	return &StatementHandler{
+		historyService: historyService,
+	}
+}
+
+// GetStatement renders the account history for [from, to) as CSV, OFX or
+// camt.053, chosen by ?format= or the Accept header. The period defaults to
+// the current calendar month up to now.
+func (h *StatementHandler) GetStatement(c *gin.Context) {
+	accountID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid account ID",
+		})
+		return
+	}
+
+	format, ok := statement.Negotiate(c.Query("format"), c.GetHeader("Accept"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported format, expected csv, ofx or camt053",
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := now
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if raw := c.Query(name); raw != "" {
+			parsed, err := parseHistoryTime(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("invalid %s: %v", name, err),
+				})
+				return
+			}
+			*target = parsed
+		}
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be before to",
+		})
+		return
+	}
+
+	stmt, err := h.historyService.Statement(currentActor(c), uint(accountID), from, to)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, services.ErrAccountNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrForbidden):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%d-%s-%s.%s", stmt.AccountID, from.Format("20060102"), to.Format("20060102"), format.Extension)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Content-Type", format.ContentType)
+	c.Status(http.StatusOK)
+	if err := format.Write(c.Writer, stmt); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
+)
+
+type TOTPHandler struct {
+	totpService *services.TOTPService
+}
+
+func NewTOTPHandler(totpService *services.TOTPService) *TOTPHandler {
+	return &TOTPHandler{
+		totpService: totpService,
+	}
+}
+
+func (h *TOTPHandler) Enroll(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	enrollment, err := h.totpService.Enroll(currentActor(c))
+	if err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, enrollment)
+}
+
+// Confirm returns the recovery codes, which are shown only once.
+func (h *TOTPHandler) Confirm(c *gin.Context) {
+	req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.totpService.Confirm(currentActor(c), req.Code)
+	if err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *TOTPHandler) Disable(c *gin.Context) {
+	req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+
+	if err := h.totpService.Disable(currentActor(c), req.Code); err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+func (h *TOTPHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.totpService.RegenerateRecoveryCodes(currentActor(c), req.Code)
+	if err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recovery_codes": codes,
+	})
+}
+
+func (h *TOTPHandler) StepUp(c *gin.Context) {
+	req, ok := bindTOTPCode(c)
+	if !ok {
+		return
+	}
+
+	at, err := h.totpService.StepUp(currentActor(c), req.Code)
+	if err != nil {
+		respondTOTPError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"step_up_at": at,
+	})
+}
+
+// bindTOTPCode reads the code and stops API keys, which have no session to
+// verify, from changing two-factor settings.
+func bindTOTPCode(c *gin.Context) (services.TOTPCodeRequest, bool) {
+	var req services.TOTPCodeRequest
+	if !requireSession(c) {
+		return req, false
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request format",
+			"details": err.Error(),
+		})
+		return req, false
+	}
+	return req, true
+}
+
+func respondTOTPError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidTOTPCode), errors.Is(err, services.ErrTOTPAttemptsUsedUp):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrTOTPAlreadyEnabled), errors.Is(err, services.ErrTOTPNotEnabled):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 
-	"github.com/gin-gonic/gin"
 	"bank-ledger-core/services"
+	"github.com/gin-gonic/gin"
 )
 
 type TransferHandler struct {
@@ -21,15 +24,15 @@ func (h *TransferHandler) TransferMoney(c *gin.Context) {
 	var req services.TransferRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request format",
+			"error":   "Invalid request format",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	response, err := h.transferService.TransferMoney(req)
+	response, err := h.transferService.TransferMoney(currentActor(c), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response)
+		c.JSON(transferFailureStatus(err, response), response)
 		return
 	}
 
@@ -40,17 +43,77 @@ func (h *TransferHandler) TransferMoneyByUserIDs(c *gin.Context) {
 	var req services.UserTransferRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request format",
+			"error":   "Invalid request format",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	response, err := h.transferService.TransferMoneyByUserIDs(req)
+	response, err := h.transferService.TransferMoneyByUserIDs(currentActor(c), req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response)
+		c.JSON(transferFailureStatus(err, response), response)
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+func (h *TransferHandler) ReverseTransfer(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid transfer ID",
+		})
+		return
+	}
+
+	reversal, err := h.transferService.ReverseTransfer(currentActor(c), uint(id))
+	if err != nil {
+		status := movementFailureStatus(err)
+		if errors.Is(err, services.ErrTransferNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": movementFailureMessage(err, status, "Failed to reverse transfer"),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reversal)
+}
+
+// transferFailureStatus maps a failed transfer to its HTTP status and flags
+// responses the client can retry after a two-factor step-up.
+func transferFailureStatus(err error, response *services.TransferResponse) int {
+	if errors.Is(err, services.ErrStepUpRequired) {
+		response.StepUpRequired = true
+	}
+	status := movementFailureStatus(err)
+	response.Message = movementFailureMessage(err, status, "Transfer failed")
+	return status
+}
+
+// movementFailureStatus maps a failed transfer, order or refund to its HTTP
+// status. Only rejections are the client's fault; a lost race or a storage
+// failure answers 409 or 500, which the idempotency middleware does not
+// store, so the client can retry with the same key.
+func movementFailureStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrStepUpRequired), errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrConcurrentUpdate):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrRejected):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// movementFailureMessage keeps storage errors out of responses.
+func movementFailureMessage(err error, status int, failure string) string {
+	if status != http.StatusInternalServerError {
+		return err.Error()
+	}
+	log.Printf("%s: %v", failure, err)
+	return failure
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-ledger-core/middleware"
+	"bank-ledger-core/models"
+	"bank-ledger-core/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A request the ledger rejects is answered 400 and replayed under its key,
+// while a storage failure is answered 500 without its details and releases
+// the key so the same request can be retried.
+func TestTransferFailureStatus(t *testing.T) {
+	db := newTestDB(t)
+	journal := services.NewJournalService(db)
+	transfers := services.NewTransferService(db, journal, services.NewFXService(db, 50, time.Minute), services.StepUpPolicy{})
+	h := NewTransferHandler(transfers)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", "alice")
+		c.Set("role", models.RoleCustomer)
+	})
+	r.POST("/transfers", middleware.Idempotency(db), h.TransferMoney)
+
+	alice := createAccount(t, db, "alice", "100.00")
+	bob := createAccount(t, db, "bob", "0")
+	transfer := func(key, amount string) *httptest.ResponseRecorder {
+		body := `{"from_account_id":` + strconv.Itoa(int(alice.ID)) + `,"to_account_id":` + strconv.Itoa(int(bob.ID)) + `,"amount":"` + amount + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/transfers", strings.NewReader(body))
+		req.Header.Set(middleware.IdempotencyHeader, key)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := transfer("overdraft", "500.00"); w.Code != http.StatusBadRequest {
+		t.Fatalf("overdraft = %d %s, want 400", w.Code, w.Body)
+	}
+	if w := transfer("overdraft", "500.00"); w.Code != http.StatusBadRequest || w.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("overdraft retry = %d replayed %q, want a replayed 400", w.Code, w.Header().Get("Idempotent-Replayed"))
+	}
+
+	if err := db.Exec("ALTER TABLE transfers RENAME TO transfers_offline").Error; err != nil {
+		t.Fatal(err)
+	}
+	w := transfer("outage", "10.00")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("transfer during an outage = %d %s, want 500", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "transfers") {
+		t.Fatalf("500 response leaks the storage error: %s", w.Body)
+	}
+	if err := db.Exec("ALTER TABLE transfers_offline RENAME TO transfers").Error; err != nil {
+		t.Fatal(err)
+	}
+	if w := transfer("outage", "10.00"); w.Code != http.StatusOK || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after the outage = %d %s, want a fresh 200", w.Code, w.Body)
+	}
+
+	if got := movementFailureStatus(services.ErrConcurrentUpdate); got != http.StatusConflict {
+		t.Fatalf("lost race = %d, want 409", got)
+	}
+}
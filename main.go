@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"bank-ledger-core/config"
 	"bank-ledger-core/routes"
+	"bank-ledger-core/services"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	dbDriver := getEnv("DB_DRIVER", "postgres")
 	dbConfig := config.GetDatabaseConfig()
 
@@ -17,7 +23,45 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	router := routes.SetupRoutes(db)
+	svc := routes.NewServices(db)
+
+	if err := svc.Journal.BackfillOpeningBalances(); err != nil {
+		log.Fatalf("Failed to backfill journal: %v", err)
+	}
+	if err := svc.Journal.BackfillBalanceAfter(); err != nil {
+		log.Fatalf("Failed to backfill running balances: %v", err)
+	}
+	if err := svc.Order.BackfillAccounts(); err != nil {
+		log.Fatalf("Failed to backfill order accounts: %v", err)
+	}
+
+	if ratesFile := config.GetFXConfig().RatesFile; ratesFile != "" {
+		if err := loadExchangeRates(svc.FX, ratesFile); err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+	}
+
+	if os.Getenv("ADMIN_USER_IDS") != "" {
+		log.Printf("ADMIN_USER_IDS is no longer read; create administrators with `ledgerctl admin create`")
+	}
+
+	go svc.Hold.RunExpiry(context.Background(), config.GetHoldConfig().ExpiryInterval)
+	go svc.Session.RunSweeper(context.Background(), config.GetSessionConfig().SweepInterval)
+
+	reconciliationConfig := config.GetReconciliationConfig()
+	if reconciliationConfig.SigningKey != "" && reconciliationConfig.Interval > 0 {
+		go svc.Reconciliation.RunSchedule(context.Background(), reconciliationConfig.Interval)
+	} else {
+		log.Printf("Scheduled reconciliation is off; set RECONCILIATION_SIGNING_KEY and RECONCILIATION_INTERVAL to enable it")
+	}
+
+	if closeInterval := config.GetCloseConfig().Interval; closeInterval > 0 {
+		go svc.Close.RunSchedule(context.Background(), closeInterval)
+	}
+
+	go svc.ScheduledTransfer.RunWorker(context.Background(), config.GetScheduledTransferConfig().Interval)
+
+	router := routes.SetupRoutes(db, svc)
 	port := getEnv("PORT", "8080")
 
 	// Serve static files
@@ -38,3 +82,18 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func loadExchangeRates(fx *services.FXService, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	imported, err := fx.ImportRatesCSV(services.SystemActor, file, "file:"+path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %d exchange rates from %s", imported, path)
+	return nil
+}
@@ -1,16 +1,47 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
 	"bank-ledger-core/models"
+	"bank-ledger-core/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+// AuthMiddleware accepts either an API key in the Authorization header
+// ("Bearer blk_...") or the session_id cookie. A request carrying an invalid
+// API key is rejected even if it also has a valid session. Each use of a
+// session extends its idle expiry.
+func AuthMiddleware(db *gorm.DB, apiKeys *services.APIKeyService, sessions *services.SessionService) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if token, ok := bearerToken(c); ok {
+			key, err := apiKeys.Authenticate(token, c.ClientIP())
+			if err != nil {
+				message := "Invalid API key"
+				if !errors.Is(err, services.ErrInvalidAPIKey) {
+					message = "Failed to verify API key"
+				}
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"success": false,
+					"message": message,
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", key.UserID)
+			c.Set("role", userRole(db, key.UserID))
+			c.Set("scopes", key.Scopes)
+			c.Set("api_key_id", key.KeyID)
+			c.Next()
+			return
+		}
+
 		sessionID, err := c.Cookie("session_id")
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -21,32 +52,64 @@ func AuthMiddleware(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var session models.Session
-		if err := db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		session, err := sessions.Authenticate(sessionID, c.ClientIP())
+		if err != nil {
+			message := "Failed to verify session"
+			switch {
+			case errors.Is(err, services.ErrSessionNotFound):
+				message = "Invalid session"
+			case errors.Is(err, services.ErrSessionExpired):
+				message = "Session expired"
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
-				"message": "Invalid session",
+				"message": message,
 			})
 			c.Abort()
 			return
 		}
 
-		if session.IsExpired() {
-			db.Where("id = ?", sessionID).Delete(&models.Session{})
+		// A partial session only lets the user finish two-factor login
+		if session.MFAPending {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
-				"message": "Session expired",
+				"message": "Two-factor verification required",
 			})
 			c.Abort()
 			return
 		}
 
-		// Set user_id in context
+		// Set user_id, role and session_id in context
 		c.Set("user_id", session.UserID)
+		c.Set("role", userRole(db, session.UserID))
+		c.Set("session_id", session.ID)
+		if session.StepUpAt != nil {
+			c.Set("step_up_at", *session.StepUpAt)
+		}
 		c.Next()
 	}
 }
 
+func userRole(db *gorm.DB, userID string) models.Role {
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err == nil && user.Role != "" {
+		return user.Role
+	}
+	return models.RoleCustomer
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return "", false
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return header, true
+	}
+	return strings.TrimSpace(token), true
+}
+
 func GetUserID(c *gin.Context) string {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -54,3 +117,39 @@ func GetUserID(c *gin.Context) string {
 	}
 	return userID.(string)
 }
+
+func GetSessionID(c *gin.Context) string {
+	return c.GetString("session_id")
+}
+
+// GetStepUpAt returns when the session last passed a two-factor check, or nil.
+func GetStepUpAt(c *gin.Context) *time.Time {
+	stepUpAt, exists := c.Get("step_up_at")
+	if !exists {
+		return nil
+	}
+	t := stepUpAt.(time.Time)
+	return &t
+}
+
+// GetScopes returns the API key's scopes, or nil for session requests, which
+// are limited by the role alone.
+func GetScopes(c *gin.Context) models.PermissionList {
+	scopes, exists := c.Get("scopes")
+	if !exists {
+		return nil
+	}
+	return scopes.(models.PermissionList)
+}
+
+func GetAPIKeyID(c *gin.Context) string {
+	return c.GetString("api_key_id")
+}
+
+func GetRole(c *gin.Context) models.Role {
+	role, exists := c.Get("role")
+	if !exists {
+		return ""
+	}
+	return role.(models.Role)
+}
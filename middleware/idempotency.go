@@ -0,0 +1,240 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"bank-ledger-core/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const IdempotencyHeader = "Idempotency-Key"
+
+var (
+	// IdempotencyKeyTTL is how long a completed response can be replayed.
+	IdempotencyKeyTTL = 24 * time.Hour
+	// IdempotencyWaitTimeout bounds how long a duplicate request waits for
+	// the original one to finish before giving up with 409.
+	IdempotencyWaitTimeout = 30 * time.Second
+	// IdempotencyStaleAfter releases keys whose original request never
+	// finished, e.g. because the process crashed mid-request.
+	IdempotencyStaleAfter = 5 * time.Minute
+	// IdempotencyMaxBodyBytes bounds the request body that is read into
+	// memory to fingerprint it.
+	IdempotencyMaxBodyBytes int64 = 1 << 20
+
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// Idempotency makes a mutating endpoint safe to retry. The first request with
+// a given Idempotency-Key is executed and its response stored; later requests
+// with the same key and payload receive the stored response, requests that
+// arrive while the first is still running wait for it, and requests that
+// reuse the key with a different payload are rejected with 422. Requests
+// without the header are passed through unchanged.
+func Idempotency(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, IdempotencyMaxBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit),
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Failed to read request body",
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := models.IdempotencyKey{
+			UserID:      GetUserID(c),
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			Fingerprint: fingerprint(c.Request.Method, c.Request.URL.Path, body),
+			Status:      models.IdempotencyStatusInProgress,
+			ExpiresAt:   time.Now().Add(IdempotencyKeyTTL),
+		}
+
+		claimed, existing, err := claimIdempotencyKey(db, &record)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to process idempotency key",
+			})
+			return
+		}
+
+		if !claimed {
+			replayIdempotentResponse(c, db, &record, existing)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError || recorder.Status() == http.StatusConflict {
+			// Let the client retry server-side failures and lost races with
+			// the same key
+			if err := db.Delete(&record).Error; err != nil {
+				log.Printf("Failed to release idempotency key %q of %s: %v", key, record.UserID, err)
+			}
+			return
+		}
+
+		if err := storeIdempotentResponse(db, &record, recorder.Status(), recorder.body.String()); err != nil {
+			// The response is already on its way. The key stays in progress,
+			// so duplicates get 409 rather than a second execution until it
+			// goes stale.
+			log.Printf("Failed to store the response for idempotency key %q of %s: %v", key, record.UserID, err)
+		}
+	}
+}
+
+// storeIdempotentResponse marks the claimed key completed with the response,
+// retrying a failed write a few times since the request cannot be undone.
+func storeIdempotentResponse(db *gorm.DB, record *models.IdempotencyKey, code int, body string) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		result := db.Model(record).Updates(map[string]interface{}{
+			"status":        models.IdempotencyStatusCompleted,
+			"response_code": code,
+			"response_body": body,
+		})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return errors.New("the key was released as abandoned before the request finished")
+		}
+		if err = result.Error; err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// claimIdempotencyKey inserts record unless the key is already taken, in
+// which case the existing row is returned. Expired and abandoned rows are
+// removed and the insert retried.
+func claimIdempotencyKey(db *gorm.DB, record *models.IdempotencyKey) (bool, *models.IdempotencyKey, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := db.Create(record).Error; err == nil {
+			return true, nil, nil
+		}
+
+		var existing models.IdempotencyKey
+		err := db.Where("user_id = ? AND idempotency_key = ?", record.UserID, record.Key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, nil, err
+		}
+
+		now := time.Now()
+		abandoned := existing.Status == models.IdempotencyStatusInProgress && now.Sub(existing.UpdatedAt) > IdempotencyStaleAfter
+		if now.After(existing.ExpiresAt) || abandoned {
+			if err := db.Delete(&existing).Error; err != nil {
+				return false, nil, err
+			}
+			continue
+		}
+		return false, &existing, nil
+	}
+	return false, nil, errors.New("idempotency key is being claimed concurrently")
+}
+
+func replayIdempotentResponse(c *gin.Context, db *gorm.DB, record, existing *models.IdempotencyKey) {
+	if existing.Fingerprint != record.Fingerprint {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Idempotency-Key was already used with a different request",
+		})
+		return
+	}
+
+	deadline := time.Now().Add(IdempotencyWaitTimeout)
+	for existing.Status == models.IdempotencyStatusInProgress {
+		if time.Now().After(deadline) {
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "A request with this Idempotency-Key is still being processed",
+			})
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			c.Abort()
+			return
+		case <-time.After(idempotencyPollInterval):
+		}
+
+		err := db.First(existing, existing.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The original request failed and released the key
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "The original request with this Idempotency-Key failed; retry it",
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to process idempotency key",
+			})
+			return
+		}
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(existing.ResponseCode, "application/json; charset=utf-8", []byte(existing.ResponseBody))
+	c.Abort()
+}
+
+func fingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder copies everything written to the client so that it can
+// be stored alongside the idempotency key.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
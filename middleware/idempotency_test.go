@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newIdempotencyRouter(t *testing.T, calls *int32, delay time.Duration) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.IdempotencyKey{}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("user_id", "alice") })
+	r.POST("/transfers", Idempotency(db), func(c *gin.Context) {
+		n := atomic.AddInt32(calls, 1)
+		time.Sleep(delay)
+		c.JSON(http.StatusOK, gin.H{"transfer_id": n})
+	})
+	return r
+}
+
+func post(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/transfers", strings.NewReader(body))
+	req.Header.Set(IdempotencyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	var calls int32
+	r := newIdempotencyRouter(t, &calls, 0)
+
+	first := post(r, "key-1", `{"amount":"10.00"}`)
+	second := post(r, "key-1", `{"amount":"10.00"}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("replayed response is not marked")
+	}
+}
+
+func TestIdempotencyRejectsDifferentPayload(t *testing.T) {
+	var calls int32
+	r := newIdempotencyRouter(t, &calls, 0)
+
+	post(r, "key-1", `{"amount":"10.00"}`)
+	w := post(r, "key-1", `{"amount":"99.00"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", w.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyRejectsOversizedBody(t *testing.T) {
+	var calls int32
+	r := newIdempotencyRouter(t, &calls, 0)
+
+	w := post(r, "key-1", `{"memo":"`+strings.Repeat("x", int(IdempotencyMaxBodyBytes))+`"}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", w.Code)
+	}
+	if calls != 0 {
+		t.Fatalf("handler ran %d times, want 0", calls)
+	}
+}
+
+func TestIdempotencyConcurrentDuplicatesRunOnce(t *testing.T) {
+	var calls int32
+	r := newIdempotencyRouter(t, &calls, 100*time.Millisecond)
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := post(r, "key-1", `{"amount":"10.00"}`)
+			bodies[i] = w.Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	for _, body := range bodies {
+		if body != bodies[0] {
+			t.Fatalf("responses differ: %q vs %q", body, bodies[0])
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"bank-ledger-core/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission rejects the request with 403 unless the role set by
+// AuthMiddleware grants every listed permission and, for API keys, the key
+// was scoped to it.
+func RequirePermission(permissions ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := GetRole(c)
+		scopes := GetScopes(c)
+		for _, permission := range permissions {
+			if !role.Can(permission) || (scopes != nil && !scopes.Contains(permission)) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":      "Forbidden",
+					"details":    "missing permission " + string(permission),
+					"permission": permission,
+				})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
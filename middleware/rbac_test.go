@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"bank-ledger-core/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	readOnly := models.PermissionList{models.PermissionHistoryRead}
+
+	tests := []struct {
+		role       models.Role
+		scopes     models.PermissionList
+		permission models.Permission
+		want       int
+	}{
+		{models.RoleCustomer, nil, models.PermissionTransfersWrite, http.StatusOK},
+		{models.RoleCustomer, nil, models.PermissionProductsWrite, http.StatusForbidden},
+		{models.RoleMerchant, nil, models.PermissionProductsWrite, http.StatusOK},
+		{models.RoleMerchant, nil, models.PermissionAccountsList, http.StatusForbidden},
+		{models.RoleOperator, nil, models.PermissionAccountsCreate, http.StatusOK},
+		{models.RoleOperator, nil, models.PermissionRolesAssign, http.StatusForbidden},
+		{models.RoleAdmin, nil, models.PermissionRolesAssign, http.StatusOK},
+		{"", nil, models.PermissionAccountsRead, http.StatusForbidden},
+		// API keys are limited to their scopes
+		{models.RoleCustomer, readOnly, models.PermissionHistoryRead, http.StatusOK},
+		{models.RoleCustomer, readOnly, models.PermissionTransfersWrite, http.StatusForbidden},
+		{models.RoleAdmin, readOnly, models.PermissionRolesAssign, http.StatusForbidden},
+		{models.RoleCustomer, models.PermissionList{models.PermissionProductsWrite}, models.PermissionProductsWrite, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if tt.role != "" {
+				c.Set("role", tt.role)
+			}
+			if tt.scopes != nil {
+				c.Set("scopes", tt.scopes)
+			}
+		})
+		r.GET("/", RequirePermission(tt.permission), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != tt.want {
+			t.Errorf("role %q scopes %v with %s = %d, want %d", tt.role, tt.scopes, tt.permission, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package migrations
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Before migrations existed every release ran AutoMigrate, so a database may
+// hold the 0001 tables as any earlier release left them. CREATE TABLE IF NOT
+// EXISTS leaves such tables alone, so the migrator adopts them around the
+// initial migration: the columns they lack are added before the script runs,
+// and the credentials older releases kept on accounts are moved to users
+// after it.
+
+// initialVersion is the migration that describes the AutoMigrate schema.
+const initialVersion = 1
+
+var (
+	createTablePattern = regexp.MustCompile("(?s)CREATE TABLE (?:IF NOT EXISTS )?[`\"](\\w+)[`\"] \\((.*?)\\n\\);")
+	columnPattern      = regexp.MustCompile("^[`\"](\\w+)[`\"] ")
+)
+
+// tableColumn is one column definition of a CREATE TABLE statement.
+type tableColumn struct {
+	table      string
+	column     string
+	definition string
+}
+
+// scriptColumns lists the column definitions of every CREATE TABLE in the
+// script. The scripts keep one column per line, which is all this relies on.
+func scriptColumns(script string) []tableColumn {
+	var columns []tableColumn
+	for _, match := range createTablePattern.FindAllStringSubmatch(script, -1) {
+		for _, line := range strings.Split(match[2], "\n") {
+			line = strings.TrimSuffix(strings.TrimSpace(line), ",")
+			column := columnPattern.FindStringSubmatch(line)
+			if column == nil {
+				continue
+			}
+			columns = append(columns, tableColumn{table: match[1], column: column[1], definition: line})
+		}
+	}
+	return columns
+}
+
+// addMissingColumns adds the columns of the script's tables that existing
+// tables lack. Releases before migrations only ever added columns with a
+// default or without NOT NULL, so each can be added in place.
+func addMissingColumns(tx *gorm.DB, script string) error {
+	migrator := tx.Migrator()
+	for _, column := range scriptColumns(script) {
+		if !migrator.HasTable(column.table) || migrator.HasColumn(column.table, column.column) {
+			continue
+		}
+		if err := tx.Exec("ALTER TABLE " + column.table + " ADD COLUMN " + column.definition).Error; err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", column.table, column.column, err)
+		}
+	}
+	return nil
+}
+
+// moveAccountCredentials copies the password and role that releases before
+// users existed kept on every account into users, taking them from each
+// user's first account, and drops the old columns. The ledger's own users
+// are seeded by a later migration and never logged in.
+func moveAccountCredentials(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+	if !migrator.HasColumn("accounts", "password_hash") {
+		return nil
+	}
+
+	role := "'customer'"
+	if migrator.HasColumn("accounts", "role") {
+		role = "a.role"
+	}
+	err := tx.Exec(`INSERT INTO users (id, password_hash, role, created_at, updated_at)
+		SELECT a.user_id, a.password_hash, ` + role + `, a.created_at, a.created_at FROM accounts a
+		WHERE a.id IN (SELECT MIN(id) FROM accounts GROUP BY user_id)
+		AND a.user_id <> '0' AND a.user_id NOT LIKE 'system:%'
+		AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = a.user_id)`).Error
+	if err != nil {
+		return fmt.Errorf("failed to copy users: %w", err)
+	}
+	for _, column := range []string{"password_hash", "role"} {
+		if !migrator.HasColumn("accounts", column) {
+			continue
+		}
+		if err := tx.Exec("ALTER TABLE accounts DROP COLUMN " + column).Error; err != nil {
+			return fmt.Errorf("failed to drop accounts.%s: %w", column, err)
+		}
+	}
+	return nil
+}
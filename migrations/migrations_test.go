@@ -0,0 +1,419 @@
+package migrations
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// baselineModels is what AutoMigrate built before migrations existed; 0001
+// adopts such a schema in place.
+var baselineModels = []interface{}{
+	&models.Session{},
+	&models.User{},
+	&models.Account{},
+	&baselineProduct{},
+	&models.Order{},
+	&models.Transfer{},
+	&models.JournalEntry{},
+	&models.Posting{},
+	&models.IdempotencyKey{},
+	&models.ExchangeRate{},
+	&models.FXQuote{},
+	&models.Hold{},
+	&models.AuditEvent{},
+	&models.AuditHead{},
+	&models.APIKey{},
+	&models.TOTPEnrollment{},
+	&models.RecoveryCode{},
+	&models.LoginThrottle{},
+	&models.PasswordResetToken{},
+}
+
+// schemaModels lists every model with a table, so that a model change
+// without a migration fails TestMatchesAutoMigrate.
+var schemaModels = append(append([]interface{}{}, baselineModels...),
+	&models.Product{},
+	&models.ReconciliationReport{},
+	&models.PeriodClose{},
+	&models.DailyBalance{},
+	&models.PeriodLock{},
+	&models.ScheduledTransfer{},
+)
+
+// baselineProduct is Product as AutoMigrate built it, before products had a
+// merchant.
+type baselineProduct struct {
+	ID          uint         `gorm:"primaryKey"`
+	Name        string       `gorm:"not null;size:255"`
+	Description string       `gorm:"type:text"`
+	Price       money.Amount `gorm:"type:decimal(15,2);not null"`
+	Stock       int          `gorm:"not null;default:0"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineProduct) TableName() string { return "products" }
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ledger.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// schema describes the tables and indexes of a SQLite database, one entry
+// per column, so that equivalent DDL written differently compares equal.
+func schema(t *testing.T, db *gorm.DB) map[string]bool {
+	t.Helper()
+
+	var rows []struct {
+		Object string
+		Column string
+		Type   string
+		Detail string
+	}
+	err := db.Raw(`SELECT m.name AS object, c.name AS "column", c.type AS type, c."notnull" || '/' || c.pk AS detail
+		FROM sqlite_master m JOIN pragma_table_info(m.name) c
+		WHERE m.type = 'table' AND m.name NOT IN ('schema_migrations', 'sqlite_sequence')
+		UNION ALL
+		SELECT m.tbl_name || '.' || m.name, c.name, '', c.seqno
+		FROM sqlite_master m JOIN pragma_index_info(m.name) c
+		WHERE m.type = 'index' AND m.tbl_name <> 'schema_migrations'`).Scan(&rows).Error
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	objects := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		objects[row.Object+" "+row.Column+" "+row.Type+" "+row.Detail] = true
+	}
+	return objects
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := newTestDB(t)
+	migrator, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	applied, err := migrator.Up(0)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(applied) == 0 || applied[len(applied)-1].Version != migrator.Latest() {
+		t.Fatalf("applied = %+v, want up to %d", applied, migrator.Latest())
+	}
+	if !db.Migrator().HasTable(&models.Account{}) {
+		t.Fatal("accounts table was not created")
+	}
+	if again, err := migrator.Up(0); err != nil || len(again) != 0 {
+		t.Fatalf("second up = %d migrations, %v", len(again), err)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil || status.Modified {
+			t.Fatalf("status = %+v", status)
+		}
+	}
+
+	rolledBack, err := migrator.Down(len(applied))
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(rolledBack) != len(applied) || rolledBack[0].Version != migrator.Latest() {
+		t.Fatalf("rolled back = %+v", rolledBack)
+	}
+	if left := schema(t, db); len(left) != 0 {
+		t.Fatalf("objects left after rolling everything back: %v", left)
+	}
+	if pending, err := migrator.Pending(); err != nil || len(pending) != len(applied) {
+		t.Fatalf("pending after down = %d, %v", len(pending), err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("up after down: %v", err)
+	}
+}
+
+// A database created by AutoMigrate is adopted without changes, and the
+// scripts describe exactly what AutoMigrate would build from the models.
+func TestMatchesAutoMigrate(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(baselineModels...); err != nil {
+		t.Fatalf("automigrate: %v", err)
+	}
+	want := schema(t, db)
+
+	migrator, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("up on an AutoMigrate schema: %v", err)
+	}
+
+	fresh := newTestDB(t)
+	migrator, _ = New(fresh, "sqlite")
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	scripted := schema(t, fresh)
+	if err := fresh.AutoMigrate(schemaModels...); err != nil {
+		t.Fatalf("automigrate after up: %v", err)
+	}
+	for column := range schema(t, fresh) {
+		if !scripted[column] {
+			t.Errorf("models have %s, migrations do not", column)
+		}
+	}
+	for column := range want {
+		if !scripted[column] {
+			t.Errorf("AutoMigrate builds %s, migrations do not", column)
+		}
+	}
+}
+
+// The models of the first release, whose AutoMigrate schema lacks most
+// columns of 0001 and keeps credentials on accounts.
+type (
+	firstAccount struct {
+		ID           uint   `gorm:"primaryKey"`
+		UserID       string `gorm:"not null;index"`
+		PasswordHash string `gorm:"not null"`
+		Currency     string `gorm:"not null;size:3"`
+		Balance      string `gorm:"type:decimal(15,2);not null;default:0.00"`
+		CreatedAt    time.Time
+		UpdatedAt    time.Time
+		DeletedAt    gorm.DeletedAt `gorm:"index"`
+	}
+	firstProduct struct {
+		ID          uint   `gorm:"primaryKey"`
+		Name        string `gorm:"not null;size:255"`
+		Description string `gorm:"type:text"`
+		Price       string `gorm:"type:decimal(15,2);not null"`
+		Stock       int    `gorm:"not null;default:0"`
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+		DeletedAt   gorm.DeletedAt `gorm:"index"`
+	}
+	firstOrder struct {
+		ID        uint   `gorm:"primaryKey"`
+		UserID    string `gorm:"not null;index"`
+		ProductID uint   `gorm:"not null;index"`
+		Amount    string `gorm:"type:decimal(15,2);not null"`
+		Quantity  int    `gorm:"not null"`
+		Status    string `gorm:"type:varchar(20);not null;default:pending"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		DeletedAt gorm.DeletedAt `gorm:"index"`
+		Product   firstProduct   `gorm:"foreignKey:ProductID"`
+	}
+	firstSession struct {
+		ID        string    `gorm:"primaryKey"`
+		UserID    string    `gorm:"not null;index"`
+		ExpiresAt time.Time `gorm:"not null"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	firstTransfer struct {
+		ID            uint   `gorm:"primaryKey"`
+		FromAccountID uint   `gorm:"not null;index"`
+		ToAccountID   uint   `gorm:"not null;index"`
+		Amount        string `gorm:"type:decimal(15,2);not null"`
+		Status        string `gorm:"type:varchar(20);not null;default:pending"`
+		CreatedAt     time.Time
+		UpdatedAt     time.Time
+		DeletedAt     gorm.DeletedAt `gorm:"index"`
+		FromAccount   firstAccount   `gorm:"foreignKey:FromAccountID"`
+		ToAccount     firstAccount   `gorm:"foreignKey:ToAccountID"`
+	}
+)
+
+func (firstAccount) TableName() string  { return "accounts" }
+func (firstProduct) TableName() string  { return "products" }
+func (firstOrder) TableName() string    { return "orders" }
+func (firstSession) TableName() string  { return "sessions" }
+func (firstTransfer) TableName() string { return "transfers" }
+
+// A database from the first release is brought up to the same schema as a
+// fresh one, keeping its data and moving passwords to users.
+func TestUpgradesFirstReleaseSchema(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&firstSession{}, &firstAccount{}, &firstProduct{}, &firstOrder{}, &firstTransfer{}); err != nil {
+		t.Fatalf("automigrate: %v", err)
+	}
+	accounts := []firstAccount{
+		{UserID: "0", PasswordHash: "system", Currency: "UZS", Balance: "0.00"},
+		{UserID: "alice", PasswordHash: "alice-hash", Currency: "UZS", Balance: "100000.00"},
+		{UserID: "alice", PasswordHash: "other-hash", Currency: "USD", Balance: "5.00"},
+	}
+	if err := db.Create(&accounts).Error; err != nil {
+		t.Fatalf("create accounts: %v", err)
+	}
+
+	migrator, err := New(db, "sqlite")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("up on a first-release schema: %v", err)
+	}
+
+	fresh := newTestDB(t)
+	migrator, _ = New(fresh, "sqlite")
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	upgraded, want := schema(t, db), schema(t, fresh)
+	for column := range want {
+		if !upgraded[column] {
+			t.Errorf("upgraded database lacks %s", column)
+		}
+	}
+	for column := range upgraded {
+		if !want[column] {
+			t.Errorf("upgraded database has %s, a fresh one does not", column)
+		}
+	}
+
+	var users []models.User
+	db.Where("id = ?", "alice").Find(&users)
+	if len(users) != 1 || users[0].PasswordHash != "alice-hash" || users[0].Role != models.RoleCustomer {
+		t.Fatalf("users = %+v", users)
+	}
+	var account models.Account
+	if err := db.First(&account, accounts[1].ID).Error; err != nil {
+		t.Fatalf("load account: %v", err)
+	}
+	if account.Balance.String() != "100000.00" || account.Status != models.AccountStatusActive || account.Type != models.AccountTypeCurrent {
+		t.Fatalf("account = %+v", account)
+	}
+	if err := db.Create(&models.Account{UserID: "alice", Currency: "EUR"}).Error; err != nil {
+		t.Fatalf("create account after upgrade: %v", err)
+	}
+}
+
+// Releases between roles and users kept a role on every account too; the
+// first account of each user wins.
+func TestMovesAccountRoles(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(baselineModels...); err != nil {
+		t.Fatalf("automigrate: %v", err)
+	}
+	for _, column := range []string{
+		"`password_hash` text NOT NULL DEFAULT ''",
+		"`role` varchar(20) NOT NULL DEFAULT 'customer'",
+	} {
+		if err := db.Exec("ALTER TABLE accounts ADD COLUMN " + column).Error; err != nil {
+			t.Fatalf("add column: %v", err)
+		}
+	}
+	for _, account := range []struct{ user, hash, role string }{
+		{"alice", "first", "merchant"},
+		{"alice", "other", "customer"},
+		{"bob", "bob-hash", "operator"},
+	} {
+		err := db.Exec("INSERT INTO accounts (user_id, currency, password_hash, role) VALUES (?, 'UZS', ?, ?)", account.user, account.hash, account.role).Error
+		if err != nil {
+			t.Fatalf("insert account: %v", err)
+		}
+	}
+
+	migrator, _ := New(db, "sqlite")
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	var users []models.User
+	db.Where("id IN ?", []string{"alice", "bob"}).Order("id").Find(&users)
+	if len(users) != 2 || users[0].PasswordHash != "first" || users[0].Role != models.RoleMerchant || users[1].Role != models.RoleOperator {
+		t.Fatalf("users = %+v", users)
+	}
+	if db.Migrator().HasColumn(&models.Account{}, "password_hash") || db.Migrator().HasColumn(&models.Account{}, "role") {
+		t.Fatal("old account columns were not dropped")
+	}
+}
+
+func TestVerifyRejectsChangedAndUnknownMigrations(t *testing.T) {
+	scripts := fstest.MapFS{
+		"sqlite/0001_widgets.up.sql":   {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+		"sqlite/0001_widgets.down.sql": {Data: []byte("DROP TABLE widgets;")},
+		"sqlite/0002_gadgets.up.sql":   {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY);")},
+	}
+	loaded, err := load(scripts, "sqlite")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	db := newTestDB(t)
+	migrator := &Migrator{db: db, dialect: "sqlite", migrations: loaded}
+
+	if applied, err := migrator.Up(1); err != nil || len(applied) != 1 {
+		t.Fatalf("up to 1 = %d, %v", len(applied), err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if _, err := migrator.Down(1); !errors.Is(err, ErrNoDownScript) {
+		t.Fatalf("down without a script = %v, want ErrNoDownScript", err)
+	}
+
+	scripts["sqlite/0001_widgets.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE widgets (id INTEGER);")}
+	changed, err := load(scripts, "sqlite")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	migrator.migrations = changed
+	if _, err := migrator.Pending(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("pending with a changed script = %v, want ErrChecksumMismatch", err)
+	}
+	statuses, err := migrator.Status()
+	if err != nil || !statuses[0].Modified || statuses[1].Modified {
+		t.Fatalf("status = %+v, %v", statuses, err)
+	}
+
+	migrator.migrations = loaded[:1]
+	if err := migrator.Verify(); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("verify with an unknown applied version = %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestLoadRejectsBadNames(t *testing.T) {
+	if _, err := New(nil, "mysql"); !errors.Is(err, ErrUnknownDialect) {
+		t.Fatalf("unknown dialect = %v, want ErrUnknownDialect", err)
+	}
+	for _, scripts := range []fstest.MapFS{
+		{"sqlite/initial.up.sql": {Data: []byte("SELECT 1;")}},
+		{"sqlite/0001_a.down.sql": {Data: []byte("SELECT 1;")}},
+		{
+			"sqlite/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+			"sqlite/0001_b.up.sql": {Data: []byte("SELECT 1;")},
+		},
+	} {
+		if _, err := load(scripts, "sqlite"); err == nil {
+			t.Fatalf("load(%v) succeeded", scripts)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+DROP TABLE IF EXISTS "password_reset_tokens";
+DROP TABLE IF EXISTS "login_throttles";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "totp_enrollments";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "audit_heads";
+DROP TABLE IF EXISTS "audit_events";
+DROP TABLE IF EXISTS "holds";
+DROP TABLE IF EXISTS "fx_quotes";
+DROP TABLE IF EXISTS "exchange_rates";
+DROP TABLE IF EXISTS "idempotency_keys";
+DROP TABLE IF EXISTS "postings";
+DROP TABLE IF EXISTS "journal_entries";
+DROP TABLE IF EXISTS "transfers";
+DROP TABLE IF EXISTS "orders";
+DROP TABLE IF EXISTS "products";
+DROP TABLE IF EXISTS "accounts";
+DROP TABLE IF EXISTS "users";
+DROP TABLE IF EXISTS "sessions";
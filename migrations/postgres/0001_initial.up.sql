@@ -0,0 +1,305 @@
+-- Schema as it stood when AutoMigrate was retired. Every object is created
+-- only if missing; tables an older release built with fewer columns are
+-- brought up to these definitions by the migrator (see adopt.go).
+
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" text,
+    "user_id" text NOT NULL,
+    "mfa_pending" boolean NOT NULL DEFAULT false,
+    "mfa_attempts" bigint NOT NULL DEFAULT 0,
+    "step_up_at" timestamptz,
+    "user_agent" varchar(512),
+    "ip" varchar(64),
+    "last_seen_at" timestamptz,
+    "expires_at" timestamptz NOT NULL,
+    "absolute_expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_sessions_absolute_expires_at" ON "sessions" ("absolute_expires_at");
+CREATE INDEX IF NOT EXISTS "idx_sessions_expires_at" ON "sessions" ("expires_at");
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" varchar(255),
+    "password_hash" text NOT NULL,
+    "role" varchar(20) NOT NULL DEFAULT 'customer',
+    "display_name" varchar(255),
+    "email" varchar(255),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "accounts" (
+    "id" bigserial,
+    "user_id" text NOT NULL,
+    "type" varchar(20) NOT NULL DEFAULT 'current',
+    "name" varchar(100),
+    "currency" varchar(3) NOT NULL,
+    "balance" decimal(15,2) NOT NULL DEFAULT '0.00',
+    "held_balance" decimal(15,2) NOT NULL DEFAULT '0.00',
+    "version" bigint NOT NULL DEFAULT 0,
+    "status" varchar(20) NOT NULL DEFAULT 'active',
+    "status_reason" varchar(255),
+    "status_changed_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_accounts_status" ON "accounts" ("status");
+CREATE INDEX IF NOT EXISTS "idx_accounts_user_id" ON "accounts" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_accounts_deleted_at" ON "accounts" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "products" (
+    "id" bigserial,
+    "name" varchar(255) NOT NULL,
+    "description" text,
+    "price" decimal(15,2) NOT NULL,
+    "stock" bigint NOT NULL DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_products_deleted_at" ON "products" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "orders" (
+    "id" bigserial,
+    "user_id" text NOT NULL,
+    "account_id" bigint,
+    "product_id" bigint NOT NULL,
+    "amount" decimal(15,2) NOT NULL,
+    "currency" varchar(3),
+    "quantity" bigint NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "transfer_id" bigint,
+    "refunded_amount" decimal(15,2) NOT NULL DEFAULT '0',
+    "refunded_quantity" bigint NOT NULL DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_orders_product" FOREIGN KEY ("product_id") REFERENCES "products"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_orders_deleted_at" ON "orders" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_orders_transfer_id" ON "orders" ("transfer_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_product_id" ON "orders" ("product_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_account_id" ON "orders" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_user_id" ON "orders" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "transfers" (
+    "id" bigserial,
+    "from_account_id" bigint NOT NULL,
+    "to_account_id" bigint NOT NULL,
+    "amount" decimal(15,2) NOT NULL,
+    "currency" varchar(3),
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "reversal_of_id" bigint,
+    "destination_amount" decimal(15,2),
+    "destination_currency" varchar(3),
+    "fx_rate" decimal(24,10),
+    "fx_spread_bps" bigint,
+    "fx_quote_id" varchar(36),
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_transfers_to_account" FOREIGN KEY ("to_account_id") REFERENCES "accounts"("id"),
+    CONSTRAINT "fk_transfers_from_account" FOREIGN KEY ("from_account_id") REFERENCES "accounts"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_transfers_from_account_id" ON "transfers" ("from_account_id");
+CREATE INDEX IF NOT EXISTS "idx_transfers_reversal_of_id" ON "transfers" ("reversal_of_id");
+CREATE INDEX IF NOT EXISTS "idx_transfers_deleted_at" ON "transfers" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_transfers_to_account_id" ON "transfers" ("to_account_id");
+
+CREATE TABLE IF NOT EXISTS "journal_entries" (
+    "id" bigserial,
+    "type" varchar(32) NOT NULL,
+    "transfer_id" bigint,
+    "description" varchar(255),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_journal_entries_transfer_id" ON "journal_entries" ("transfer_id");
+CREATE INDEX IF NOT EXISTS "idx_journal_entries_type" ON "journal_entries" ("type");
+
+CREATE TABLE IF NOT EXISTS "postings" (
+    "id" bigserial,
+    "entry_id" bigint NOT NULL,
+    "account_id" bigint NOT NULL,
+    "direction" varchar(6) NOT NULL,
+    "amount" decimal(15,2) NOT NULL,
+    "currency" varchar(3) NOT NULL,
+    "balance_after" decimal(15,2),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_journal_entries_postings" FOREIGN KEY ("entry_id") REFERENCES "journal_entries"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_postings_account_id" ON "postings" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_postings_entry_id" ON "postings" ("entry_id");
+
+CREATE TABLE IF NOT EXISTS "idempotency_keys" (
+    "id" bigserial,
+    "user_id" varchar(255) NOT NULL,
+    "idempotency_key" varchar(255) NOT NULL,
+    "method" varchar(10) NOT NULL,
+    "path" varchar(255) NOT NULL,
+    "fingerprint" varchar(64) NOT NULL,
+    "status" varchar(20) NOT NULL,
+    "response_code" bigint,
+    "response_body" text,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idempotency_user_key" ON "idempotency_keys" ("user_id","idempotency_key");
+
+CREATE TABLE IF NOT EXISTS "exchange_rates" (
+    "id" bigserial,
+    "base_currency" varchar(3) NOT NULL,
+    "quote_currency" varchar(3) NOT NULL,
+    "rate" decimal(24,10) NOT NULL,
+    "effective_date" timestamptz NOT NULL,
+    "source" varchar(50),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_exchange_rate_pair" ON "exchange_rates" ("base_currency","quote_currency","effective_date");
+
+CREATE TABLE IF NOT EXISTS "fx_quotes" (
+    "id" varchar(36),
+    "user_id" text NOT NULL,
+    "from_currency" varchar(3) NOT NULL,
+    "to_currency" varchar(3) NOT NULL,
+    "source_amount" decimal(15,2) NOT NULL,
+    "target_amount" decimal(15,2) NOT NULL,
+    "mid_rate" decimal(24,10) NOT NULL,
+    "rate" decimal(24,10) NOT NULL,
+    "spread_bps" bigint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "transfer_id" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_fx_quotes_user_id" ON "fx_quotes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "holds" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "to_account_id" bigint NOT NULL,
+    "transfer_id" bigint NOT NULL,
+    "amount" decimal(15,2) NOT NULL,
+    "captured_amount" decimal(15,2) NOT NULL DEFAULT '0',
+    "currency" varchar(3) NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "expires_at" timestamptz NOT NULL,
+    "released_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_holds_status" ON "holds" ("status");
+CREATE INDEX IF NOT EXISTS "idx_holds_transfer_id" ON "holds" ("transfer_id");
+CREATE INDEX IF NOT EXISTS "idx_holds_to_account_id" ON "holds" ("to_account_id");
+CREATE INDEX IF NOT EXISTS "idx_holds_account_id" ON "holds" ("account_id");
+CREATE INDEX IF NOT EXISTS "idx_holds_expires_at" ON "holds" ("expires_at");
+
+CREATE TABLE IF NOT EXISTS "audit_events" (
+    "id" bigserial,
+    "sequence" bigint NOT NULL,
+    "occurred_at" timestamptz NOT NULL,
+    "actor_user_id" varchar(255),
+    "actor_role" varchar(20),
+    "session_id" varchar(64),
+    "ip" varchar(64),
+    "request_id" varchar(64),
+    "action" varchar(64) NOT NULL,
+    "entity_type" varchar(32),
+    "entity_id" varchar(64),
+    "before" text,
+    "after" text,
+    "prev_hash" varchar(64) NOT NULL,
+    "hash" varchar(64) NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_entity" ON "audit_events" ("entity_type","entity_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_action" ON "audit_events" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_request_id" ON "audit_events" ("request_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_actor_user_id" ON "audit_events" ("actor_user_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_occurred_at" ON "audit_events" ("occurred_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_audit_events_sequence" ON "audit_events" ("sequence");
+
+CREATE TABLE IF NOT EXISTS "audit_heads" (
+    "id" bigserial,
+    "sequence" bigint NOT NULL,
+    "hash" varchar(64) NOT NULL,
+    PRIMARY KEY ("id")
+);
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" bigserial,
+    "user_id" text NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "key_id" varchar(24) NOT NULL,
+    "secret_hash" varchar(64) NOT NULL,
+    "scopes" text NOT NULL,
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "last_used_ip" varchar(64),
+    "revoked_at" timestamptz,
+    "rotated_from_id" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_id" ON "api_keys" ("key_id");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "totp_enrollments" (
+    "user_id" varchar(255),
+    "secret" varchar(64) NOT NULL,
+    "confirmed_at" timestamptz,
+    "last_used_step" bigint NOT NULL DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id")
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bigserial,
+    "user_id" text NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_recovery_codes_code_hash" ON "recovery_codes" ("code_hash");
+
+CREATE TABLE IF NOT EXISTS "login_throttles" (
+    "key" varchar(300),
+    "failures" bigint NOT NULL DEFAULT 0,
+    "last_failed_at" timestamptz,
+    "locked_until" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+    "id" bigserial,
+    "user_id" text NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "requested_ip" varchar(64),
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_password_reset_tokens_user_id" ON "password_reset_tokens" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_password_reset_tokens_token_hash" ON "password_reset_tokens" ("token_hash");
@@ -0,0 +1,16 @@
+CREATE TABLE "reconciliation_reports" (
+    "id" bigserial,
+    "trigger" varchar(20) NOT NULL,
+    "triggered_by" varchar(255),
+    "started_at" timestamptz NOT NULL,
+    "finished_at" timestamptz NOT NULL,
+    "balanced" boolean NOT NULL,
+    "accounts_checked" bigint NOT NULL,
+    "transfers_checked" bigint NOT NULL,
+    "discrepancies" bigint NOT NULL,
+    "report" text NOT NULL,
+    "key_id" varchar(16) NOT NULL,
+    "signature" varchar(64) NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_reconciliation_reports_started_at" ON "reconciliation_reports" ("started_at");
@@ -0,0 +1,24 @@
+CREATE TABLE "period_closes" (
+    "id" bigserial,
+    "date" timestamptz NOT NULL,
+    "closed_by" varchar(255),
+    "accounts" bigint NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_period_closes_date" ON "period_closes" ("date");
+
+CREATE TABLE "daily_balances" (
+    "id" bigserial,
+    "account_id" bigint NOT NULL,
+    "date" timestamptz NOT NULL,
+    "currency" varchar(3) NOT NULL,
+    "opening_balance" decimal(15,2) NOT NULL,
+    "inflows" decimal(15,2) NOT NULL,
+    "outflows" decimal(15,2) NOT NULL,
+    "closing_balance" decimal(15,2) NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_daily_balances_date" ON "daily_balances" ("date");
+CREATE UNIQUE INDEX "idx_daily_balance_account_date" ON "daily_balances" ("account_id","date");
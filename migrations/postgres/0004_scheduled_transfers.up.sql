@@ -0,0 +1,20 @@
+CREATE TABLE "scheduled_transfers" (
+    "id" bigserial,
+    "user_id" varchar(255) NOT NULL,
+    "from_account_id" bigint NOT NULL,
+    "to_account_id" bigint NOT NULL,
+    "amount" decimal(15,2) NOT NULL,
+    "currency" varchar(3) NOT NULL,
+    "execute_at" timestamptz NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'scheduled',
+    "transfer_id" bigint,
+    "failure_reason" varchar(255),
+    "executed_at" timestamptz,
+    "cancelled_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_scheduled_transfer_due" ON "scheduled_transfers" ("status","execute_at");
+CREATE INDEX "idx_scheduled_transfers_from_account_id" ON "scheduled_transfers" ("from_account_id");
+CREATE INDEX "idx_scheduled_transfers_user_id" ON "scheduled_transfers" ("user_id");
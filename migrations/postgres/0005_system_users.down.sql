@@ -0,0 +1 @@
+DELETE FROM "users" WHERE "id" IN ('0', 'system:issuance', 'system:fx');
@@ -0,0 +1,8 @@
+-- The users that own the marketplace account and the internal contra
+-- accounts, so that nobody can register their IDs. A password hash of "!"
+-- matches no password, so they cannot log in. If one of the IDs was
+-- registered before this migration, it fails and that user needs looking at.
+INSERT INTO "users" ("id", "password_hash", "role", "display_name", "created_at", "updated_at") VALUES
+    ('0', '!', 'customer', 'Marketplace', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+    ('system:issuance', '!', 'customer', 'Issuance', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+    ('system:fx', '!', 'customer', 'FX position', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
@@ -0,0 +1,4 @@
+-- The merchant who listed a product and may refund its orders. Products
+-- listed before this migration have none and are refunded by operators.
+ALTER TABLE "products" ADD COLUMN "merchant_id" varchar(255);
+CREATE INDEX "idx_products_merchant_id" ON "products" ("merchant_id");
@@ -0,0 +1,7 @@
+-- The row closing a day and posting into it lock, so that a posting
+-- checked against the last closed day commits before that day is closed.
+CREATE TABLE "period_locks" (
+    "id" bigserial,
+    PRIMARY KEY ("id")
+);
+INSERT INTO "period_locks" ("id") VALUES (1);
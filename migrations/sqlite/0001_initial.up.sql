@@ -0,0 +1,291 @@
+-- Schema as it stood when AutoMigrate was retired. Every object is created
+-- only if missing; tables an older release built with fewer columns are
+-- brought up to these definitions by the migrator (see adopt.go).
+
+CREATE TABLE IF NOT EXISTS `sessions` (
+    `id` text,
+    `user_id` text NOT NULL,
+    `mfa_pending` numeric NOT NULL DEFAULT false,
+    `mfa_attempts` integer NOT NULL DEFAULT 0,
+    `step_up_at` datetime,
+    `user_agent` text,
+    `ip` text,
+    `last_seen_at` datetime,
+    `expires_at` datetime NOT NULL,
+    `absolute_expires_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_sessions_absolute_expires_at` ON `sessions` (`absolute_expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_sessions_expires_at` ON `sessions` (`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_sessions_user_id` ON `sessions` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` text,
+    `password_hash` text NOT NULL,
+    `role` varchar(20) NOT NULL DEFAULT 'customer',
+    `display_name` text,
+    `email` text,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `accounts` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` text NOT NULL,
+    `type` varchar(20) NOT NULL DEFAULT 'current',
+    `name` text,
+    `currency` text NOT NULL,
+    `balance` decimal(15,2) NOT NULL DEFAULT '0.00',
+    `held_balance` decimal(15,2) NOT NULL DEFAULT '0.00',
+    `version` integer NOT NULL DEFAULT 0,
+    `status` varchar(20) NOT NULL DEFAULT 'active',
+    `status_reason` text,
+    `status_changed_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_accounts_user_id` ON `accounts` (`user_id`);
+CREATE INDEX IF NOT EXISTS `idx_accounts_deleted_at` ON `accounts` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_accounts_status` ON `accounts` (`status`);
+
+CREATE TABLE IF NOT EXISTS `products` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `name` text NOT NULL,
+    `description` text,
+    `price` decimal(15,2) NOT NULL,
+    `stock` integer NOT NULL DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_products_deleted_at` ON `products` (`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `orders` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` text NOT NULL,
+    `account_id` integer,
+    `product_id` integer NOT NULL,
+    `amount` decimal(15,2) NOT NULL,
+    `currency` text,
+    `quantity` integer NOT NULL,
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `transfer_id` integer,
+    `refunded_amount` decimal(15,2) NOT NULL DEFAULT '0',
+    `refunded_quantity` integer NOT NULL DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    CONSTRAINT `fk_orders_product` FOREIGN KEY (`product_id`) REFERENCES `products`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_orders_deleted_at` ON `orders` (`deleted_at`);
+CREATE INDEX IF NOT EXISTS `idx_orders_transfer_id` ON `orders` (`transfer_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_product_id` ON `orders` (`product_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_account_id` ON `orders` (`account_id`);
+CREATE INDEX IF NOT EXISTS `idx_orders_user_id` ON `orders` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `transfers` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `from_account_id` integer NOT NULL,
+    `to_account_id` integer NOT NULL,
+    `amount` decimal(15,2) NOT NULL,
+    `currency` text,
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `created_at` datetime,
+    `updated_at` datetime,
+    `deleted_at` datetime,
+    `reversal_of_id` integer,
+    `destination_amount` decimal(15,2),
+    `destination_currency` text,
+    `fx_rate` decimal(24,10),
+    `fx_spread_bps` integer,
+    `fx_quote_id` text,
+    CONSTRAINT `fk_transfers_from_account` FOREIGN KEY (`from_account_id`) REFERENCES `accounts`(`id`),
+    CONSTRAINT `fk_transfers_to_account` FOREIGN KEY (`to_account_id`) REFERENCES `accounts`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_transfers_to_account_id` ON `transfers` (`to_account_id`);
+CREATE INDEX IF NOT EXISTS `idx_transfers_from_account_id` ON `transfers` (`from_account_id`);
+CREATE INDEX IF NOT EXISTS `idx_transfers_reversal_of_id` ON `transfers` (`reversal_of_id`);
+CREATE INDEX IF NOT EXISTS `idx_transfers_deleted_at` ON `transfers` (`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `journal_entries` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `type` varchar(32) NOT NULL,
+    `transfer_id` integer,
+    `description` text,
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_journal_entries_transfer_id` ON `journal_entries` (`transfer_id`);
+CREATE INDEX IF NOT EXISTS `idx_journal_entries_type` ON `journal_entries` (`type`);
+
+CREATE TABLE IF NOT EXISTS `postings` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `entry_id` integer NOT NULL,
+    `account_id` integer NOT NULL,
+    `direction` varchar(6) NOT NULL,
+    `amount` decimal(15,2) NOT NULL,
+    `currency` text NOT NULL,
+    `balance_after` decimal(15,2),
+    `created_at` datetime,
+    CONSTRAINT `fk_journal_entries_postings` FOREIGN KEY (`entry_id`) REFERENCES `journal_entries`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_postings_account_id` ON `postings` (`account_id`);
+CREATE INDEX IF NOT EXISTS `idx_postings_entry_id` ON `postings` (`entry_id`);
+
+CREATE TABLE IF NOT EXISTS `idempotency_keys` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` text NOT NULL,
+    `idempotency_key` text NOT NULL,
+    `method` text NOT NULL,
+    `path` text NOT NULL,
+    `fingerprint` text NOT NULL,
+    `status` varchar(20) NOT NULL,
+    `response_code` integer,
+    `response_body` text,
+    `expires_at` datetime NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_idempotency_keys_expires_at` ON `idempotency_keys` (`expires_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_idempotency_user_key` ON `idempotency_keys` (`user_id`,`idempotency_key`);
+
+CREATE TABLE IF NOT EXISTS `exchange_rates` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `base_currency` text NOT NULL,
+    `quote_currency` text NOT NULL,
+    `rate` decimal(24,10) NOT NULL,
+    `effective_date` datetime NOT NULL,
+    `source` text,
+    `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_exchange_rate_pair` ON `exchange_rates` (`base_currency`,`quote_currency`,`effective_date`);
+
+CREATE TABLE IF NOT EXISTS `fx_quotes` (
+    `id` text,
+    `user_id` text NOT NULL,
+    `from_currency` text NOT NULL,
+    `to_currency` text NOT NULL,
+    `source_amount` decimal(15,2) NOT NULL,
+    `target_amount` decimal(15,2) NOT NULL,
+    `mid_rate` decimal(24,10) NOT NULL,
+    `rate` decimal(24,10) NOT NULL,
+    `spread_bps` integer NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime,
+    `transfer_id` integer,
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_fx_quotes_user_id` ON `fx_quotes` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `holds` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `to_account_id` integer NOT NULL,
+    `transfer_id` integer NOT NULL,
+    `amount` decimal(15,2) NOT NULL,
+    `captured_amount` decimal(15,2) NOT NULL DEFAULT '0',
+    `currency` text NOT NULL,
+    `status` varchar(20) NOT NULL DEFAULT 'pending',
+    `expires_at` datetime NOT NULL,
+    `released_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_holds_account_id` ON `holds` (`account_id`);
+CREATE INDEX IF NOT EXISTS `idx_holds_expires_at` ON `holds` (`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_holds_status` ON `holds` (`status`);
+CREATE INDEX IF NOT EXISTS `idx_holds_transfer_id` ON `holds` (`transfer_id`);
+CREATE INDEX IF NOT EXISTS `idx_holds_to_account_id` ON `holds` (`to_account_id`);
+
+CREATE TABLE IF NOT EXISTS `audit_events` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `sequence` integer NOT NULL,
+    `occurred_at` datetime NOT NULL,
+    `actor_user_id` text,
+    `actor_role` varchar(20),
+    `session_id` text,
+    `ip` text,
+    `request_id` text,
+    `action` text NOT NULL,
+    `entity_type` text,
+    `entity_id` text,
+    `before` text,
+    `after` text,
+    `prev_hash` text NOT NULL,
+    `hash` text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_request_id` ON `audit_events` (`request_id`);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_actor_user_id` ON `audit_events` (`actor_user_id`);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_occurred_at` ON `audit_events` (`occurred_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_audit_events_sequence` ON `audit_events` (`sequence`);
+CREATE INDEX IF NOT EXISTS `idx_audit_entity` ON `audit_events` (`entity_type`,`entity_id`);
+CREATE INDEX IF NOT EXISTS `idx_audit_events_action` ON `audit_events` (`action`);
+
+CREATE TABLE IF NOT EXISTS `audit_heads` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `sequence` integer NOT NULL,
+    `hash` text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `api_keys` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` text NOT NULL,
+    `name` text NOT NULL,
+    `key_id` text NOT NULL,
+    `secret_hash` text NOT NULL,
+    `scopes` text NOT NULL,
+    `expires_at` datetime,
+    `last_used_at` datetime,
+    `last_used_ip` text,
+    `revoked_at` datetime,
+    `rotated_from_id` integer,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_api_keys_key_id` ON `api_keys` (`key_id`);
+CREATE INDEX IF NOT EXISTS `idx_api_keys_user_id` ON `api_keys` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `totp_enrollments` (
+    `user_id` text,
+    `secret` text NOT NULL,
+    `confirmed_at` datetime,
+    `last_used_step` integer NOT NULL DEFAULT 0,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `recovery_codes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` text NOT NULL,
+    `code_hash` text NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_recovery_codes_code_hash` ON `recovery_codes` (`code_hash`);
+CREATE INDEX IF NOT EXISTS `idx_recovery_codes_user_id` ON `recovery_codes` (`user_id`);
+
+CREATE TABLE IF NOT EXISTS `login_throttles` (
+    `key` text,
+    `failures` integer NOT NULL DEFAULT 0,
+    `last_failed_at` datetime,
+    `locked_until` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`key`)
+);
+
+CREATE TABLE IF NOT EXISTS `password_reset_tokens` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` text NOT NULL,
+    `token_hash` text NOT NULL,
+    `requested_ip` text,
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_password_reset_tokens_token_hash` ON `password_reset_tokens` (`token_hash`);
+CREATE INDEX IF NOT EXISTS `idx_password_reset_tokens_user_id` ON `password_reset_tokens` (`user_id`);
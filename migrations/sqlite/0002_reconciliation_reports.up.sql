@@ -0,0 +1,15 @@
+CREATE TABLE `reconciliation_reports` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `trigger` varchar(20) NOT NULL,
+    `triggered_by` text,
+    `started_at` datetime NOT NULL,
+    `finished_at` datetime NOT NULL,
+    `balanced` numeric NOT NULL,
+    `accounts_checked` integer NOT NULL,
+    `transfers_checked` integer NOT NULL,
+    `discrepancies` integer NOT NULL,
+    `report` text NOT NULL,
+    `key_id` text NOT NULL,
+    `signature` text NOT NULL
+);
+CREATE INDEX `idx_reconciliation_reports_started_at` ON `reconciliation_reports` (`started_at`);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `daily_balances`;
+DROP TABLE IF EXISTS `period_closes`;
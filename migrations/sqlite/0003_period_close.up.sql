@@ -0,0 +1,22 @@
+CREATE TABLE `period_closes` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `date` datetime NOT NULL,
+    `closed_by` text,
+    `accounts` integer NOT NULL,
+    `created_at` datetime
+);
+CREATE UNIQUE INDEX `idx_period_closes_date` ON `period_closes` (`date`);
+
+CREATE TABLE `daily_balances` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `account_id` integer NOT NULL,
+    `date` datetime NOT NULL,
+    `currency` text NOT NULL,
+    `opening_balance` decimal(15,2) NOT NULL,
+    `inflows` decimal(15,2) NOT NULL,
+    `outflows` decimal(15,2) NOT NULL,
+    `closing_balance` decimal(15,2) NOT NULL,
+    `created_at` datetime
+);
+CREATE INDEX `idx_daily_balances_date` ON `daily_balances` (`date`);
+CREATE UNIQUE INDEX `idx_daily_balance_account_date` ON `daily_balances` (`account_id`,`date`);
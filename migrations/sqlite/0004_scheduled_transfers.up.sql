@@ -0,0 +1,19 @@
+CREATE TABLE `scheduled_transfers` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `user_id` text NOT NULL,
+    `from_account_id` integer NOT NULL,
+    `to_account_id` integer NOT NULL,
+    `amount` decimal(15,2) NOT NULL,
+    `currency` text NOT NULL,
+    `execute_at` datetime NOT NULL,
+    `status` varchar(20) NOT NULL DEFAULT 'scheduled',
+    `transfer_id` integer,
+    `failure_reason` text,
+    `executed_at` datetime,
+    `cancelled_at` datetime,
+    `created_at` datetime,
+    `updated_at` datetime
+);
+CREATE INDEX `idx_scheduled_transfer_due` ON `scheduled_transfers` (`status`,`execute_at`);
+CREATE INDEX `idx_scheduled_transfers_from_account_id` ON `scheduled_transfers` (`from_account_id`);
+CREATE INDEX `idx_scheduled_transfers_user_id` ON `scheduled_transfers` (`user_id`);
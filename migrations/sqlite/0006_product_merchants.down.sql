@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS `idx_products_merchant_id`;
+ALTER TABLE `products` DROP COLUMN `merchant_id`;
@@ -1,22 +1,119 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
+	"bank-ledger-core/money"
+
 	"gorm.io/gorm"
 )
 
+const (
+	// SystemUserID owns the marketplace account that receives order payments.
+	SystemUserID = "0"
+	// IssuanceUserID owns the per-currency accounts that balance money
+	// entering the ledger (opening balances, manual funding).
+	IssuanceUserID = "system:issuance"
+	// systemUserPrefix marks the users that own the ledger's internal
+	// contra accounts.
+	systemUserPrefix = "system:"
+)
+
+// IsReservedUserID reports whether id belongs to the ledger itself, so that
+// nobody may register it or open accounts under it.
+func IsReservedUserID(id string) bool {
+	return id == SystemUserID || strings.HasPrefix(id, systemUserPrefix)
+}
+
+type AccountStatus string
+
+const (
+	AccountStatusActive AccountStatus = "active"
+	// A frozen account can still receive money but cannot send it.
+	AccountStatusFrozen AccountStatus = "frozen"
+	// A closed account can neither send nor receive. Accounts are only
+	// closed at a zero balance and are never reopened.
+	AccountStatusClosed AccountStatus = "closed"
+)
+
+func (s AccountStatus) IsValid() bool {
+	switch s {
+	case AccountStatusActive, AccountStatusFrozen, AccountStatusClosed:
+		return true
+	}
+	return false
+}
+
+// AccountType separates everyday accounts, which receive transfers addressed
+// to a user, from savings accounts, which only move money when addressed by
+// account ID.
+type AccountType string
+
+const (
+	AccountTypeCurrent AccountType = "current"
+	AccountTypeSavings AccountType = "savings"
+)
+
+func (t AccountType) IsValid() bool {
+	return t == AccountTypeCurrent || t == AccountTypeSavings
+}
+
 type Account struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	UserID       string         `gorm:"not null;index" json:"user_id"`
-	PasswordHash string         `gorm:"not null" json:"-"`
-	Currency     string         `gorm:"not null;size:3" json:"currency"`
-	Balance      string         `gorm:"type:decimal(15,2);not null;default:0.00" json:"balance"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	UserID      string       `gorm:"not null;index" json:"user_id"`
+	Type        AccountType  `gorm:"type:varchar(20);not null;default:current" json:"type"`
+	Name        string       `gorm:"size:100" json:"name,omitempty"`
+	Currency    string       `gorm:"not null;size:3" json:"currency"`
+	Balance     money.Amount `gorm:"type:decimal(15,2);not null;default:0.00" json:"ledger_balance"`
+	HeldBalance money.Amount `gorm:"type:decimal(15,2);not null;default:0.00" json:"held_balance"`
+	Version     int          `gorm:"not null;default:0" json:"-"`
+
+	Status          AccountStatus `gorm:"type:varchar(20);not null;default:active;index" json:"status"`
+	StatusReason    string        `gorm:"size:255" json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time    `json:"status_changed_at,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (Account) TableName() string {
 	return "accounts"
 }
+
+func (a *Account) AfterFind(tx *gorm.DB) error {
+	a.Balance = a.Balance.Normalize(a.Currency)
+	a.HeldBalance = a.HeldBalance.Normalize(a.Currency)
+	return nil
+}
+
+// AvailableBalance is the ledger balance minus funds reserved by pending holds.
+func (a *Account) AvailableBalance() money.Amount {
+	return a.Balance.Sub(a.HeldBalance)
+}
+
+func (a Account) MarshalJSON() ([]byte, error) {
+	type account Account
+	return json.Marshal(struct {
+		account
+		AvailableBalance money.Amount `json:"available_balance"`
+	}{account(a), a.AvailableBalance()})
+}
+
+// CanSend reports whether money may leave the account.
+func (a *Account) CanSend() bool {
+	return a.Status == "" || a.Status == AccountStatusActive
+}
+
+// CanReceive reports whether money may enter the account.
+func (a *Account) CanReceive() bool {
+	return a.Status != AccountStatusClosed
+}
+
+// AllowsNegativeBalance reports whether the account is an internal
+// contra account whose balance mirrors money held by customers.
+func (a *Account) AllowsNegativeBalance() bool {
+	return strings.HasPrefix(a.UserID, systemUserPrefix)
+}
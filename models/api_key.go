@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every API key token: blk_<key id>_<secret>.
+const APIKeyPrefix = "blk_"
+
+// APIKey authenticates a machine client as its owner. Only a SHA-256 hash of
+// the secret is stored; KeyID is public and identifies the key in logs and
+// in the audit trail.
+type APIKey struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	UserID     string         `gorm:"not null;index" json:"user_id"`
+	Name       string         `gorm:"not null;size:100" json:"name"`
+	KeyID      string         `gorm:"not null;size:24;uniqueIndex" json:"key_id"`
+	SecretHash string         `gorm:"not null;size:64" json:"-"`
+	Scopes     PermissionList `gorm:"type:text;not null" json:"scopes"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	LastUsedIP string         `gorm:"size:64" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty"`
+	// RotatedFromID links a key to the key it replaced.
+	RotatedFromID *uint     `json:"rotated_from_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsActive reports whether the key may authenticate at now.
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil && !now.Before(*k.RevokedAt) {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+// PermissionList is stored as a comma-separated string.
+type PermissionList []Permission
+
+func (l PermissionList) Contains(permission Permission) bool {
+	for _, p := range l {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (l PermissionList) Value() (driver.Value, error) {
+	parts := make([]string, len(l))
+	for i, p := range l {
+		parts[i] = string(p)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func (l *PermissionList) Scan(src interface{}) error {
+	var raw string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into PermissionList", src)
+	}
+	*l = PermissionList{}
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*l = append(*l, Permission(part))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audited actions, named <entity>.<verb>.
+const (
+	AuditAuthRegister             = "auth.register"
+	AuditAuthLogin                = "auth.login"
+	AuditAuthLoginFailed          = "auth.login_failed"
+	AuditAuthLogout               = "auth.logout"
+	AuditAuthLogoutAll            = "auth.logout_all"
+	AuditAuthSessionRevoke        = "auth.session_revoke"
+	AuditAuthMFAVerified          = "auth.mfa_verified"
+	AuditAuthMFAFailed            = "auth.mfa_failed"
+	AuditAuthStepUp               = "auth.step_up"
+	AuditAuthLockout              = "auth.lockout"
+	AuditAuthUnlock               = "auth.unlock"
+	AuditAuthPasswordChange       = "auth.password_change"
+	AuditAuthPasswordResetRequest = "auth.password_reset_request"
+	AuditAuthPasswordReset        = "auth.password_reset"
+
+	AuditTOTPEnroll        = "totp.enroll"
+	AuditTOTPConfirm       = "totp.confirm"
+	AuditTOTPDisable       = "totp.disable"
+	AuditTOTPRecoveryCodes = "totp.recovery_codes"
+
+	AuditAPIKeyCreate = "api_key.create"
+	AuditAPIKeyRevoke = "api_key.revoke"
+	AuditAPIKeyRotate = "api_key.rotate"
+
+	AuditAccountCreate = "account.create"
+	AuditAccountStatus = "account.status"
+	AuditAccountRotate = "account.rotate"
+	AuditUserRole      = "user.role"
+
+	AuditOwnershipOverride = "ownership.override"
+
+	AuditProductCreate = "product.create"
+
+	AuditOrderCreate = "order.create"
+	AuditOrderRefund = "order.refund"
+
+	AuditTransferCreate  = "transfer.create"
+	AuditTransferReverse = "transfer.reverse"
+
+	AuditScheduledTransferCreate = "scheduled_transfer.create"
+	AuditScheduledTransferCancel = "scheduled_transfer.cancel"
+	AuditScheduledTransferFail   = "scheduled_transfer.fail"
+
+	AuditHoldAuthorize = "hold.authorize"
+	AuditHoldCapture   = "hold.capture"
+	AuditHoldVoid      = "hold.void"
+	AuditHoldExpire    = "hold.expire"
+
+	AuditFXRateSet     = "fx_rate.set"
+	AuditFXQuoteCreate = "fx_quote.create"
+
+	AuditLedgerAdjust = "ledger.adjust"
+
+	AuditReconciliationRun = "reconciliation.run"
+
+	AuditPeriodClose = "period.close"
+)
+
+// ErrAuditImmutable is returned when something tries to change or remove a
+// recorded audit event.
+var ErrAuditImmutable = errors.New("audit events are append-only")
+
+// AuditEvent records one state change. Events form a hash chain: Hash covers
+// the event's fields and PrevHash, the hash of the event with the previous
+// Sequence, so editing, removing or reordering events breaks the chain.
+// SessionID holds a hash of the session ID, or key:<key id> for API keys.
+type AuditEvent struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Sequence    uint64    `gorm:"not null;uniqueIndex" json:"sequence"`
+	OccurredAt  time.Time `gorm:"not null;index" json:"occurred_at"`
+	ActorUserID string    `gorm:"size:255;index" json:"actor_user_id"`
+	ActorRole   Role      `gorm:"type:varchar(20)" json:"actor_role"`
+	SessionID   string    `gorm:"size:64" json:"session_id,omitempty"`
+	IP          string    `gorm:"size:64" json:"ip,omitempty"`
+	RequestID   string    `gorm:"size:64;index" json:"request_id,omitempty"`
+	Action      string    `gorm:"size:64;not null;index" json:"action"`
+	EntityType  string    `gorm:"size:32;index:idx_audit_entity" json:"entity_type"`
+	EntityID    string    `gorm:"size:64;index:idx_audit_entity" json:"entity_id"`
+	Before      string    `gorm:"type:text" json:"-"`
+	After       string    `gorm:"type:text" json:"-"`
+	PrevHash    string    `gorm:"size:64;not null" json:"prev_hash"`
+	Hash        string    `gorm:"size:64;not null" json:"hash"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+func (AuditEvent) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+func (AuditEvent) BeforeDelete(*gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// MarshalJSON embeds the before and after snapshots as JSON documents rather
+// than strings.
+func (e AuditEvent) MarshalJSON() ([]byte, error) {
+	type auditEvent AuditEvent
+	return json.Marshal(struct {
+		auditEvent
+		Before json.RawMessage `json:"before,omitempty"`
+		After  json.RawMessage `json:"after,omitempty"`
+	}{auditEvent(e), rawSnapshot(e.Before), rawSnapshot(e.After)})
+}
+
+func rawSnapshot(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+// AuditHead is the single row tracking the end of the audit chain. Locking it
+// serializes appends so every event links to its predecessor.
+type AuditHead struct {
+	ID       uint   `gorm:"primaryKey"`
+	Sequence uint64 `gorm:"not null"`
+	Hash     string `gorm:"size:64;not null"`
+}
+
+func (AuditHead) TableName() string {
+	return "audit_heads"
+}
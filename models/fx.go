@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"bank-ledger-core/money"
+)
+
+// FXUserID owns the per-currency position accounts that sit between the two
+// legs of a cross-currency transfer.
+const FXUserID = "system:fx"
+
+// ExchangeRate is the mid-market rate for converting BaseCurrency into
+// QuoteCurrency, valid from EffectiveDate until a newer rate for the pair.
+type ExchangeRate struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	BaseCurrency  string     `gorm:"not null;size:3;index:idx_exchange_rate_pair" json:"base_currency"`
+	QuoteCurrency string     `gorm:"not null;size:3;index:idx_exchange_rate_pair" json:"quote_currency"`
+	Rate          money.Rate `gorm:"type:decimal(24,10);not null" json:"rate"`
+	EffectiveDate time.Time  `gorm:"not null;index:idx_exchange_rate_pair" json:"effective_date"`
+	Source        string     `gorm:"size:50" json:"source"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
+
+// FXQuote locks a conversion for a short time so that the customer sees the
+// exact amount the recipient will receive before committing.
+type FXQuote struct {
+	ID           string       `gorm:"primaryKey;size:36" json:"id"`
+	UserID       string       `gorm:"not null;index" json:"user_id"`
+	FromCurrency string       `gorm:"not null;size:3" json:"from_currency"`
+	ToCurrency   string       `gorm:"not null;size:3" json:"to_currency"`
+	SourceAmount money.Amount `gorm:"type:decimal(15,2);not null" json:"source_amount"`
+	TargetAmount money.Amount `gorm:"type:decimal(15,2);not null" json:"target_amount"`
+	MidRate      money.Rate   `gorm:"type:decimal(24,10);not null" json:"mid_rate"`
+	Rate         money.Rate   `gorm:"type:decimal(24,10);not null" json:"rate"`
+	SpreadBps    int          `gorm:"not null" json:"spread_bps"`
+	ExpiresAt    time.Time    `gorm:"not null" json:"expires_at"`
+	UsedAt       *time.Time   `json:"used_at,omitempty"`
+	TransferID   *uint        `json:"transfer_id,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+func (FXQuote) TableName() string {
+	return "fx_quotes"
+}
+
+func (q *FXQuote) IsExpired(now time.Time) bool {
+	return now.After(q.ExpiresAt)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+type HoldStatus string
+
+const (
+	HoldStatusPending  HoldStatus = "pending"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusVoided   HoldStatus = "voided"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// Hold reserves funds on an account without moving them. While pending it
+// reduces the account's available balance but not its ledger balance.
+type Hold struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	AccountID      uint         `gorm:"not null;index" json:"account_id"`
+	ToAccountID    uint         `gorm:"not null;index" json:"to_account_id"`
+	TransferID     uint         `gorm:"not null;index" json:"transfer_id"`
+	Amount         money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	CapturedAmount money.Amount `gorm:"type:decimal(15,2);not null;default:0" json:"captured_amount"`
+	Currency       string       `gorm:"not null;size:3" json:"currency"`
+	Status         HoldStatus   `gorm:"type:varchar(20);not null;default:pending;index" json:"status"`
+	ExpiresAt      time.Time    `gorm:"not null;index" json:"expires_at"`
+	ReleasedAt     *time.Time   `json:"released_at,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+func (Hold) TableName() string {
+	return "holds"
+}
+
+func (h *Hold) AfterFind(tx *gorm.DB) error {
+	h.Amount = h.Amount.Normalize(h.Currency)
+	h.CapturedAmount = h.CapturedAmount.Normalize(h.Currency)
+	return nil
+}
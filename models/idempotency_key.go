@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+type IdempotencyStatus string
+
+const (
+	IdempotencyStatusInProgress IdempotencyStatus = "in_progress"
+	IdempotencyStatusCompleted  IdempotencyStatus = "completed"
+)
+
+// IdempotencyKey remembers the first response to a request made with a
+// client-supplied Idempotency-Key so that retries can be answered from it.
+type IdempotencyKey struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	UserID       string            `gorm:"not null;size:255;uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key          string            `gorm:"column:idempotency_key;not null;size:255;uniqueIndex:idx_idempotency_user_key" json:"key"`
+	Method       string            `gorm:"not null;size:10" json:"method"`
+	Path         string            `gorm:"not null;size:255" json:"path"`
+	Fingerprint  string            `gorm:"not null;size:64" json:"fingerprint"`
+	Status       IdempotencyStatus `gorm:"type:varchar(20);not null" json:"status"`
+	ResponseCode int               `json:"response_code"`
+	ResponseBody string            `gorm:"type:text" json:"-"`
+	ExpiresAt    time.Time         `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"bank-ledger-core/money"
+)
+
+type EntryType string
+
+const (
+	EntryTypeOpeningBalance EntryType = "opening_balance"
+	EntryTypeTransfer       EntryType = "transfer"
+	EntryTypeOrderPayment   EntryType = "order_payment"
+	EntryTypeRefund         EntryType = "refund"
+	EntryTypeReversal       EntryType = "reversal"
+	EntryTypeFee            EntryType = "fee"
+	EntryTypeAdjustment     EntryType = "adjustment"
+)
+
+// PostingDirection follows the bank's view of customer accounts: a credit
+// increases an account balance and a debit decreases it.
+type PostingDirection string
+
+const (
+	PostingDebit  PostingDirection = "debit"
+	PostingCredit PostingDirection = "credit"
+)
+
+// JournalEntry groups postings that must balance per currency. Entries and
+// postings are append-only and never updated or deleted.
+type JournalEntry struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Type        EntryType `gorm:"type:varchar(32);not null;index" json:"type"`
+	TransferID  *uint     `gorm:"index" json:"transfer_id,omitempty"`
+	Description string    `gorm:"size:255" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	Postings []Posting `gorm:"foreignKey:EntryID" json:"postings,omitempty"`
+}
+
+func (JournalEntry) TableName() string {
+	return "journal_entries"
+}
+
+type Posting struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	EntryID   uint             `gorm:"not null;index" json:"entry_id"`
+	AccountID uint             `gorm:"not null;index" json:"account_id"`
+	Direction PostingDirection `gorm:"type:varchar(6);not null" json:"direction"`
+	Amount    money.Amount     `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Currency  string           `gorm:"not null;size:3" json:"currency"`
+	// BalanceAfter is the account's ledger balance right after this posting.
+	BalanceAfter *money.Amount `gorm:"type:decimal(15,2)" json:"balance_after,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+func (Posting) TableName() string {
+	return "postings"
+}
+
+// Signed returns the posting's effect on the account balance.
+func (p Posting) Signed() money.Amount {
+	if p.Direction == PostingDebit {
+		return p.Amount.Neg()
+	}
+	return p.Amount
+}
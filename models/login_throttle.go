@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for one key, "user:<user_id>" or
+// "ip:<address>". LockedUntil is set once the failures reach the lockout
+// limit.
+type LoginThrottle struct {
+	Key          string     `gorm:"primaryKey;size:300" json:"key"`
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
@@ -3,28 +3,39 @@ package models
 import (
 	"time"
 
+	"bank-ledger-core/money"
+
 	"gorm.io/gorm"
 )
 
 type OrderStatus string
 
 const (
-	OrderStatusPending OrderStatus = "pending"
-	OrderStatusPaid    OrderStatus = "paid"
-	OrderStatusFailed  OrderStatus = "failed"
+	OrderStatusPending   OrderStatus = "pending"
+	OrderStatusPaid      OrderStatus = "paid"
+	OrderStatusFailed    OrderStatus = "failed"
 	OrderStatusCancelled OrderStatus = "cancelled"
+
+	OrderStatusPartiallyRefunded OrderStatus = "partially_refunded"
+	OrderStatusRefunded          OrderStatus = "refunded"
 )
 
 type Order struct {
-	ID        uint        `gorm:"primaryKey" json:"id"`
-	UserID    string      `gorm:"not null;index" json:"user_id"`
-	ProductID uint        `gorm:"not null;index" json:"product_id"`
-	Amount    string      `gorm:"type:decimal(15,2);not null" json:"amount"`
-	Quantity  int         `gorm:"not null" json:"quantity"`
-	Status    OrderStatus `gorm:"type:varchar(20);not null;default:pending" json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	UserID    string       `gorm:"not null;index" json:"user_id"`
+	AccountID uint         `gorm:"index" json:"account_id"`
+	ProductID uint         `gorm:"not null;index" json:"product_id"`
+	Amount    money.Amount `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Currency  string       `gorm:"size:3" json:"currency"`
+	Quantity  int          `gorm:"not null" json:"quantity"`
+	Status    OrderStatus  `gorm:"type:varchar(20);not null;default:pending" json:"status"`
+	// TransferID is the payment transfer; refunds point back at it.
+	TransferID       *uint          `gorm:"index" json:"transfer_id,omitempty"`
+	RefundedAmount   money.Amount   `gorm:"type:decimal(15,2);not null;default:0" json:"refunded_amount"`
+	RefundedQuantity int            `gorm:"not null;default:0" json:"refunded_quantity"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 
 	Product Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 }
@@ -32,3 +43,9 @@ type Order struct {
 func (Order) TableName() string {
 	return "orders"
 }
+
+func (o *Order) AfterFind(tx *gorm.DB) error {
+	o.Amount = o.Amount.Normalize(o.Currency)
+	o.RefundedAmount = o.RefundedAmount.Normalize(o.Currency)
+	return nil
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PasswordResetToken is a single-use token sent to a user to set a new
+// password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      string     `gorm:"not null;index" json:"user_id"`
+	TokenHash   string     `gorm:"not null;size:64;uniqueIndex" json:"-"`
+	RequestedIP string     `gorm:"size:64" json:"requested_ip"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+// PeriodClose marks a business day (UTC) as closed. Closed days are never
+// reopened, and no posting may be dated on or before the last closed day.
+type PeriodClose struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Date      time.Time `gorm:"not null;uniqueIndex" json:"date"`
+	ClosedBy  string    `gorm:"size:255" json:"closed_by"`
+	Accounts  int       `gorm:"not null" json:"accounts"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (PeriodClose) TableName() string {
+	return "period_closes"
+}
+
+// PeriodLock is the single row that serializes closing days with posting
+// into them: closing a day locks it for update, every posting for share.
+type PeriodLock struct {
+	ID uint `gorm:"primaryKey"`
+}
+
+func (PeriodLock) TableName() string {
+	return "period_locks"
+}
+
+// DailyBalance is an account's snapshot for a closed day: the balance at the
+// start and end of the day and the money that came in and went out.
+type DailyBalance struct {
+	ID             uint         `gorm:"primaryKey" json:"-"`
+	AccountID      uint         `gorm:"not null;uniqueIndex:idx_daily_balance_account_date" json:"account_id"`
+	Date           time.Time    `gorm:"not null;uniqueIndex:idx_daily_balance_account_date;index" json:"date"`
+	Currency       string       `gorm:"not null;size:3" json:"currency"`
+	OpeningBalance money.Amount `gorm:"type:decimal(15,2);not null" json:"opening_balance"`
+	Inflows        money.Amount `gorm:"type:decimal(15,2);not null" json:"inflows"`
+	Outflows       money.Amount `gorm:"type:decimal(15,2);not null" json:"outflows"`
+	ClosingBalance money.Amount `gorm:"type:decimal(15,2);not null" json:"closing_balance"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+func (DailyBalance) TableName() string {
+	return "daily_balances"
+}
+
+func (b *DailyBalance) AfterFind(tx *gorm.DB) error {
+	b.OpeningBalance = b.OpeningBalance.Normalize(b.Currency)
+	b.Inflows = b.Inflows.Normalize(b.Currency)
+	b.Outflows = b.Outflows.Normalize(b.Currency)
+	b.ClosingBalance = b.ClosingBalance.Normalize(b.Currency)
+	return nil
+}
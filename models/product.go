@@ -3,14 +3,20 @@ package models
 import (
 	"time"
 
+	"bank-ledger-core/money"
+
 	"gorm.io/gorm"
 )
 
+// Product is a catalog item. MerchantID is the merchant who listed it and
+// may refund its orders; it is empty for products listed before merchants
+// owned their products.
 type Product struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
+	MerchantID  string         `gorm:"size:255;index" json:"merchant_id"`
 	Name        string         `gorm:"not null;size:255" json:"name"`
 	Description string         `gorm:"type:text" json:"description"`
-	Price       string         `gorm:"type:decimal(15,2);not null" json:"price"`
+	Price       money.Amount   `gorm:"type:decimal(15,2);not null" json:"price"`
 	Stock       int            `gorm:"not null;default:0" json:"stock"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
@@ -20,3 +26,8 @@ type Product struct {
 func (Product) TableName() string {
 	return "products"
 }
+
+func (p *Product) AfterFind(tx *gorm.DB) error {
+	p.Price = p.Price.Normalize("")
+	return nil
+}
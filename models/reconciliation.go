@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ReconciliationTrigger string
+
+const (
+	ReconciliationTriggerSchedule ReconciliationTrigger = "schedule"
+	ReconciliationTriggerManual   ReconciliationTrigger = "manual"
+)
+
+// ReconciliationReport is the stored outcome of one reconciliation run.
+// Report holds the findings as JSON exactly as they were signed: Signature
+// is an HMAC-SHA256 of Report under the key identified by KeyID.
+type ReconciliationReport struct {
+	ID               uint                  `gorm:"primaryKey" json:"id"`
+	Trigger          ReconciliationTrigger `gorm:"type:varchar(20);not null" json:"trigger"`
+	TriggeredBy      string                `gorm:"size:255" json:"triggered_by"`
+	StartedAt        time.Time             `gorm:"not null;index" json:"started_at"`
+	FinishedAt       time.Time             `gorm:"not null" json:"finished_at"`
+	Balanced         bool                  `gorm:"not null" json:"balanced"`
+	AccountsChecked  int                   `gorm:"not null" json:"accounts_checked"`
+	TransfersChecked int                   `gorm:"not null" json:"transfers_checked"`
+	Discrepancies    int                   `gorm:"not null" json:"discrepancies"`
+	Report           string                `gorm:"type:text;not null" json:"-"`
+	KeyID            string                `gorm:"size:16;not null" json:"key_id"`
+	Signature        string                `gorm:"size:64;not null" json:"signature"`
+}
+
+func (ReconciliationReport) TableName() string {
+	return "reconciliation_reports"
+}
+
+// MarshalJSON embeds the signed findings as a JSON document.
+func (r ReconciliationReport) MarshalJSON() ([]byte, error) {
+	type reconciliationReport ReconciliationReport
+	return json.Marshal(struct {
+		reconciliationReport
+		Report json.RawMessage `json:"report,omitempty"`
+	}{reconciliationReport(r), rawSnapshot(r.Report)})
+}
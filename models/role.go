@@ -0,0 +1,132 @@
+package models
+
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleMerchant Role = "merchant"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+// Permission names an action guarded at route level, as "resource:action".
+type Permission string
+
+const (
+	PermissionAccountsRead     Permission = "accounts:read"
+	PermissionAccountsList     Permission = "accounts:list"
+	PermissionAccountsCreate   Permission = "accounts:create"
+	PermissionAccountsOpen     Permission = "accounts:open"
+	PermissionAccountsStatus   Permission = "accounts:status"
+	PermissionLoginsUnlock     Permission = "logins:unlock"
+	PermissionProductsWrite    Permission = "products:write"
+	PermissionOrdersWrite      Permission = "orders:write"
+	PermissionOrdersRefund     Permission = "orders:refund"
+	PermissionTransfersWrite   Permission = "transfers:write"
+	PermissionTransfersReverse Permission = "transfers:reverse"
+	PermissionHoldsWrite       Permission = "holds:write"
+	PermissionHistoryRead      Permission = "history:read"
+	PermissionFXQuote          Permission = "fx:quote"
+	PermissionFXRatesWrite     Permission = "fx:rates:write"
+	PermissionLedgerRead       Permission = "ledger:read"
+	PermissionLedgerAdjust     Permission = "ledger:adjust"
+	PermissionLedgerReconcile  Permission = "ledger:reconcile"
+	PermissionLedgerClose      Permission = "ledger:close"
+	PermissionRolesAssign      Permission = "roles:assign"
+	PermissionAuditRead        Permission = "audit:read"
+	PermissionAPIKeysManage    Permission = "api_keys:manage"
+	// PermissionOwnershipOverride lets a user act on or view accounts
+	// belonging to other users.
+	PermissionOwnershipOverride Permission = "ownership:override"
+)
+
+// allPermissions lists every permission, in declaration order.
+var allPermissions = []Permission{
+	PermissionAccountsRead,
+	PermissionAccountsList,
+	PermissionAccountsCreate,
+	PermissionAccountsOpen,
+	PermissionAccountsStatus,
+	PermissionLoginsUnlock,
+	PermissionProductsWrite,
+	PermissionOrdersWrite,
+	PermissionOrdersRefund,
+	PermissionTransfersWrite,
+	PermissionTransfersReverse,
+	PermissionHoldsWrite,
+	PermissionHistoryRead,
+	PermissionFXQuote,
+	PermissionFXRatesWrite,
+	PermissionLedgerRead,
+	PermissionLedgerAdjust,
+	PermissionLedgerReconcile,
+	PermissionLedgerClose,
+	PermissionRolesAssign,
+	PermissionAuditRead,
+	PermissionAPIKeysManage,
+	PermissionOwnershipOverride,
+}
+
+// customerPermissions are what every role can do with its own money.
+var customerPermissions = []Permission{
+	PermissionAPIKeysManage,
+	PermissionAccountsRead,
+	PermissionAccountsOpen,
+	PermissionOrdersWrite,
+	PermissionTransfersWrite,
+	PermissionHoldsWrite,
+	PermissionHistoryRead,
+	PermissionFXQuote,
+}
+
+var rolePermissions = map[Role][]Permission{
+	RoleCustomer: customerPermissions,
+	RoleMerchant: append([]Permission{
+		PermissionProductsWrite,
+		PermissionOrdersRefund,
+	}, customerPermissions...),
+	RoleOperator: append([]Permission{
+		PermissionAccountsList,
+		PermissionAccountsCreate,
+		PermissionAccountsStatus,
+		PermissionLoginsUnlock,
+		PermissionOrdersRefund,
+		PermissionTransfersReverse,
+		PermissionFXRatesWrite,
+		PermissionLedgerRead,
+		PermissionLedgerReconcile,
+		PermissionLedgerClose,
+		PermissionOwnershipOverride,
+	}, customerPermissions...),
+}
+
+func (p Permission) IsValid() bool {
+	for _, known := range allPermissions {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleCustomer, RoleMerchant, RoleOperator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// Can reports whether the role grants the permission. Admins can do
+// everything.
+func (r Role) Can(permission Permission) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
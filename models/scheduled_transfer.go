@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferStatusScheduled ScheduledTransferStatus = "scheduled"
+	// Executing is only found on transfers picked up by releases that
+	// claimed a transfer before running it; one left in this state needs a
+	// look, as the transfer may or may not have happened.
+	ScheduledTransferStatusExecuting ScheduledTransferStatus = "executing"
+	ScheduledTransferStatusCompleted ScheduledTransferStatus = "completed"
+	ScheduledTransferStatusFailed    ScheduledTransferStatus = "failed"
+	ScheduledTransferStatusCancelled ScheduledTransferStatus = "cancelled"
+)
+
+// ScheduledTransfer is a same-currency transfer submitted now and executed
+// at ExecuteAt on behalf of UserID. TransferID is set once it has run;
+// FailureReason when it could not.
+type ScheduledTransfer struct {
+	ID            uint                    `gorm:"primaryKey" json:"id"`
+	UserID        string                  `gorm:"not null;size:255;index" json:"user_id"`
+	FromAccountID uint                    `gorm:"not null;index" json:"from_account_id"`
+	ToAccountID   uint                    `gorm:"not null" json:"to_account_id"`
+	Amount        money.Amount            `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Currency      string                  `gorm:"not null;size:3" json:"currency"`
+	ExecuteAt     time.Time               `gorm:"not null;index:idx_scheduled_transfer_due,priority:2" json:"execute_at"`
+	Status        ScheduledTransferStatus `gorm:"type:varchar(20);not null;default:scheduled;index:idx_scheduled_transfer_due,priority:1" json:"status"`
+	TransferID    *uint                   `json:"transfer_id,omitempty"`
+	FailureReason string                  `gorm:"size:255" json:"failure_reason,omitempty"`
+	ExecutedAt    *time.Time              `json:"executed_at,omitempty"`
+	CancelledAt   *time.Time              `json:"cancelled_at,omitempty"`
+	CreatedAt     time.Time               `json:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+}
+
+func (ScheduledTransfer) TableName() string {
+	return "scheduled_transfers"
+}
+
+func (t *ScheduledTransfer) AfterFind(tx *gorm.DB) error {
+	t.Amount = t.Amount.Normalize(t.Currency)
+	return nil
+}
@@ -4,12 +4,23 @@ import (
 	"time"
 )
 
+// Session is a login. ExpiresAt slides forward while the session is used,
+// but never past AbsoluteExpiresAt. A session with MFAPending set only proves
+// the password and can do nothing but complete two-factor verification.
+// MFAAttempts counts the wrong codes the session has sent.
 type Session struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	UserID    string    `gorm:"not null;index" json:"user_id"`
-	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                string     `gorm:"primaryKey" json:"-"`
+	UserID            string     `gorm:"not null;index" json:"user_id"`
+	MFAPending        bool       `gorm:"not null;default:false" json:"mfa_pending"`
+	MFAAttempts       int        `gorm:"not null;default:0" json:"-"`
+	StepUpAt          *time.Time `json:"step_up_at,omitempty"`
+	UserAgent         string     `gorm:"size:512" json:"user_agent"`
+	IP                string     `gorm:"size:64" json:"ip"`
+	LastSeenAt        time.Time  `json:"last_seen_at"`
+	ExpiresAt         time.Time  `gorm:"not null;index" json:"expires_at"`
+	AbsoluteExpiresAt time.Time  `gorm:"index" json:"absolute_expires_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
 func (Session) TableName() string {
@@ -17,5 +28,15 @@ func (Session) TableName() string {
 }
 
 func (s *Session) IsExpired() bool {
-	return time.Now().After(s.ExpiresAt)
+	return s.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt reports whether the session has been idle too long or reached
+// its maximum lifetime at now. Sessions created before the lifetime was
+// recorded only expire when idle.
+func (s *Session) IsExpiredAt(now time.Time) bool {
+	if !now.Before(s.ExpiresAt) {
+		return true
+	}
+	return !s.AbsoluteExpiresAt.IsZero() && !now.Before(s.AbsoluteExpiresAt)
 }
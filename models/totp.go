@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// TOTPEnrollment holds a user's authenticator secret. Two-factor login is
+// only enforced once the enrollment is confirmed with a first code.
+type TOTPEnrollment struct {
+	UserID      string     `gorm:"primaryKey;size:255" json:"user_id"`
+	Secret      string     `gorm:"not null;size:64" json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	// LastUsedStep is the time step of the last accepted code; codes from
+	// it or earlier steps are refused so a code cannot be replayed.
+	LastUsedStep int64     `gorm:"not null;default:0" json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (TOTPEnrollment) TableName() string {
+	return "totp_enrollments"
+}
+
+func (e *TOTPEnrollment) IsConfirmed() bool {
+	return e.ConfirmedAt != nil
+}
+
+// RecoveryCode is a single-use code for when the authenticator is lost. Only
+// its SHA-256 hash is stored.
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    string `gorm:"not null;index"`
+	CodeHash  string `gorm:"not null;size:64;uniqueIndex"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
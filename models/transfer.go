@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"bank-ledger-core/money"
+
 	"gorm.io/gorm"
 )
 
@@ -12,18 +14,32 @@ const (
 	TransferStatusPending   TransferStatus = "pending"
 	TransferStatusCompleted TransferStatus = "completed"
 	TransferStatusFailed    TransferStatus = "failed"
+	TransferStatusVoided    TransferStatus = "voided"
+	TransferStatusReversed  TransferStatus = "reversed"
 )
 
 type Transfer struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	FromAccountID uint          `gorm:"not null;index" json:"from_account_id"`
-	ToAccountID   uint          `gorm:"not null;index" json:"to_account_id"`
-	Amount        string        `gorm:"type:decimal(15,2);not null" json:"amount"`
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	FromAccountID uint           `gorm:"not null;index" json:"from_account_id"`
+	ToAccountID   uint           `gorm:"not null;index" json:"to_account_id"`
+	Amount        money.Amount   `gorm:"type:decimal(15,2);not null" json:"amount"`
+	Currency      string         `gorm:"size:3" json:"currency"`
 	Status        TransferStatus `gorm:"type:varchar(20);not null;default:pending" json:"status"`
-	CreatedAt     time.Time     `json:"created_at"`
-	UpdatedAt     time.Time     `json:"updated_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 
+	// ReversalOfID links a reversal or refund to the transfer it compensates.
+	ReversalOfID *uint `gorm:"index" json:"reversal_of_id,omitempty"`
+
+	// Set only for cross-currency transfers: what the recipient was credited
+	// and the quote that fixed the conversion.
+	DestinationAmount   *money.Amount `gorm:"type:decimal(15,2)" json:"destination_amount,omitempty"`
+	DestinationCurrency string        `gorm:"size:3" json:"destination_currency,omitempty"`
+	FXRate              *money.Rate   `gorm:"type:decimal(24,10)" json:"fx_rate,omitempty"`
+	FXSpreadBps         *int          `json:"fx_spread_bps,omitempty"`
+	FXQuoteID           *string       `gorm:"size:36" json:"fx_quote_id,omitempty"`
+
 	FromAccount Account `gorm:"foreignKey:FromAccountID" json:"from_account,omitempty"`
 	ToAccount   Account `gorm:"foreignKey:ToAccountID" json:"to_account,omitempty"`
 }
@@ -31,3 +47,12 @@ type Transfer struct {
 func (Transfer) TableName() string {
 	return "transfers"
 }
+
+func (t *Transfer) AfterFind(tx *gorm.DB) error {
+	t.Amount = t.Amount.Normalize(t.Currency)
+	if t.DestinationAmount != nil {
+		normalized := t.DestinationAmount.Normalize(t.DestinationCurrency)
+		t.DestinationAmount = &normalized
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// User is a person or company that can log in. Credentials and the role
+// belong to the user; money lives in the user's accounts, of which there may
+// be several (one per currency, current and savings).
+type User struct {
+	ID           string    `gorm:"primaryKey;size:255" json:"user_id"`
+	PasswordHash string    `gorm:"not null" json:"-"`
+	Role         Role      `gorm:"type:varchar(20);not null;default:customer" json:"role"`
+	DisplayName  string    `gorm:"size:255" json:"display_name,omitempty"`
+	Email        string    `gorm:"size:255" json:"email,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (User) TableName() string {
+	return "users"
+}
@@ -0,0 +1,425 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an exact decimal monetary value stored as an integer number of
+// units at a fixed scale, i.e. units / 10^scale. The zero value is 0.
+type Amount struct {
+	units int64
+	scale int32
+}
+
+const (
+	// DefaultScale is used for currencies without a known minor unit.
+	DefaultScale int32 = 2
+	// MaxScale bounds the number of decimals Parse accepts.
+	MaxScale int32 = 6
+	// StorageScale is the number of decimals the monetary columns keep.
+	StorageScale int32 = 2
+	// MaxIntegerDigits matches the widest monetary column in the schema and
+	// keeps sums of many amounts far away from int64 overflow.
+	MaxIntegerDigits = 15
+)
+
+var (
+	ErrInvalidAmount  = errors.New("invalid amount format")
+	ErrNegativeAmount = errors.New("amount must not be negative")
+	ErrZeroAmount     = errors.New("amount must be greater than zero")
+	ErrTooPrecise     = errors.New("amount has more decimal places than the currency allows")
+	ErrOutOfRange     = errors.New("amount is out of range")
+	ErrOverflow       = errors.New("amount overflow")
+
+	ErrUnsupportedCurrency = errors.New("currency has more decimal places than the ledger stores")
+)
+
+// currencyScales lists ISO 4217 minor units that differ from DefaultScale.
+var currencyScales = map[string]int32{
+	"BHD": 3,
+	"CLP": 0,
+	"IQD": 3,
+	"ISK": 0,
+	"JOD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"LYD": 3,
+	"OMR": 3,
+	"TND": 3,
+	"VND": 0,
+}
+
+// Scale returns the number of minor-unit decimals for an ISO 4217 currency.
+func Scale(currency string) int32 {
+	if scale, ok := currencyScales[strings.ToUpper(currency)]; ok {
+		return scale
+	}
+	return DefaultScale
+}
+
+// CheckCurrency returns ErrUnsupportedCurrency for currencies whose minor
+// unit is finer than StorageScale, so that amounts in them are never cut
+// short when written.
+func CheckCurrency(currency string) error {
+	if Scale(currency) > StorageScale {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCurrency, strings.ToUpper(currency))
+	}
+	return nil
+}
+
+var pow10 = func() [19]int64 {
+	var p [19]int64
+	p[0] = 1
+	for i := 1; i < len(p); i++ {
+		p[i] = p[i-1] * 10
+	}
+	return p
+}()
+
+func New(units int64, scale int32) Amount {
+	return Amount{units: units, scale: scale}
+}
+
+// FromMinor builds an amount from an integer count of the currency's minor units.
+func FromMinor(minor int64, currency string) Amount {
+	return Amount{units: minor, scale: Scale(currency)}
+}
+
+// Parse reads a plain decimal string such as "100", "-5.5" or "10.25".
+// Exponents, thousands separators and more than MaxScale decimals are rejected.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Amount{}, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return Amount{}, ErrInvalidAmount
+	}
+	if hasDot && fracPart == "" {
+		return Amount{}, ErrInvalidAmount
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Amount{}, ErrInvalidAmount
+	}
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if len(intPart) > MaxIntegerDigits {
+		return Amount{}, ErrOutOfRange
+	}
+	if int32(len(fracPart)) > MaxScale {
+		return Amount{}, ErrTooPrecise
+	}
+
+	units, err := strconv.ParseInt("0"+intPart+fracPart, 10, 64)
+	if err != nil {
+		return Amount{}, ErrOutOfRange
+	}
+	if negative {
+		units = -units
+	}
+
+	return Amount{units: units, scale: int32(len(fracPart))}, nil
+}
+
+// MustParse is Parse for constants and tests; it panics on invalid input.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(fmt.Sprintf("money: MustParse(%q): %v", s, err))
+	}
+	return a
+}
+
+// ParseIn parses s as an amount of currency and normalises it to the
+// currency's scale. Values with more significant decimals are rejected.
+func ParseIn(s, currency string) (Amount, error) {
+	a, err := Parse(s)
+	if err != nil {
+		return Amount{}, err
+	}
+	return a.In(currency)
+}
+
+// ParsePositive is ParseIn for amounts that move money: the result must be
+// strictly greater than zero.
+func ParsePositive(s, currency string) (Amount, error) {
+	a, err := ParseIn(s, currency)
+	if err != nil {
+		return Amount{}, err
+	}
+	if err := Validate(a, currency); err != nil {
+		return Amount{}, err
+	}
+	return a, nil
+}
+
+// Validate reports whether a can be used as a payment amount in currency.
+func Validate(a Amount, currency string) error {
+	if a.IsNegative() {
+		return ErrNegativeAmount
+	}
+	if a.IsZero() {
+		return ErrZeroAmount
+	}
+	if a.significantScale() > Scale(currency) {
+		return ErrTooPrecise
+	}
+	return nil
+}
+
+// In rescales a to the currency's scale without rounding.
+func (a Amount) In(currency string) (Amount, error) {
+	scale := Scale(currency)
+	if a.significantScale() > scale {
+		return Amount{}, ErrTooPrecise
+	}
+	return a.Round(scale, RoundHalfEven), nil
+}
+
+// Normalize pads a to at least the currency's scale so that values read back
+// from the database format consistently ("0" becomes "0.00" for USD).
+func (a Amount) Normalize(currency string) Amount {
+	if scale := Scale(currency); a.scale < scale {
+		return a.Round(scale, RoundHalfEven)
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Amount) Units() int64 { return a.units }
+
+func (a Amount) Scale() int32 { return a.scale }
+
+func (a Amount) Sign() int {
+	switch {
+	case a.units > 0:
+		return 1
+	case a.units < 0:
+		return -1
+	}
+	return 0
+}
+
+func (a Amount) IsZero() bool     { return a.units == 0 }
+func (a Amount) IsNegative() bool { return a.units < 0 }
+func (a Amount) IsPositive() bool { return a.units > 0 }
+
+func (a Amount) Neg() Amount {
+	if a.units == math.MinInt64 {
+		panic(ErrOverflow)
+	}
+	return Amount{units: -a.units, scale: a.scale}
+}
+
+func (a Amount) Abs() Amount {
+	if a.units < 0 {
+		return a.Neg()
+	}
+	return a
+}
+
+// Add returns a+b at the larger of the two scales. It panics with
+// ErrOverflow if the result does not fit, which cannot happen for values
+// within the range Parse accepts.
+func (a Amount) Add(b Amount) Amount {
+	x, y, scale := align(a, b)
+	sum := x + y
+	if (sum > x) != (y > 0) {
+		panic(ErrOverflow)
+	}
+	return Amount{units: sum, scale: scale}
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return a.Add(b.Neg())
+}
+
+// Cmp compares a and b and returns -1, 0 or +1.
+func (a Amount) Cmp(b Amount) int {
+	x, y, _ := align(a, b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func (a Amount) Equal(b Amount) bool {
+	return a.Cmp(b) == 0
+}
+
+// MulInt multiplies a by an integer factor such as a quantity.
+func (a Amount) MulInt(n int64) (Amount, error) {
+	product := new(big.Int).Mul(big.NewInt(a.units), big.NewInt(n))
+	if !product.IsInt64() {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{units: product.Int64(), scale: a.scale}, nil
+}
+
+// MulFrac returns a * num / den at a's scale, rounded according to mode.
+// It is used to prorate an amount, e.g. a refund for part of an order.
+func (a Amount) MulFrac(num, den int64, mode RoundingMode) (Amount, error) {
+	if den <= 0 {
+		return Amount{}, ErrInvalidAmount
+	}
+	product := new(big.Int).Mul(big.NewInt(a.units), big.NewInt(num))
+	q := roundQuo(product, big.NewInt(den), mode)
+	if !q.IsInt64() {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{units: q.Int64(), scale: a.scale}, nil
+}
+
+// Round returns a at the given scale. Increasing the scale is always exact;
+// decreasing it rounds according to mode.
+func (a Amount) Round(scale int32, mode RoundingMode) Amount {
+	switch {
+	case scale == a.scale:
+		return a
+	case scale > a.scale:
+		return Amount{units: scaleUp(a.units, scale-a.scale), scale: scale}
+	}
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.scale-scale)), nil)
+	q := roundQuo(big.NewInt(a.units), den, mode)
+	return Amount{units: q.Int64(), scale: scale}
+}
+
+// String formats a with exactly Scale() decimals, e.g. "-10.50".
+func (a Amount) String() string {
+	units := strconv.FormatInt(a.units, 10)
+	sign := ""
+	if strings.HasPrefix(units, "-") {
+		sign, units = "-", units[1:]
+	}
+	if a.scale <= 0 {
+		return sign + units
+	}
+	if pad := int(a.scale) + 1 - len(units); pad > 0 {
+		units = strings.Repeat("0", pad) + units
+	}
+	cut := len(units) - int(a.scale)
+	return sign + units[:cut] + "." + units[cut:]
+}
+
+// Rat returns a as an exact rational number.
+func (a Amount) Rat() *big.Rat {
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.scale)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(a.units), den)
+}
+
+// significantScale is the scale a would have with trailing zeros removed.
+func (a Amount) significantScale() int32 {
+	if a.units == 0 {
+		return 0
+	}
+	scale := a.scale
+	for scale > 0 && a.units%pow10[a.scale-scale+1] == 0 {
+		scale--
+	}
+	return scale
+}
+
+func align(a, b Amount) (int64, int64, int32) {
+	switch {
+	case a.scale > b.scale:
+		return a.units, scaleUp(b.units, a.scale-b.scale), a.scale
+	case b.scale > a.scale:
+		return scaleUp(a.units, b.scale-a.scale), b.units, b.scale
+	}
+	return a.units, b.units, a.scale
+}
+
+func scaleUp(units int64, by int32) int64 {
+	if by >= int32(len(pow10)) {
+		panic(ErrOverflow)
+	}
+	p := pow10[by]
+	if units > math.MaxInt64/p || units < math.MinInt64/p {
+		panic(ErrOverflow)
+	}
+	return units * p
+}
+
+// Value stores the amount as its decimal string so that numeric columns
+// receive an exact literal.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+func (a *Amount) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*a = Amount{}
+		return nil
+	case int64:
+		*a = Amount{units: v}
+		return nil
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return fmt.Errorf("money: cannot scan %q: %w", s, err)
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+// UnmarshalJSON accepts both quoted strings and bare JSON numbers; numbers
+// are parsed from their literal text so no float rounding is involved.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
@@ -0,0 +1,178 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"100", "100", nil},
+		{"100.50", "100.50", nil},
+		{"-5.5", "-5.5", nil},
+		{".25", "0.25", nil},
+		{"0001.10", "1.10", nil},
+		{"", "", ErrInvalidAmount},
+		{"1e6", "", ErrInvalidAmount},
+		{"1,000.00", "", ErrInvalidAmount},
+		{"10.", "", ErrInvalidAmount},
+		{"1.1234567", "", ErrTooPrecise},
+		{"1234567890123456", "", ErrOutOfRange},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCheckCurrency(t *testing.T) {
+	for _, currency := range []string{"UZS", "usd", "JPY"} {
+		if err := CheckCurrency(currency); err != nil {
+			t.Errorf("CheckCurrency(%q) = %v", currency, err)
+		}
+	}
+	for _, currency := range []string{"KWD", "bhd", "OMR"} {
+		if err := CheckCurrency(currency); !errors.Is(err, ErrUnsupportedCurrency) {
+			t.Errorf("CheckCurrency(%q) = %v, want ErrUnsupportedCurrency", currency, err)
+		}
+	}
+}
+
+func TestParsePositive(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     string
+		err      error
+	}{
+		{"10", "UZS", "10.00", nil},
+		{"10.5", "USD", "10.50", nil},
+		{"10.500", "USD", "10.50", nil},
+		{"10.005", "USD", "", ErrTooPrecise},
+		{"10.005", "KWD", "10.005", nil},
+		{"10.5", "JPY", "", ErrTooPrecise},
+		{"0", "USD", "", ErrZeroAmount},
+		{"0.00", "USD", "", ErrZeroAmount},
+		{"-1", "USD", "", ErrNegativeAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePositive(tt.in, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParsePositive(%q, %s) error = %v, want %v", tt.in, tt.currency, err, tt.err)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParsePositive(%q, %s) = %s, want %s", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestArithmeticIsExact(t *testing.T) {
+	balance := MustParse("0")
+	for i := 0; i < 10; i++ {
+		balance = balance.Add(MustParse("0.10"))
+	}
+	if balance.Cmp(MustParse("1")) != 0 {
+		t.Fatalf("ten times 0.10 = %s, want 1.00", balance)
+	}
+
+	large := MustParse("999999999999999.99")
+	if got := large.Sub(MustParse("0.01")).String(); got != "999999999999999.98" {
+		t.Fatalf("large subtraction = %s", got)
+	}
+
+	total, err := MustParse("35000.00").MulInt(3)
+	if err != nil || total.String() != "105000.00" {
+		t.Fatalf("MulInt = %s, %v", total, err)
+	}
+
+	part, err := MustParse("100.00").MulFrac(1, 3, RoundHalfEven)
+	if err != nil || part.String() != "33.33" {
+		t.Fatalf("MulFrac = %s, %v", part, err)
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode RoundingMode
+		want string
+	}{
+		{"2.345", RoundHalfEven, "2.34"},
+		{"2.355", RoundHalfEven, "2.36"},
+		{"2.345", RoundHalfUp, "2.35"},
+		{"-2.345", RoundHalfUp, "-2.35"},
+		{"2.349", RoundDown, "2.34"},
+		{"2.341", RoundUp, "2.35"},
+		{"-2.341", RoundFloor, "-2.35"},
+		{"-2.349", RoundCeiling, "-2.34"},
+		{"2.3", RoundHalfEven, "2.30"},
+	}
+
+	for _, tt := range tests {
+		if got := MustParse(tt.in).Round(2, tt.mode).String(); got != tt.want {
+			t.Errorf("Round(%s, %d) = %s, want %s", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestScanAndJSON(t *testing.T) {
+	var a Amount
+	for _, src := range []interface{}{"100000.00", []byte("100000.00"), int64(100000), float64(100000)} {
+		if err := a.Scan(src); err != nil {
+			t.Fatalf("Scan(%v): %v", src, err)
+		}
+		if a.Cmp(MustParse("100000")) != 0 {
+			t.Fatalf("Scan(%v) = %s", src, a)
+		}
+	}
+
+	var body struct {
+		Price Amount `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 35000.50}`), &body); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(body)
+	if string(out) != `{"price":"35000.50"}` {
+		t.Fatalf("Marshal = %s", out)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	rate, err := ParseRate("12650.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := MustParse("10.00").Convert(rate.WithSpread(50), "UZS", RoundDown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 12650.5 * 0.995 = 12587.2475
+	if got.String() != "125872.47" {
+		t.Fatalf("Convert = %s, want 125872.47", got)
+	}
+
+	back, _ := MustParse("125872.47").Convert(rate.Inverse(), "USD", RoundDown)
+	if back.String() != "9.95" {
+		t.Fatalf("inverse Convert = %s, want 9.95", back)
+	}
+
+	if _, err := ParseRate("-1"); !errors.Is(err, ErrInvalidRate) {
+		t.Fatalf("ParseRate(-1) error = %v", err)
+	}
+}
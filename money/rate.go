@@ -0,0 +1,137 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RateScale is the number of decimals an exchange rate is stored with.
+const RateScale = 10
+
+var ErrInvalidRate = errors.New("invalid exchange rate")
+
+// Rate is an exact exchange rate: how many units of the quote currency one
+// unit of the base currency buys. The zero value is not a valid rate.
+type Rate struct {
+	r *big.Rat
+}
+
+// ParseRate reads a positive decimal rate such as "12650.5" or "0.000079".
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.ContainsAny(s, "eE/") {
+		return Rate{}, ErrInvalidRate
+	}
+	if _, frac, ok := strings.Cut(s, "."); ok && len(frac) > RateScale {
+		return Rate{}, fmt.Errorf("%w: more than %d decimal places", ErrInvalidRate, RateScale)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() <= 0 {
+		return Rate{}, ErrInvalidRate
+	}
+	return Rate{r: r}, nil
+}
+
+func (r Rate) IsZero() bool { return r.r == nil || r.r.Sign() == 0 }
+
+// Inverse returns the rate of the opposite direction, rounded to RateScale.
+func (r Rate) Inverse() Rate {
+	return Rate{r: new(big.Rat).Inv(r.r)}.Round()
+}
+
+// WithSpread lowers the rate by bps basis points in the bank's favour and
+// rounds it to RateScale.
+func (r Rate) WithSpread(bps int) Rate {
+	factor := big.NewRat(int64(10000-bps), 10000)
+	return Rate{r: new(big.Rat).Mul(r.r, factor)}.Round()
+}
+
+// Round returns r rounded half-even to RateScale decimals.
+func (r Rate) Round() Rate {
+	den := new(big.Int).Exp(big.NewInt(10), big.NewInt(RateScale), nil)
+	num := new(big.Int).Mul(r.r.Num(), den)
+	q := roundQuo(num, r.r.Denom(), RoundHalfEven)
+	return Rate{r: new(big.Rat).SetFrac(q, den)}
+}
+
+// Convert multiplies a by the rate and rounds the result to the scale of
+// the target currency using mode.
+func (a Amount) Convert(r Rate, currency string, mode RoundingMode) (Amount, error) {
+	if r.IsZero() {
+		return Amount{}, ErrInvalidRate
+	}
+	scale := Scale(currency)
+	value := new(big.Rat).Mul(a.Rat(), r.r)
+	num := new(big.Int).Mul(value.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	q := roundQuo(num, value.Denom(), mode)
+	if !q.IsInt64() {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{units: q.Int64(), scale: scale}, nil
+}
+
+// String formats the rate with trailing zeros removed.
+func (r Rate) String() string {
+	if r.r == nil {
+		return "0"
+	}
+	s := r.r.FloatString(RateScale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+func (r *Rate) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		*r = Rate{}
+		return nil
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return fmt.Errorf("money: cannot scan %T into Rate", src)
+	}
+
+	parsed, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("money: cannot scan %q into Rate", s)
+	}
+	*r = Rate{r: parsed}
+	return nil
+}
+
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
@@ -1,28 +1,36 @@
 package routes
 
 import (
-	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 	"bank-ledger-core/handlers"
 	"bank-ledger-core/middleware"
-	"bank-ledger-core/services"
+	"bank-ledger-core/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func SetupRoutes(db *gorm.DB) *gin.Engine {
+func SetupRoutes(db *gorm.DB, svc *Services) *gin.Engine {
 	r := gin.Default()
+	r.Use(middleware.RequestID())
 
-	// Initialize services and handlers
-	transferService := services.NewTransferService(db)
-	orderService := services.NewOrderService(db, transferService)
-	historyService := services.NewHistoryService(db)
-	
 	// Handlers
-	accountHandler := handlers.NewAccountHandler(db)
-	productHandler := handlers.NewProductHandler(db)
-	orderHandler := handlers.NewOrderHandler(orderService)
-	transferHandler := handlers.NewTransferHandler(transferService)
-	historyHandler := handlers.NewHistoryHandler(historyService)
-	authHandler := handlers.NewAuthHandler(db)
+	accountHandler := handlers.NewAccountHandler(db, svc.Journal, svc.Account, svc.Audit)
+	productHandler := handlers.NewProductHandler(db, svc.Audit)
+	orderHandler := handlers.NewOrderHandler(db, svc.Order)
+	transferHandler := handlers.NewTransferHandler(svc.Transfer)
+	historyHandler := handlers.NewHistoryHandler(svc.History)
+	statementHandler := handlers.NewStatementHandler(svc.History)
+	authHandler := handlers.NewAuthHandler(db, svc.Audit, svc.TOTP, svc.LoginThrottle, svc.Session, svc.Password)
+	ledgerHandler := handlers.NewLedgerHandler(svc.Journal)
+	fxHandler := handlers.NewFXHandler(svc.FX)
+	holdHandler := handlers.NewHoldHandler(svc.Hold)
+	auditHandler := handlers.NewAuditHandler(svc.Audit)
+	apiKeyHandler := handlers.NewAPIKeyHandler(svc.APIKey)
+	totpHandler := handlers.NewTOTPHandler(svc.TOTP)
+	sessionHandler := handlers.NewSessionHandler(svc.Session)
+	passwordHandler := handlers.NewPasswordHandler(svc.Password)
+	reconciliationHandler := handlers.NewReconciliationHandler(svc.Reconciliation)
+	closeHandler := handlers.NewCloseHandler(svc.Close)
+	scheduledTransferHandler := handlers.NewScheduledTransferHandler(svc.ScheduledTransfer)
 
 	api := r.Group("/api/v1")
 	{
@@ -31,42 +39,117 @@ func SetupRoutes(db *gorm.DB) *gin.Engine {
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/login/verify", authHandler.VerifyLogin)
 			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/password/reset-request", passwordHandler.RequestReset)
+			auth.POST("/password/reset", passwordHandler.Reset)
 		}
 
 		// Protected routes
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware(db))
+		protected.Use(middleware.AuthMiddleware(db, svc.APIKey, svc.Session))
 		{
+			can := middleware.RequirePermission
+
 			accounts := protected.Group("/accounts")
 			{
-				accounts.POST("", accountHandler.CreateAccount)
-				accounts.GET("", accountHandler.GetAccounts)
-				accounts.GET("/:id", accountHandler.GetAccount)
+				accounts.POST("", can(models.PermissionAccountsCreate), accountHandler.CreateAccount)
+				accounts.GET("", can(models.PermissionAccountsList), accountHandler.GetAccounts)
+				accounts.GET("/:id", can(models.PermissionAccountsRead), accountHandler.GetAccount)
+				accounts.GET("/:id/history", can(models.PermissionHistoryRead), historyHandler.GetAccountHistory)
+				accounts.GET("/:id/statement", can(models.PermissionHistoryRead), statementHandler.GetStatement)
+				accounts.GET("/:id/balances", can(models.PermissionHistoryRead), closeHandler.GetBalances)
+			}
+
+			users := protected.Group("/users/:user_id")
+			{
+				users.GET("/accounts", can(models.PermissionAccountsRead), accountHandler.ListUserAccounts)
+				users.POST("/accounts", can(models.PermissionAccountsOpen), accountHandler.OpenAccount)
+			}
+
+			authenticated := protected.Group("/auth")
+			{
+				authenticated.GET("/me", authHandler.Me)
+				authenticated.POST("/totp/enroll", totpHandler.Enroll)
+				authenticated.POST("/totp/confirm", totpHandler.Confirm)
+				authenticated.POST("/totp/disable", totpHandler.Disable)
+				authenticated.POST("/totp/recovery-codes", totpHandler.RegenerateRecoveryCodes)
+				authenticated.POST("/step-up", totpHandler.StepUp)
+				authenticated.POST("/password", passwordHandler.Change)
+				authenticated.GET("/sessions", sessionHandler.List)
+				authenticated.DELETE("/sessions", sessionHandler.RevokeAll)
+				authenticated.DELETE("/sessions/:id", sessionHandler.Revoke)
+			}
+
+			apiKeys := protected.Group("/api-keys")
+			apiKeys.Use(can(models.PermissionAPIKeysManage))
+			{
+				apiKeys.POST("", apiKeyHandler.Create)
+				apiKeys.GET("", apiKeyHandler.List)
+				apiKeys.DELETE("/:id", apiKeyHandler.Revoke)
+				apiKeys.POST("/:id/rotate", apiKeyHandler.Rotate)
 			}
 
 			products := protected.Group("/products")
 			{
 				products.GET("", productHandler.GetProducts)
 				products.GET("/:id", productHandler.GetProduct)
-				products.POST("", productHandler.CreateProduct)
+				products.POST("", can(models.PermissionProductsWrite), productHandler.CreateProduct)
 			}
 
 			orders := protected.Group("/orders")
 			{
-				orders.POST("", orderHandler.CreateOrder)
-				orders.GET("", orderHandler.GetOrders)
-				orders.GET("/:id", orderHandler.GetOrder)
+				orders.POST("", can(models.PermissionOrdersWrite), middleware.Idempotency(db), orderHandler.CreateOrder)
+				orders.GET("", can(models.PermissionHistoryRead), orderHandler.GetOrders)
+				orders.GET("/:id", can(models.PermissionHistoryRead), orderHandler.GetOrder)
+				orders.POST("/:id/refund", can(models.PermissionOrdersRefund), middleware.Idempotency(db), orderHandler.RefundOrder)
 			}
 
 			transfers := protected.Group("/transfers")
 			{
-				transfers.POST("/money", transferHandler.TransferMoney)
-				transfers.POST("/money/users", transferHandler.TransferMoneyByUserIDs)
+				transfers.POST("/money", can(models.PermissionTransfersWrite), middleware.Idempotency(db), transferHandler.TransferMoney)
+				transfers.POST("/money/users", can(models.PermissionTransfersWrite), middleware.Idempotency(db), transferHandler.TransferMoneyByUserIDs)
+				transfers.POST("/:id/reverse", can(models.PermissionTransfersReverse), middleware.Idempotency(db), transferHandler.ReverseTransfer)
+				transfers.POST("/scheduled", can(models.PermissionTransfersWrite), middleware.Idempotency(db), scheduledTransferHandler.Schedule)
+				transfers.GET("/scheduled", can(models.PermissionHistoryRead), scheduledTransferHandler.List)
+				transfers.GET("/scheduled/:id", can(models.PermissionHistoryRead), scheduledTransferHandler.Get)
+				transfers.DELETE("/scheduled/:id", can(models.PermissionTransfersWrite), scheduledTransferHandler.Cancel)
+			}
+
+			holds := protected.Group("/holds")
+			holds.Use(can(models.PermissionHoldsWrite))
+			{
+				holds.POST("", middleware.Idempotency(db), holdHandler.Authorize)
+				holds.GET("/:id", holdHandler.GetHold)
+				holds.POST("/:id/capture", middleware.Idempotency(db), holdHandler.Capture)
+				holds.POST("/:id/void", holdHandler.Void)
 			}
 
-			// Separate route for account history to avoid conflicts
-			protected.GET("/users/:user_id/history", historyHandler.GetAccountHistory)
+			fx := protected.Group("/fx")
+			{
+				fx.GET("/rates", fxHandler.GetRates)
+				fx.POST("/rates", can(models.PermissionFXRatesWrite), fxHandler.SetRate)
+				fx.POST("/rates/import", can(models.PermissionFXRatesWrite), fxHandler.ImportRates)
+				fx.POST("/quotes", can(models.PermissionFXQuote), fxHandler.CreateQuote)
+				fx.GET("/quotes/:id", can(models.PermissionFXQuote), fxHandler.GetQuote)
+			}
+
+			protected.GET("/ledger/check", can(models.PermissionLedgerRead), ledgerHandler.Check)
+
+			admin := protected.Group("/admin")
+			{
+				admin.PUT("/accounts/:id/status", can(models.PermissionAccountsStatus), accountHandler.ChangeStatus)
+				admin.PUT("/users/:user_id/role", can(models.PermissionRolesAssign), accountHandler.SetRole)
+				admin.DELETE("/users/:user_id/lockout", can(models.PermissionLoginsUnlock), authHandler.Unlock)
+				admin.GET("/audit", can(models.PermissionAuditRead), auditHandler.GetEvents)
+				admin.GET("/audit/verify", can(models.PermissionAuditRead), auditHandler.Verify)
+				admin.POST("/reconciliations", can(models.PermissionLedgerReconcile), reconciliationHandler.Run)
+				admin.GET("/reconciliations", can(models.PermissionLedgerRead), reconciliationHandler.List)
+				admin.GET("/reconciliations/:id", can(models.PermissionLedgerRead), reconciliationHandler.Get)
+				admin.POST("/closes", can(models.PermissionLedgerClose), closeHandler.Close)
+				admin.GET("/closes", can(models.PermissionLedgerRead), closeHandler.ListCloses)
+				admin.GET("/closes/:date", can(models.PermissionLedgerRead), closeHandler.GetClose)
+			}
 		}
 	}
 
@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"bank-ledger-core/config"
+	"bank-ledger-core/notify"
+	"bank-ledger-core/services"
+	"gorm.io/gorm"
+)
+
+// Services are built once from the environment and shared by the API and
+// the background workers, so that both run with the same configuration.
+type Services struct {
+	Journal           *services.JournalService
+	FX                *services.FXService
+	Transfer          *services.TransferService
+	Order             *services.OrderService
+	Hold              *services.HoldService
+	History           *services.HistoryService
+	Account           *services.AccountService
+	Audit             *services.AuditService
+	APIKey            *services.APIKeyService
+	Session           *services.SessionService
+	Password          *services.PasswordService
+	TOTP              *services.TOTPService
+	Reconciliation    *services.ReconciliationService
+	Close             *services.CloseService
+	ScheduledTransfer *services.ScheduledTransferService
+	LoginThrottle     *services.LoginThrottleService
+}
+
+func NewServices(db *gorm.DB) *Services {
+	fxConfig := config.GetFXConfig()
+	holdConfig := config.GetHoldConfig()
+	authConfig := config.GetAuthConfig()
+	loginConfig := config.GetLoginConfig()
+	sessionConfig := config.GetSessionConfig()
+	passwordConfig := config.GetPasswordConfig()
+	reconciliationConfig := config.GetReconciliationConfig()
+	scheduledTransferConfig := config.GetScheduledTransferConfig()
+	stepUp := services.StepUpPolicy{Thresholds: authConfig.StepUpThresholds, Window: authConfig.StepUpWindow}
+
+	s := &Services{}
+	s.Journal = services.NewJournalService(db)
+	s.FX = services.NewFXService(db, fxConfig.SpreadBps, fxConfig.QuoteTTL)
+	s.Transfer = services.NewTransferService(db, s.Journal, s.FX, stepUp)
+	s.Order = services.NewOrderService(db, s.Transfer, s.Journal)
+	s.Hold = services.NewHoldService(db, s.Journal, holdConfig.TTL, stepUp)
+	s.History = services.NewHistoryService(db, s.Journal)
+	s.Account = services.NewAccountService(db)
+	s.Audit = services.NewAuditService(db)
+	s.APIKey = services.NewAPIKeyService(db)
+	s.Session = services.NewSessionService(db, services.SystemClock, services.SessionConfig{
+		IdleTimeout: sessionConfig.IdleTimeout,
+		MaxLifetime: sessionConfig.MaxLifetime,
+		PendingTTL:  sessionConfig.MFATimeout,
+	})
+	s.Password = services.NewPasswordService(db, services.SystemClock, newNotifier(passwordConfig), services.PasswordPolicy{
+		MinLength:        passwordConfig.MinLength,
+		RequireMixedCase: passwordConfig.RequireMixedCase,
+		RequireDigit:     passwordConfig.RequireDigit,
+		RequireSymbol:    passwordConfig.RequireSymbol,
+	}, passwordConfig.ResetTTL)
+	s.TOTP = services.NewTOTPService(db, authConfig.TOTPIssuer)
+	s.Reconciliation = services.NewReconciliationService(db, services.SystemClock, reconciliationConfig.SigningKey)
+	s.Close = services.NewCloseService(db, services.SystemClock)
+	s.ScheduledTransfer = services.NewScheduledTransferService(db, s.Transfer, services.SystemClock, scheduledTransferConfig.MaxAhead)
+	s.LoginThrottle = services.NewLoginThrottleService(db, services.SystemClock, services.LoginThrottleConfig{
+		User:        services.ThrottleLimits{FreeAttempts: 3, MaxFailures: loginConfig.MaxFailures},
+		IP:          services.ThrottleLimits{FreeAttempts: 10, MaxFailures: loginConfig.IPMaxFailures},
+		BackoffBase: loginConfig.BackoffBase,
+		BackoffMax:  loginConfig.BackoffMax,
+		Lockout:     loginConfig.Lockout,
+	})
+	return s
+}
+
+// newNotifier picks how messages such as password reset tokens reach users.
+func newNotifier(cfg *config.PasswordConfig) notify.Notifier {
+	if cfg.Notifier == "file" {
+		return notify.NewFileNotifier(cfg.NotifierFile)
+	}
+	return notify.LogNotifier{}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+type AccountService struct {
+	db *gorm.DB
+}
+
+func NewAccountService(db *gorm.DB) *AccountService {
+	return &AccountService{db: db}
+}
+
+type SetRoleRequest struct {
+	Role models.Role `json:"role" binding:"required"`
+}
+
+// OpenAccountRequest opens an empty account. Type defaults to current.
+type OpenAccountRequest struct {
+	Currency string             `json:"currency" binding:"required,len=3"`
+	Type     models.AccountType `json:"type"`
+	Name     string             `json:"name" binding:"max=100"`
+}
+
+type ChangeStatusRequest struct {
+	Status models.AccountStatus `json:"status" binding:"required"`
+	Reason string               `json:"reason" binding:"required,max=255"`
+}
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrReservedUserID  = errors.New("user ID is reserved for the ledger")
+	ErrAccountExists   = errors.New("user already has a current account in this currency")
+	ErrAccountNotFound = errors.New("account not found")
+	ErrAccountFrozen   = errors.New("account is frozen")
+	ErrAccountClosed   = errors.New("account is closed")
+)
+
+// accountTransitions lists the statuses each status may move to.
+var accountTransitions = map[models.AccountStatus][]models.AccountStatus{
+	models.AccountStatusActive: {models.AccountStatusFrozen, models.AccountStatusClosed},
+	models.AccountStatusFrozen: {models.AccountStatusActive},
+}
+
+// ChangeStatus moves an account to a new status. Closing requires a zero
+// ledger balance and no funds on hold.
+func (s *AccountService) ChangeStatus(actor Actor, accountID uint, req ChangeStatusRequest) (*models.Account, error) {
+	if !req.Status.IsValid() {
+		return nil, reject(fmt.Errorf("unknown account status %q", req.Status))
+	}
+	var account *models.Account
+
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		accounts, err := lockAccounts(tx, accountID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccountNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+		account = accounts[accountID]
+
+		if !canTransition(account.Status, req.Status) {
+			return fmt.Errorf("cannot change account status from %s to %s", account.Status, req.Status)
+		}
+		if req.Status == models.AccountStatusClosed && (!account.Balance.IsZero() || !account.HeldBalance.IsZero()) {
+			return fmt.Errorf("account can only be closed at a zero balance, current balance is %s", account.Balance)
+		}
+
+		before := *account
+		now := time.Now()
+		result := tx.Model(&models.Account{}).
+			Where("id = ? AND version = ?", account.ID, account.Version).
+			Updates(map[string]interface{}{
+				"status":            req.Status,
+				"status_reason":     req.Reason,
+				"status_changed_at": now,
+				"version":           gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update account status: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrConcurrentUpdate
+		}
+
+		account.Status = req.Status
+		account.StatusReason = req.Reason
+		account.StatusChangedAt = &now
+		account.Version++
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAccountStatus, EntityType: "account", EntityID: account.ID, Before: before, After: account})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// OpenAccount opens an empty account for the user. A user has at most one
+// open current account per currency, which is where money addressed to the
+// user in that currency goes; savings accounts are not limited.
+func (s *AccountService) OpenAccount(actor Actor, userID string, req OpenAccountRequest) (*models.Account, error) {
+	if err := actor.Authorize(s.db, userID, "open an account"); err != nil {
+		return nil, err
+	}
+	if req.Type == "" {
+		req.Type = models.AccountTypeCurrent
+	}
+	if !req.Type.IsValid() {
+		return nil, fmt.Errorf("unknown account type %q", req.Type)
+	}
+
+	account := models.Account{
+		UserID:   userID,
+		Type:     req.Type,
+		Name:     req.Name,
+		Currency: strings.ToUpper(req.Currency),
+	}
+	account.Balance = money.FromMinor(0, account.Currency)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.Create(tx, &account); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAccountCreate, EntityType: "account", EntityID: account.ID, After: account})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListAccounts returns the user's accounts, oldest first.
+func (s *AccountService) ListAccounts(actor Actor, userID string) ([]models.Account, error) {
+	if err := actor.Authorize(s.db, userID, "view accounts"); err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("id = ?", userID).First(&models.User{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	var accounts []models.Account
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// SetRole assigns a role to the user.
+func (s *AccountService) SetRole(actor Actor, userID string, role models.Role) error {
+	if !role.IsValid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var previous models.User
+		if err := tx.Where("id = ?", userID).First(&previous).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return fmt.Errorf("failed to find user: %w", err)
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("role", role).Error; err != nil {
+			return fmt.Errorf("failed to set role: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditUserRole,
+			EntityType: "user",
+			EntityID:   userID,
+			Before:     map[string]interface{}{"role": previous.Role},
+			After:      map[string]interface{}{"role": role},
+		})
+	})
+}
+
+// Create inserts the account inside tx after checking that its owner exists,
+// is not one of the ledger's own users and has no other open current account
+// in the currency, and that the ledger can store the currency.
+func (s *AccountService) Create(tx *gorm.DB, account *models.Account) error {
+	if account.Type == "" {
+		account.Type = models.AccountTypeCurrent
+	}
+	if models.IsReservedUserID(account.UserID) {
+		return fmt.Errorf("%w: %s", ErrReservedUserID, account.UserID)
+	}
+	if err := money.CheckCurrency(account.Currency); err != nil {
+		return err
+	}
+	if err := tx.Where("id = ?", account.UserID).First(&models.User{}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	if account.Type == models.AccountTypeCurrent {
+		var existing int64
+		err := tx.Model(&models.Account{}).
+			Where("user_id = ? AND currency = ? AND type = ? AND status <> ?", account.UserID, account.Currency, models.AccountTypeCurrent, models.AccountStatusClosed).
+			Count(&existing).Error
+		if err != nil {
+			return fmt.Errorf("failed to check accounts: %w", err)
+		}
+		if existing > 0 {
+			return ErrAccountExists
+		}
+	}
+	if err := tx.Create(account).Error; err != nil {
+		return fmt.Errorf("failed to create account: %w", err)
+	}
+	return nil
+}
+
+// primaryAccount returns the account that receives money addressed to a user
+// in a currency: the user's open current account in it.
+func primaryAccount(tx *gorm.DB, userID, currency string) (*models.Account, error) {
+	var account models.Account
+	err := tx.Where("user_id = ? AND currency = ? AND type = ? AND status <> ?", userID, currency, models.AccountTypeCurrent, models.AccountStatusClosed).
+		Order("id").First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, reject(fmt.Errorf("%w: %s has no %s account", ErrAccountNotFound, userID, currency))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find account: %w", err)
+	}
+	return &account, nil
+}
+
+// marketplaceAccount returns the open account of the system user, which
+// receives order payments and pays refunds.
+func marketplaceAccount(tx *gorm.DB) (*models.Account, error) {
+	var account models.Account
+	err := tx.Where("user_id = ? AND status <> ?", models.SystemUserID, models.AccountStatusClosed).
+		Order("id DESC").First(&account).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find system account: %w", err)
+	}
+	return &account, nil
+}
+
+// SystemAccountRotation is the outcome of RotateSystemAccount.
+type SystemAccountRotation struct {
+	Previous *models.Account `json:"previous"`
+	Current  *models.Account `json:"current"`
+}
+
+// RotateSystemAccount replaces the marketplace account with a new one in the
+// same currency: the balance moves over in an adjustment entry and the old
+// account is closed. Funds on hold must be settled first.
+func (s *AccountService) RotateSystemAccount(actor Actor, reason string) (*SystemAccountRotation, error) {
+	if !actor.Can(models.PermissionLedgerAdjust) {
+		return nil, fmt.Errorf("%w: cannot rotate the system account", ErrForbidden)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("rotating the system account needs a reason")
+	}
+
+	var rotation *SystemAccountRotation
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		current, err := marketplaceAccount(tx)
+		if err != nil {
+			return err
+		}
+		accounts, err := lockAccounts(tx, current.ID)
+		if err != nil {
+			return fmt.Errorf("failed to lock system account: %w", err)
+		}
+		previous := accounts[current.ID]
+		if !previous.HeldBalance.IsZero() {
+			return fmt.Errorf("system account %d has %s on hold", previous.ID, previous.HeldBalance)
+		}
+		if previous.Balance.IsNegative() {
+			return fmt.Errorf("system account %d has a negative balance of %s", previous.ID, previous.Balance)
+		}
+		before := *previous
+
+		next := &models.Account{
+			UserID:   models.SystemUserID,
+			Type:     previous.Type,
+			Name:     previous.Name,
+			Currency: previous.Currency,
+			Balance:  money.FromMinor(0, previous.Currency),
+		}
+		if err := tx.Create(next).Error; err != nil {
+			return fmt.Errorf("failed to create system account: %w", err)
+		}
+		if previous.Balance.IsPositive() {
+			description := fmt.Sprintf("System account rotation: %s", reason)
+			if _, err := NewJournalService(tx).Move(tx, models.EntryTypeAdjustment, nil, previous.ID, next.ID, previous.Balance, description); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.Account{}).
+			Where("id = ?", previous.ID).
+			Updates(map[string]interface{}{
+				"status":            models.AccountStatusClosed,
+				"status_reason":     reason,
+				"status_changed_at": now,
+				"version":           gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to close system account: %w", result.Error)
+		}
+
+		rotation = &SystemAccountRotation{Previous: &models.Account{}, Current: &models.Account{}}
+		if err := tx.First(rotation.Previous, previous.ID).Error; err != nil {
+			return fmt.Errorf("failed to reload system account: %w", err)
+		}
+		if err := tx.First(rotation.Current, next.ID).Error; err != nil {
+			return fmt.Errorf("failed to reload system account: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAccountRotate, EntityType: "account", EntityID: previous.ID, Before: before, After: rotation})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rotation, nil
+}
+
+func canTransition(from, to models.AccountStatus) bool {
+	if from == "" {
+		from = models.AccountStatusActive
+	}
+	for _, allowed := range accountTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCanSend returns why money may not leave the account, if it may not.
+func checkCanSend(account *models.Account) error {
+	if account.CanSend() {
+		return nil
+	}
+	if account.Status == models.AccountStatusClosed {
+		return reject(fmt.Errorf("%w: account %d", ErrAccountClosed, account.ID))
+	}
+	return reject(fmt.Errorf("%w: account %d", ErrAccountFrozen, account.ID))
+}
+
+// checkCanReceive returns why money may not enter the account, if it may not.
+func checkCanReceive(account *models.Account) error {
+	if account.CanReceive() {
+		return nil
+	}
+	return reject(fmt.Errorf("%w: account %d", ErrAccountClosed, account.ID))
+}
+
+// checkMovement checks both sides of a movement between two accounts.
+func checkMovement(fromAccount, toAccount *models.Account) error {
+	if err := checkCanSend(fromAccount); err != nil {
+		return err
+	}
+	return checkCanReceive(toAccount)
+}
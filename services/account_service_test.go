@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+)
+
+func TestOpenAccountsAndTransferToUser(t *testing.T) {
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	createFundedAccount(t, db, "bob", "USD", "0")
+	bob := Actor{UserID: "bob", Role: models.RoleCustomer}
+
+	savings, err := accounts.OpenAccount(bob, "bob", OpenAccountRequest{Currency: "uzs", Type: models.AccountTypeSavings})
+	if err != nil {
+		t.Fatalf("open savings: %v", err)
+	}
+	if savings.Currency != "UZS" || savings.Type != models.AccountTypeSavings {
+		t.Fatalf("savings = %+v", savings)
+	}
+
+	// Without a current account in the currency a user cannot be paid in it
+	if _, err := transfers.TransferMoneyByUserIDs(testOperator, UserTransferRequest{FromAccountID: alice.ID, ToUserID: "bob", Amount: "10.00"}); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("transfer without a current account = %v, want ErrAccountNotFound", err)
+	}
+
+	current, err := accounts.OpenAccount(bob, "bob", OpenAccountRequest{Currency: "UZS"})
+	if err != nil {
+		t.Fatalf("open current: %v", err)
+	}
+	if _, err := accounts.OpenAccount(bob, "bob", OpenAccountRequest{Currency: "UZS"}); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("second current account = %v, want ErrAccountExists", err)
+	}
+	if _, err := accounts.OpenAccount(bob, "alice", OpenAccountRequest{Currency: "UZS"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("opening for another user = %v, want ErrForbidden", err)
+	}
+	if _, err := accounts.OpenAccount(testOperator, "nobody", OpenAccountRequest{Currency: "UZS"}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("opening for an unknown user = %v, want ErrUserNotFound", err)
+	}
+	// Three-decimal currencies would lose their last digit in the columns
+	if _, err := accounts.OpenAccount(bob, "bob", OpenAccountRequest{Currency: "KWD"}); !errors.Is(err, money.ErrUnsupportedCurrency) {
+		t.Fatalf("opening a KWD account = %v, want ErrUnsupportedCurrency", err)
+	}
+
+	owner := Actor{UserID: "alice", Role: models.RoleCustomer}
+	if _, err := transfers.TransferMoneyByUserIDs(owner, UserTransferRequest{FromAccountID: alice.ID, ToUserID: "bob", Amount: "10.00"}); err != nil {
+		t.Fatalf("transfer to user: %v", err)
+	}
+	if got := balanceOf(t, db, current.ID); got.String() != "10.00" {
+		t.Fatalf("current balance = %s, want 10.00", got)
+	}
+	if got := balanceOf(t, db, savings.ID); !got.IsZero() {
+		t.Fatalf("savings balance = %s, want 0", got)
+	}
+	if _, err := transfers.TransferMoneyByUserIDs(owner, UserTransferRequest{FromAccountID: alice.ID, ToUserID: "bob", ToCurrency: "USD", Amount: "10.00"}); err == nil {
+		t.Fatal("cross-currency transfer to user without a quote succeeded")
+	}
+
+	list, err := accounts.ListAccounts(bob, "bob")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 3 || list[1].ID != savings.ID || list[2].ID != current.ID {
+		t.Fatalf("accounts = %+v", list)
+	}
+	if _, err := accounts.ListAccounts(owner, "bob"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("listing another user's accounts = %v, want ErrForbidden", err)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+// The marketplace and system users exist from the start, so their IDs can
+// neither be registered nor given accounts through the service.
+func TestReservedUsers(t *testing.T) {
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+
+	for _, userID := range []string{models.SystemUserID, models.IssuanceUserID, models.FXUserID} {
+		var user models.User
+		if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+			t.Fatalf("user %s was not seeded: %v", userID, err)
+		}
+		if _, err := accounts.OpenAccount(testOperator, userID, OpenAccountRequest{Currency: "USD"}); !errors.Is(err, ErrReservedUserID) {
+			t.Fatalf("opening an account for %s = %v, want ErrReservedUserID", userID, err)
+		}
+	}
+	if _, err := accounts.OpenAccount(testOperator, "system:other", OpenAccountRequest{Currency: "USD"}); !errors.Is(err, ErrReservedUserID) {
+		t.Fatalf("opening an account under the system prefix = %v, want ErrReservedUserID", err)
+	}
+}
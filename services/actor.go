@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"bank-ledger-core/models"
+
+	"gorm.io/gorm"
+)
+
+// Actor is the authenticated user a service call runs for. Money can only
+// leave, and history can only be read from, accounts the actor owns unless
+// the actor's role may override ownership and the request asked for it with
+// OverrideReason. Scopes, when not nil, further restrict the role to what an
+// API key was granted. StepUpAt is when the session last passed a two-factor
+// check. SessionID, APIKeyID, IP and RequestID are only recorded in the
+// audit log.
+type Actor struct {
+	UserID         string
+	Role           models.Role
+	Scopes         models.PermissionList
+	SessionID      string
+	APIKeyID       string
+	IP             string
+	RequestID      string
+	StepUpAt       *time.Time
+	OverrideReason string
+}
+
+// SystemActor runs background jobs and startup tasks.
+var SystemActor = Actor{UserID: "system", Role: models.RoleAdmin}
+
+var ErrForbidden = errors.New("forbidden")
+
+// ownershipOverride is the audit record of acting for another user.
+type ownershipOverride struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// Authorize checks that the actor may perform action on something owned by
+// ownerUserID. Acting for another user needs the ownership:override
+// permission and an override reason on the request; every such use is
+// written to the audit log through db, so inside a transaction the entry
+// only stays if the action does.
+func (a Actor) Authorize(db *gorm.DB, ownerUserID, action string) error {
+	if err := a.permits(ownerUserID, action); err != nil || a.owns(ownerUserID) {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return recordAudit(tx, a, AuditRecord{
+			Action:     models.AuditOwnershipOverride,
+			EntityType: "user",
+			EntityID:   ownerUserID,
+			After:      ownershipOverride{Action: action, Reason: a.OverrideReason},
+		})
+	})
+}
+
+// permits is Authorize without the audit entry, for rejecting a request
+// before the locks that have to be taken ahead of the audit chain head.
+func (a Actor) permits(ownerUserID, action string) error {
+	if a.owns(ownerUserID) {
+		return nil
+	}
+	if !a.Can(models.PermissionOwnershipOverride) {
+		return fmt.Errorf("%w: cannot %s for another user", ErrForbidden, action)
+	}
+	if a.OverrideReason == "" {
+		return fmt.Errorf("%w: cannot %s for another user without an ownership override", ErrForbidden, action)
+	}
+	return nil
+}
+
+func (a Actor) owns(ownerUserID string) bool {
+	return a.UserID != "" && a.UserID == ownerUserID
+}
+
+// Can reports whether both the role and, for API keys, the key's scopes grant
+// the permission.
+func (a Actor) Can(permission models.Permission) bool {
+	if !a.Role.Can(permission) {
+		return false
+	}
+	return a.Scopes == nil || a.Scopes.Contains(permission)
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// apiKeyTouchInterval limits how often last-used tracking writes to a key.
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+// CreateAPIKeyRequest creates a key for the acting user. Scopes may only
+// include permissions the user's role grants; ExpiresIn is a Go duration.
+type CreateAPIKeyRequest struct {
+	Name      string              `json:"name" binding:"required,max=100"`
+	Scopes    []models.Permission `json:"scopes" binding:"required,min=1"`
+	ExpiresIn string              `json:"expires_in"`
+}
+
+// RotateAPIKeyRequest replaces a key. The old key keeps working for
+// GracePeriod, a Go duration, so clients can switch over.
+type RotateAPIKeyRequest struct {
+	GracePeriod string `json:"grace_period"`
+}
+
+// CreatedAPIKey carries the token, which is shown only once.
+type CreatedAPIKey struct {
+	models.APIKey
+	Token string `json:"token"`
+}
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key has been revoked")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+)
+
+func (s *APIKeyService) Create(actor Actor, req CreateAPIKeyRequest) (*CreatedAPIKey, error) {
+	scopes, err := apiKeyScopes(actor, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid expires_in %q", req.ExpiresIn)
+		}
+		expiry := time.Now().Add(ttl)
+		expiresAt = &expiry
+	}
+
+	key, token, err := newAPIKey(actor.UserID, req.Name, scopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(key).Error; err != nil {
+			return fmt.Errorf("failed to store api key: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAPIKeyCreate, EntityType: "api_key", EntityID: key.ID, After: key})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: *key, Token: token}, nil
+}
+
+// List returns the acting user's keys, including revoked and expired ones.
+func (s *APIKeyService) List(actor Actor) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Where("user_id = ?", actor.UserID).Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *APIKeyService) Revoke(actor Actor, id uint) (*models.APIKey, error) {
+	var key *models.APIKey
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		key, err = lockAPIKey(tx, actor, id, "revoke an API key")
+		if err != nil {
+			return err
+		}
+		before := *key
+		now := time.Now()
+		if key.RevokedAt == nil || key.RevokedAt.After(now) {
+			key.RevokedAt = &now
+		}
+		if err := tx.Model(key).Update("revoked_at", key.RevokedAt).Error; err != nil {
+			return fmt.Errorf("failed to revoke api key: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAPIKeyRevoke, EntityType: "api_key", EntityID: key.ID, Before: before, After: key})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Rotate issues a replacement with the same name, scopes and lifetime, and
+// revokes the old key once the grace period ends.
+func (s *APIKeyService) Rotate(actor Actor, id uint, req RotateAPIKeyRequest) (*CreatedAPIKey, error) {
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		parsed, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid grace_period %q", req.GracePeriod)
+		}
+		grace = parsed
+	}
+
+	var created *CreatedAPIKey
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		old, err := lockAPIKey(tx, actor, id, "rotate an API key")
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if !old.IsActive(now) {
+			return ErrAPIKeyRevoked
+		}
+
+		var expiresAt *time.Time
+		if old.ExpiresAt != nil {
+			expiry := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+			expiresAt = &expiry
+		}
+		key, token, err := newAPIKey(old.UserID, old.Name, old.Scopes, expiresAt)
+		if err != nil {
+			return err
+		}
+		key.RotatedFromID = &old.ID
+		if err := tx.Create(key).Error; err != nil {
+			return fmt.Errorf("failed to store api key: %w", err)
+		}
+
+		before := *old
+		revokeAt := now.Add(grace)
+		old.RevokedAt = &revokeAt
+		if err := tx.Model(old).Update("revoked_at", revokeAt).Error; err != nil {
+			return fmt.Errorf("failed to revoke api key: %w", err)
+		}
+		err = recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditAPIKeyRotate,
+			EntityType: "api_key",
+			EntityID:   old.ID,
+			Before:     before,
+			After:      map[string]interface{}{"key": old, "replacement": key},
+		})
+		if err != nil {
+			return err
+		}
+
+		created = &CreatedAPIKey{APIKey: *key, Token: token}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Authenticate resolves a token to its key and records the use. Every
+// failure is reported as ErrInvalidAPIKey so callers cannot probe for keys.
+func (s *APIKeyService) Authenticate(token, ip string) (*models.APIKey, error) {
+	keyID, secret, ok := parseAPIKeyToken(token)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	if err := s.db.Where("key_id = ?", keyID).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("failed to load api key: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval || key.LastUsedIP != ip {
+		err := s.db.Model(&models.APIKey{}).Where("id = ?", key.ID).
+			Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": ip}).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to record api key use: %w", err)
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIP = ip
+	}
+	return &key, nil
+}
+
+func lockAPIKey(tx *gorm.DB, actor Actor, id uint, action string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&key, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to lock api key: %w", err)
+	}
+	if err := actor.Authorize(tx, key.UserID, action); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// apiKeyScopes validates and deduplicates requested scopes. A key can never
+// do more than the user creating it.
+func apiKeyScopes(actor Actor, requested []models.Permission) (models.PermissionList, error) {
+	scopes := models.PermissionList{}
+	for _, scope := range requested {
+		if !scope.IsValid() {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !actor.Can(scope) {
+			return nil, fmt.Errorf("%w: your role does not grant %s", ErrForbidden, scope)
+		}
+		if !scopes.Contains(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func newAPIKey(userID, name string, scopes models.PermissionList, expiresAt *time.Time) (*models.APIKey, string, error) {
+	id := make([]byte, 12)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	keyID := hex.EncodeToString(id)
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.APIKey{
+		UserID:     userID,
+		Name:       name,
+		KeyID:      keyID,
+		SecretHash: hashAPIKeySecret(encodedSecret),
+		Scopes:     scopes,
+		ExpiresAt:  expiresAt,
+	}
+	return key, models.APIKeyPrefix + keyID + "_" + encodedSecret, nil
+}
+
+func parseAPIKeyToken(token string) (string, string, bool) {
+	if !strings.HasPrefix(token, models.APIKeyPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, models.APIKeyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// hashAPIKeySecret needs no salt or stretching: secrets are 256 random bits.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	keys := NewAPIKeyService(db)
+	alice := Actor{UserID: "alice", Role: models.RoleCustomer}
+
+	if _, err := keys.Create(alice, CreateAPIKeyRequest{Name: "ops", Scopes: []models.Permission{models.PermissionAccountsList}}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("scope beyond role = %v, want ErrForbidden", err)
+	}
+	if _, err := keys.Create(alice, CreateAPIKeyRequest{Name: "typo", Scopes: []models.Permission{"transfers:wirte"}}); err == nil {
+		t.Fatal("unknown scope accepted")
+	}
+
+	created, err := keys.Create(alice, CreateAPIKeyRequest{
+		Name:   "payroll",
+		Scopes: []models.Permission{models.PermissionTransfersWrite, models.PermissionHistoryRead, models.PermissionTransfersWrite},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if len(created.Scopes) != 2 || created.SecretHash == created.Token {
+		t.Fatalf("created = %+v", created)
+	}
+
+	key, err := keys.Authenticate(created.Token, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if key.UserID != "alice" || key.LastUsedAt == nil || key.LastUsedIP != "10.0.0.1" {
+		t.Fatalf("authenticated key = %+v", key)
+	}
+	for _, token := range []string{created.Token + "x", "blk_" + created.KeyID + "_wrong", "session", ""} {
+		if _, err := keys.Authenticate(token, "10.0.0.1"); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Fatalf("authenticate %q = %v, want ErrInvalidAPIKey", token, err)
+		}
+	}
+
+	if _, err := keys.Revoke(Actor{UserID: "bob", Role: models.RoleCustomer}, created.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("revoke by another user = %v, want ErrForbidden", err)
+	}
+
+	// The old key keeps working through the grace period only
+	rotated, err := keys.Rotate(alice, created.ID, RotateAPIKeyRequest{GracePeriod: "1h"})
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if rotated.RotatedFromID == nil || *rotated.RotatedFromID != created.ID || rotated.Name != "payroll" {
+		t.Fatalf("rotated = %+v", rotated)
+	}
+	if _, err := keys.Authenticate(created.Token, ""); err != nil {
+		t.Fatalf("old key during grace period: %v", err)
+	}
+	if _, err := keys.Authenticate(rotated.Token, ""); err != nil {
+		t.Fatalf("new key: %v", err)
+	}
+
+	if _, err := keys.Revoke(alice, created.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := keys.Authenticate(created.Token, ""); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("revoked key = %v, want ErrInvalidAPIKey", err)
+	}
+	if _, err := keys.Rotate(alice, created.ID, RotateAPIKeyRequest{}); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Fatalf("rotate revoked key = %v, want ErrAPIKeyRevoked", err)
+	}
+
+	expiring, err := keys.Create(alice, CreateAPIKeyRequest{Name: "short", Scopes: []models.Permission{models.PermissionHistoryRead}, ExpiresIn: "1ms"})
+	if err != nil {
+		t.Fatalf("create expiring: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := keys.Authenticate(expiring.Token, ""); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expired key = %v, want ErrInvalidAPIKey", err)
+	}
+
+	listed, err := keys.List(alice)
+	if err != nil || len(listed) != 3 {
+		t.Fatalf("list = %d keys, %v", len(listed), err)
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	auditHeadID = 1
+
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+
+	// auditVerifyBatch is how many events Verify reads at a time.
+	auditVerifyBatch = 500
+)
+
+// auditGenesisHash is the PrevHash of the first event.
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// AuditRecord describes one state change. Before and After are snapshots of
+// the entity, stored as JSON; either may be nil.
+type AuditRecord struct {
+	Action     string
+	EntityType string
+	EntityID   interface{}
+	Before     interface{}
+	After      interface{}
+}
+
+type AuditFilter struct {
+	ActorUserID string
+	Action      string
+	EntityType  string
+	EntityID    string
+	RequestID   string
+	From        *time.Time
+	To          *time.Time
+	// BeforeSequence pages backwards: only events older than it are returned.
+	BeforeSequence uint64
+	Limit          int
+}
+
+type AuditPage struct {
+	Events []models.AuditEvent `json:"events"`
+	// NextBeforeSequence is set when older events remain.
+	NextBeforeSequence *uint64 `json:"next_before_sequence,omitempty"`
+}
+
+// AuditVerification is the result of walking the chain. BrokenAt is the
+// sequence of the first event that fails to link or hash.
+type AuditVerification struct {
+	Valid    bool    `json:"valid"`
+	Events   int     `json:"events"`
+	HeadHash string  `json:"head_hash"`
+	BrokenAt *uint64 `json:"broken_at,omitempty"`
+	Reason   string  `json:"reason,omitempty"`
+}
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Record appends an event inside tx, so it commits or rolls back with the
+// change it describes.
+func (s *AuditService) Record(tx *gorm.DB, actor Actor, record AuditRecord) error {
+	return recordAudit(tx, actor, record)
+}
+
+// recordAudit appends an event to the chain. The chain head is locked until
+// tx ends, so callers should record after taking their other locks.
+func recordAudit(tx *gorm.DB, actor Actor, record AuditRecord) error {
+	before, err := auditSnapshot(record.Before)
+	if err != nil {
+		return err
+	}
+	after, err := auditSnapshot(record.After)
+	if err != nil {
+		return err
+	}
+
+	head, err := lockAuditHead(tx)
+	if err != nil {
+		return err
+	}
+
+	event := models.AuditEvent{
+		Sequence:    head.Sequence + 1,
+		OccurredAt:  time.Now().UTC().Truncate(time.Microsecond),
+		ActorUserID: actor.UserID,
+		ActorRole:   actor.Role,
+		SessionID:   auditCredentialRef(actor),
+		IP:          actor.IP,
+		RequestID:   actor.RequestID,
+		Action:      record.Action,
+		EntityType:  record.EntityType,
+		Before:      before,
+		After:       after,
+		PrevHash:    head.Hash,
+	}
+	if record.EntityID != nil {
+		event.EntityID = fmt.Sprint(record.EntityID)
+	}
+	event.Hash = auditHash(&event)
+
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	err = tx.Model(&models.AuditHead{}).Where("id = ?", auditHeadID).
+		Updates(map[string]interface{}{"sequence": event.Sequence, "hash": event.Hash}).Error
+	if err != nil {
+		return fmt.Errorf("failed to advance audit chain: %w", err)
+	}
+	return nil
+}
+
+func lockAuditHead(tx *gorm.DB) (*models.AuditHead, error) {
+	var head models.AuditHead
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, auditHeadID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		genesis := models.AuditHead{ID: auditHeadID, Hash: auditGenesisHash}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&genesis).Error; err != nil {
+			return nil, fmt.Errorf("failed to create audit chain: %w", err)
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&head, auditHeadID).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+	return &head, nil
+}
+
+func auditSnapshot(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot %T for audit: %w", v, err)
+	}
+	return string(data), nil
+}
+
+// auditTransfer snapshots a transfer without its account associations,
+// which are rarely loaded and would otherwise be recorded as empty accounts.
+func auditTransfer(t *models.Transfer) interface{} {
+	type transfer models.Transfer
+	return struct {
+		*transfer
+		FromAccount *struct{} `json:"from_account,omitempty"`
+		ToAccount   *struct{} `json:"to_account,omitempty"`
+	}{transfer: (*transfer)(t)}
+}
+
+// auditOrder snapshots an order without its product association.
+func auditOrder(o *models.Order) interface{} {
+	type order models.Order
+	return struct {
+		*order
+		Product *struct{} `json:"product,omitempty"`
+	}{order: (*order)(o)}
+}
+
+// auditCredentialRef identifies the session or API key behind a change. A
+// session is recorded as a hash, as its ID is a bearer credential; an API
+// key by its public key ID.
+func auditCredentialRef(actor Actor) string {
+	if actor.APIKeyID != "" {
+		return "key:" + actor.APIKeyID
+	}
+	if actor.SessionID == "" {
+		return ""
+	}
+	return SessionHandle(actor.SessionID)
+}
+
+// auditHash hashes every recorded field of the event together with PrevHash.
+func auditHash(e *models.AuditEvent) string {
+	payload, _ := json.Marshal([]interface{}{
+		e.Sequence,
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		e.ActorUserID,
+		e.ActorRole,
+		e.SessionID,
+		e.IP,
+		e.RequestID,
+		e.Action,
+		e.EntityType,
+		e.EntityID,
+		e.Before,
+		e.After,
+		e.PrevHash,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// Query returns matching events, newest first.
+func (s *AuditService) Query(filter AuditFilter) (*AuditPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	}
+	if limit > MaxAuditLimit {
+		limit = MaxAuditLimit
+	}
+
+	query := s.db.Model(&models.AuditEvent{})
+	if filter.ActorUserID != "" {
+		query = query.Where("actor_user_id = ?", filter.ActorUserID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("occurred_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("occurred_at < ?", *filter.To)
+	}
+	if filter.BeforeSequence > 0 {
+		query = query.Where("sequence < ?", filter.BeforeSequence)
+	}
+
+	var events []models.AuditEvent
+	if err := query.Order("sequence DESC").Limit(limit + 1).Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+
+	page := &AuditPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		next := page.Events[limit-1].Sequence
+		page.NextBeforeSequence = &next
+	}
+	return page, nil
+}
+
+// Verify walks the whole chain, checking that sequences are contiguous, that
+// each event links to its predecessor and that every hash matches the
+// event's contents, and finally that the chain ends at the recorded head.
+func (s *AuditService) Verify() (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true, HeadHash: auditGenesisHash}
+	broken := func(sequence uint64, reason string) (*AuditVerification, error) {
+		result.Valid = false
+		result.BrokenAt = &sequence
+		result.Reason = reason
+		return result, nil
+	}
+
+	var lastSequence uint64
+	for {
+		var events []models.AuditEvent
+		err := s.db.Where("sequence > ?", lastSequence).Order("sequence").Limit(auditVerifyBatch).Find(&events).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		for i := range events {
+			event := &events[i]
+			switch {
+			case event.Sequence != lastSequence+1:
+				return broken(lastSequence+1, "event is missing")
+			case event.PrevHash != result.HeadHash:
+				return broken(event.Sequence, "event does not link to its predecessor")
+			case auditHash(event) != event.Hash:
+				return broken(event.Sequence, "event contents do not match its hash")
+			}
+			lastSequence = event.Sequence
+			result.HeadHash = event.Hash
+			result.Events++
+		}
+		if len(events) < auditVerifyBatch {
+			break
+		}
+	}
+
+	var head models.AuditHead
+	err := s.db.First(&head, auditHeadID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if result.Events > 0 {
+			return broken(lastSequence, "chain head is missing")
+		}
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit chain head: %w", err)
+	}
+	if head.Sequence != lastSequence || head.Hash != result.HeadHash {
+		return broken(lastSequence+1, "events after the last stored event are missing")
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+)
+
+func TestAuditChain(t *testing.T) {
+	db := newTestDB(t)
+	audit := NewAuditService(db)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	actor := Actor{UserID: "alice", Role: models.RoleCustomer, SessionID: "secret-session", IP: "10.0.0.1", RequestID: "req-1"}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := transfers.TransferMoney(actor, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); err != nil {
+				t.Errorf("transfer: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A failed transfer leaves no trace
+	if _, err := transfers.TransferMoney(actor, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "500.00"}); err == nil {
+		t.Fatal("overdraft succeeded")
+	}
+
+	page, err := audit.Query(AuditFilter{Action: models.AuditTransferCreate, Limit: 3})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(page.Events) != 3 || page.NextBeforeSequence == nil || page.Events[0].Sequence != 5 {
+		t.Fatalf("first page = %+v", page)
+	}
+	event := page.Events[0]
+	if event.ActorUserID != "alice" || event.IP != "10.0.0.1" || event.RequestID != "req-1" || event.EntityType != "transfer" {
+		t.Fatalf("event = %+v", event)
+	}
+	if event.SessionID == "" || event.SessionID == actor.SessionID {
+		t.Fatalf("session reference = %q, want a hash of the session ID", event.SessionID)
+	}
+	rest, err := audit.Query(AuditFilter{Action: models.AuditTransferCreate, BeforeSequence: *page.NextBeforeSequence})
+	if err != nil || len(rest.Events) != 2 || rest.NextBeforeSequence != nil {
+		t.Fatalf("second page = %+v, %v", rest, err)
+	}
+
+	result, err := audit.Verify()
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !result.Valid || result.Events != 5 {
+		t.Fatalf("verify = %+v, want a valid chain of 5 events", result)
+	}
+
+	if err := db.Model(&event).Update("action", "noop").Error; !errors.Is(err, models.ErrAuditImmutable) {
+		t.Fatalf("update through the model = %v, want ErrAuditImmutable", err)
+	}
+
+	// Tampering behind the application's back is detected
+	if err := db.Exec("UPDATE audit_events SET after = ? WHERE sequence = 3", `{"amount":"1.00"}`).Error; err != nil {
+		t.Fatalf("tamper: %v", err)
+	}
+	result, err = audit.Verify()
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if result.Valid || result.BrokenAt == nil || *result.BrokenAt != 3 {
+		t.Fatalf("verify after edit = %+v, want broken at 3", result)
+	}
+
+	if err := db.Exec("DELETE FROM audit_events WHERE sequence >= 3").Error; err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	result, err = audit.Verify()
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if result.Valid || result.BrokenAt == nil || *result.BrokenAt != 3 {
+		t.Fatalf("verify after truncation = %+v, want broken at 3", result)
+	}
+}
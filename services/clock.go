@@ -0,0 +1,16 @@
+package services
+
+import "time"
+
+// Clock is the source of the current time for services whose behaviour
+// depends on elapsed time, so tests can move time forward.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock reads the wall clock.
+var SystemClock Clock = systemClock{}
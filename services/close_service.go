@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPeriodClosed     = errors.New("period is closed")
+	ErrDayAlreadyClosed = errors.New("day is already closed")
+	ErrDayNotOver       = errors.New("day has not ended yet")
+	ErrCloseNotFound    = errors.New("day is not closed")
+)
+
+// periodLockID is the one row of period_locks.
+const periodLockID = 1
+
+// BusinessDateLayout is how business days appear in requests and responses.
+// Days run from midnight to midnight UTC.
+const BusinessDateLayout = "2006-01-02"
+
+// AccountDayBalance is an account's balance at the start and end of one day
+// and the money that came in and went out during it.
+type AccountDayBalance struct {
+	AccountID      uint         `json:"account_id"`
+	Currency       string       `json:"currency"`
+	Date           string       `json:"date"`
+	OpeningBalance money.Amount `json:"opening_balance"`
+	Inflows        money.Amount `json:"inflows"`
+	Outflows       money.Amount `json:"outflows"`
+	ClosingBalance money.Amount `json:"closing_balance"`
+	// Closed is set when the figures are the day's snapshot; otherwise they
+	// are the last snapshot before the day plus the postings made since.
+	Closed bool `json:"closed"`
+}
+
+type CurrencyDayTotal struct {
+	Currency       string       `json:"currency"`
+	Accounts       int          `json:"accounts"`
+	OpeningBalance money.Amount `json:"opening_balance"`
+	Inflows        money.Amount `json:"inflows"`
+	Outflows       money.Amount `json:"outflows"`
+	ClosingBalance money.Amount `json:"closing_balance"`
+}
+
+type CloseSummary struct {
+	models.PeriodClose
+	Currencies []CurrencyDayTotal `json:"currencies"`
+}
+
+type CloseService struct {
+	db    *gorm.DB
+	clock Clock
+}
+
+func NewCloseService(db *gorm.DB, clock Clock) *CloseService {
+	return &CloseService{db: db, clock: clock}
+}
+
+// ParseBusinessDate parses a YYYY-MM-DD day.
+func ParseBusinessDate(raw string) (time.Time, error) {
+	date, err := time.Parse(BusinessDateLayout, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", raw)
+	}
+	return date, nil
+}
+
+func businessDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// lockPeriods locks the period lock row with the given strength: "SHARE"
+// for a posting, which may run alongside other postings, and "UPDATE" for
+// closing days, which waits for those postings to commit and keeps new ones
+// out until the close commits.
+func lockPeriods(tx *gorm.DB, strength string) error {
+	var lock models.PeriodLock
+	err := tx.Clauses(clause.Locking{Strength: strength}).First(&lock, periodLockID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PeriodLock{ID: periodLockID}).Error; err != nil {
+			return fmt.Errorf("failed to create the period lock: %w", err)
+		}
+		err = tx.Clauses(clause.Locking{Strength: strength}).First(&lock, periodLockID).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock periods: %w", err)
+	}
+	return nil
+}
+
+// checkPeriodOpen rejects postings dated on or before the last closed day.
+// It holds the period lock for share until the posting commits, so a close
+// cannot snapshot the day without it.
+func checkPeriodOpen(tx *gorm.DB, at time.Time) error {
+	day := businessDay(at)
+	if err := lockPeriods(tx, "SHARE"); err != nil {
+		return err
+	}
+	var closed int64
+	if err := tx.Model(&models.PeriodClose{}).Where("date >= ?", day).Count(&closed).Error; err != nil {
+		return fmt.Errorf("failed to check closed periods: %w", err)
+	}
+	if closed > 0 {
+		return reject(fmt.Errorf("%w: cannot post on %s", ErrPeriodClosed, day.Format(BusinessDateLayout)))
+	}
+	return nil
+}
+
+func lastClose(tx *gorm.DB) (*models.PeriodClose, error) {
+	var closed models.PeriodClose
+	if err := tx.Order("date DESC").Limit(1).Find(&closed).Error; err != nil {
+		return nil, fmt.Errorf("failed to find the last closed day: %w", err)
+	}
+	if closed.ID == 0 {
+		return nil, nil
+	}
+	return &closed, nil
+}
+
+// Close closes every day after the last closed one up to and including date,
+// snapshotting each account's balance for each day. The very first close
+// starts from the day of the earliest posting.
+func (s *CloseService) Close(actor Actor, date time.Time) ([]models.PeriodClose, error) {
+	if !actor.Can(models.PermissionLedgerClose) {
+		return nil, fmt.Errorf("%w: cannot close days", ErrForbidden)
+	}
+	date = businessDay(date)
+	if date.AddDate(0, 0, 1).After(s.clock.Now()) {
+		return nil, fmt.Errorf("%w: %s", ErrDayNotOver, date.Format(BusinessDateLayout))
+	}
+
+	var closes []models.PeriodClose
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockPeriods(tx, "UPDATE"); err != nil {
+			return err
+		}
+		last, err := lastClose(tx)
+		if err != nil {
+			return err
+		}
+
+		start := date
+		balances := make(map[uint]money.Amount)
+		if last != nil {
+			if !date.After(last.Date.UTC()) {
+				return fmt.Errorf("%w: %s", ErrDayAlreadyClosed, date.Format(BusinessDateLayout))
+			}
+			start = last.Date.UTC().AddDate(0, 0, 1)
+
+			var snapshots []models.DailyBalance
+			if err := tx.Where("date = ?", last.Date.UTC()).Find(&snapshots).Error; err != nil {
+				return fmt.Errorf("failed to read snapshots: %w", err)
+			}
+			for _, snapshot := range snapshots {
+				balances[snapshot.AccountID] = snapshot.ClosingBalance
+			}
+		} else {
+			var first models.Posting
+			if err := tx.Order("created_at").Limit(1).Find(&first).Error; err != nil {
+				return fmt.Errorf("failed to find the first posting: %w", err)
+			}
+			if first.ID != 0 && businessDay(first.CreatedAt).Before(date) {
+				start = businessDay(first.CreatedAt)
+			}
+		}
+
+		for day := start; !day.After(date); day = day.AddDate(0, 0, 1) {
+			closed, err := closeDay(tx, actor, day, balances)
+			if err != nil {
+				return err
+			}
+			closes = append(closes, *closed)
+		}
+
+		return recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditPeriodClose,
+			EntityType: "period_close",
+			EntityID:   closes[len(closes)-1].ID,
+			After: map[string]interface{}{
+				"from": start.Format(BusinessDateLayout),
+				"to":   date.Format(BusinessDateLayout),
+				"days": len(closes),
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closes, nil
+}
+
+// closeDay snapshots every account that existed by the end of day, carrying
+// balances forward from the previous day's closing balances.
+func closeDay(tx *gorm.DB, actor Actor, day time.Time, balances map[uint]money.Amount) (*models.PeriodClose, error) {
+	end := day.AddDate(0, 0, 1)
+
+	inflows := make(map[uint]money.Amount)
+	outflows := make(map[uint]money.Amount)
+	rows, err := tx.Model(&models.Posting{}).Select("account_id, direction, amount").
+		Where("created_at >= ? AND created_at < ?", day, end).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var posting models.Posting
+		if err := rows.Scan(&posting.AccountID, &posting.Direction, &posting.Amount); err != nil {
+			return nil, fmt.Errorf("failed to read posting: %w", err)
+		}
+		if posting.Direction == models.PostingCredit {
+			inflows[posting.AccountID] = inflows[posting.AccountID].Add(posting.Amount)
+		} else {
+			outflows[posting.AccountID] = outflows[posting.AccountID].Add(posting.Amount)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+	rows.Close()
+
+	// Accounts with money are included whatever their creation time says,
+	// so that backfilled balances are never left out of a snapshot
+	ids := make([]uint, 0, len(balances)+len(inflows)+len(outflows))
+	for _, flows := range []map[uint]money.Amount{balances, inflows, outflows} {
+		for id := range flows {
+			ids = append(ids, id)
+		}
+	}
+	query := tx.Unscoped().Where("created_at < ?", end)
+	if len(ids) > 0 {
+		query = query.Or("id IN ?", ids)
+	}
+	var accounts []models.Account
+	if err := query.Order("id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+	snapshots := make([]models.DailyBalance, 0, len(accounts))
+	for _, account := range accounts {
+		snapshot := models.DailyBalance{
+			AccountID:      account.ID,
+			Date:           day,
+			Currency:       account.Currency,
+			OpeningBalance: balances[account.ID].Normalize(account.Currency),
+			Inflows:        inflows[account.ID].Normalize(account.Currency),
+			Outflows:       outflows[account.ID].Normalize(account.Currency),
+		}
+		snapshot.ClosingBalance = snapshot.OpeningBalance.Add(snapshot.Inflows).Sub(snapshot.Outflows).Normalize(account.Currency)
+		balances[account.ID] = snapshot.ClosingBalance
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(snapshots) > 0 {
+		if err := tx.CreateInBatches(&snapshots, 500).Error; err != nil {
+			return nil, fmt.Errorf("failed to store snapshots: %w", err)
+		}
+	}
+
+	closed := &models.PeriodClose{
+		Date:     day,
+		ClosedBy: actor.UserID,
+		Accounts: len(snapshots),
+	}
+	if err := tx.Create(closed).Error; err != nil {
+		return nil, fmt.Errorf("failed to close %s: %w", day.Format(BusinessDateLayout), err)
+	}
+	return closed, nil
+}
+
+// ListCloses returns the most recently closed days first.
+func (s *CloseService) ListCloses(limit int) ([]models.PeriodClose, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 30
+	}
+	var closes []models.PeriodClose
+	if err := s.db.Order("date DESC").Limit(limit).Find(&closes).Error; err != nil {
+		return nil, fmt.Errorf("failed to list closed days: %w", err)
+	}
+	return closes, nil
+}
+
+// GetClose returns a closed day with its snapshots totalled per currency.
+func (s *CloseService) GetClose(date time.Time) (*CloseSummary, error) {
+	date = businessDay(date)
+	var closed models.PeriodClose
+	if err := s.db.Where("date = ?", date).Limit(1).Find(&closed).Error; err != nil {
+		return nil, fmt.Errorf("failed to find closed day: %w", err)
+	}
+	if closed.ID == 0 {
+		return nil, ErrCloseNotFound
+	}
+
+	var snapshots []models.DailyBalance
+	if err := s.db.Where("date = ?", date).Order("currency, account_id").Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	summary := &CloseSummary{PeriodClose: closed, Currencies: []CurrencyDayTotal{}}
+	for _, snapshot := range snapshots {
+		if n := len(summary.Currencies); n == 0 || summary.Currencies[n-1].Currency != snapshot.Currency {
+			summary.Currencies = append(summary.Currencies, CurrencyDayTotal{Currency: snapshot.Currency})
+		}
+		total := &summary.Currencies[len(summary.Currencies)-1]
+		total.Accounts++
+		total.OpeningBalance = total.OpeningBalance.Add(snapshot.OpeningBalance)
+		total.Inflows = total.Inflows.Add(snapshot.Inflows)
+		total.Outflows = total.Outflows.Add(snapshot.Outflows)
+		total.ClosingBalance = total.ClosingBalance.Add(snapshot.ClosingBalance)
+	}
+	for i := range summary.Currencies {
+		total := &summary.Currencies[i]
+		total.OpeningBalance = total.OpeningBalance.Normalize(total.Currency)
+		total.Inflows = total.Inflows.Normalize(total.Currency)
+		total.Outflows = total.Outflows.Normalize(total.Currency)
+		total.ClosingBalance = total.ClosingBalance.Normalize(total.Currency)
+	}
+	return summary, nil
+}
+
+// DayBalance answers an account's balances for a day from the latest
+// snapshot at or before it plus the postings made after that snapshot, so
+// the account's history is not replayed from the start.
+func (s *CloseService) DayBalance(actor Actor, accountID uint, date time.Time) (*AccountDayBalance, error) {
+	var account models.Account
+	if err := s.db.First(&account, accountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	if err := actor.Authorize(s.db, account.UserID, "view balances"); err != nil {
+		return nil, err
+	}
+	date = businessDay(date)
+	if date.After(businessDay(s.clock.Now())) {
+		return nil, fmt.Errorf("date %s is in the future", date.Format(BusinessDateLayout))
+	}
+
+	result := &AccountDayBalance{
+		AccountID: account.ID,
+		Currency:  account.Currency,
+		Date:      date.Format(BusinessDateLayout),
+	}
+
+	var snapshot models.DailyBalance
+	err := s.db.Where("account_id = ? AND date <= ?", account.ID, date).
+		Order("date DESC").Limit(1).Find(&snapshot).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	if snapshot.ID != 0 && snapshot.Date.UTC().Equal(date) {
+		result.OpeningBalance = snapshot.OpeningBalance
+		result.Inflows = snapshot.Inflows
+		result.Outflows = snapshot.Outflows
+		result.ClosingBalance = snapshot.ClosingBalance
+		result.Closed = true
+		return result, nil
+	}
+
+	query := s.db.Model(&models.Posting{}).Select("created_at, direction, amount").
+		Where("account_id = ? AND created_at < ?", account.ID, date.AddDate(0, 0, 1))
+	if snapshot.ID != 0 {
+		result.OpeningBalance = snapshot.ClosingBalance
+		query = query.Where("created_at >= ?", snapshot.Date.UTC().AddDate(0, 0, 1))
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var posting models.Posting
+		if err := rows.Scan(&posting.CreatedAt, &posting.Direction, &posting.Amount); err != nil {
+			return nil, fmt.Errorf("failed to read posting: %w", err)
+		}
+		switch {
+		case posting.CreatedAt.Before(date):
+			result.OpeningBalance = result.OpeningBalance.Add(posting.Signed())
+		case posting.Direction == models.PostingCredit:
+			result.Inflows = result.Inflows.Add(posting.Amount)
+		default:
+			result.Outflows = result.Outflows.Add(posting.Amount)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+
+	result.OpeningBalance = result.OpeningBalance.Normalize(account.Currency)
+	result.Inflows = result.Inflows.Normalize(account.Currency)
+	result.Outflows = result.Outflows.Normalize(account.Currency)
+	result.ClosingBalance = result.OpeningBalance.Add(result.Inflows).Sub(result.Outflows).Normalize(account.Currency)
+	return result, nil
+}
+
+// RunSchedule closes every finished day, checking every interval until ctx
+// is cancelled.
+func (s *CloseService) RunSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			yesterday := businessDay(s.clock.Now()).AddDate(0, 0, -1)
+			closes, err := s.Close(SystemActor, yesterday)
+			if errors.Is(err, ErrDayAlreadyClosed) {
+				continue
+			}
+			if err != nil {
+				log.Printf("Day close failed: %v", err)
+				continue
+			}
+			log.Printf("Closed %d days through %s", len(closes), yesterday.Format(BusinessDateLayout))
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"gorm.io/gorm"
+)
+
+func TestCloseAndDayBalances(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	clock := &fakeClock{now: time.Now()}
+	closer := NewCloseService(db, clock)
+
+	today := businessDay(clock.Now())
+	day1, day2, day3 := today.AddDate(0, 0, -3), today.AddDate(0, 0, -2), today.AddDate(0, 0, -1)
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "0")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+	issuance, err := journal.systemAccount(db, models.IssuanceUserID, "UZS")
+	if err != nil {
+		t.Fatalf("issuance account: %v", err)
+	}
+	db.Model(&models.Account{}).Where("1 = 1").Update("created_at", day1)
+
+	postOn := func(day time.Time, fromID, toID uint, amount string) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			entry := &models.JournalEntry{Type: models.EntryTypeTransfer, CreatedAt: day.Add(10 * time.Hour)}
+			return journal.Post(tx, entry, []PostingLine{
+				{AccountID: fromID, Direction: models.PostingDebit, Amount: money.MustParse(amount)},
+				{AccountID: toID, Direction: models.PostingCredit, Amount: money.MustParse(amount)},
+			})
+		})
+	}
+	for _, posting := range []struct {
+		day      time.Time
+		from, to uint
+		amount   string
+	}{
+		{day1, issuance.ID, alice.ID, "100.00"},
+		{day2, alice.ID, bob.ID, "30.00"},
+		{day3, bob.ID, alice.ID, "5.00"},
+	} {
+		if err := postOn(posting.day, posting.from, posting.to, posting.amount); err != nil {
+			t.Fatalf("post on %s: %v", posting.day.Format(BusinessDateLayout), err)
+		}
+	}
+
+	assertDay := func(balance *AccountDayBalance, opening, inflows, outflows, closing string, closed bool) {
+		t.Helper()
+		if balance.OpeningBalance.String() != opening || balance.Inflows.String() != inflows ||
+			balance.Outflows.String() != outflows || balance.ClosingBalance.String() != closing || balance.Closed != closed {
+			t.Fatalf("balance on %s = %+v, want %s +%s -%s = %s (closed %v)", balance.Date, balance, opening, inflows, outflows, closing, closed)
+		}
+	}
+
+	// Before any close the answer comes from postings alone
+	balance, err := closer.DayBalance(Actor{UserID: "alice"}, alice.ID, day2)
+	if err != nil {
+		t.Fatalf("DayBalance: %v", err)
+	}
+	assertDay(balance, "100.00", "0.00", "30.00", "70.00", false)
+
+	if _, err := closer.Close(Actor{UserID: "alice", Role: models.RoleCustomer}, day2); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("customer close = %v, want ErrForbidden", err)
+	}
+	if _, err := closer.Close(testOperator, today); !errors.Is(err, ErrDayNotOver) {
+		t.Fatalf("close today = %v, want ErrDayNotOver", err)
+	}
+
+	// The first close catches up from the day of the earliest posting
+	closes, err := closer.Close(testOperator, day2)
+	if err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(closes) != 2 || !closes[0].Date.Equal(day1) || closes[1].Accounts != 3 {
+		t.Fatalf("closes = %+v", closes)
+	}
+	if _, err := closer.Close(testOperator, day1); !errors.Is(err, ErrDayAlreadyClosed) {
+		t.Fatalf("close again = %v, want ErrDayAlreadyClosed", err)
+	}
+
+	balance, err = closer.DayBalance(Actor{UserID: "alice"}, alice.ID, day2)
+	if err != nil {
+		t.Fatalf("DayBalance: %v", err)
+	}
+	assertDay(balance, "100.00", "0.00", "30.00", "70.00", true)
+	balance, err = closer.DayBalance(Actor{UserID: "alice"}, alice.ID, day3)
+	if err != nil {
+		t.Fatalf("DayBalance: %v", err)
+	}
+	assertDay(balance, "70.00", "5.00", "0.00", "75.00", false)
+	if _, err := closer.DayBalance(Actor{UserID: "bob", Role: models.RoleCustomer}, alice.ID, day2); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("other user's balance = %v, want ErrForbidden", err)
+	}
+	if _, err := closer.DayBalance(Actor{UserID: "alice"}, alice.ID, today.AddDate(0, 0, 1)); err == nil {
+		t.Fatal("expected an error for a future date")
+	}
+
+	// Closed days no longer accept postings; open ones still do
+	if err := postOn(day2, alice.ID, bob.ID, "1.00"); !errors.Is(err, ErrPeriodClosed) {
+		t.Fatalf("post on a closed day = %v, want ErrPeriodClosed", err)
+	}
+	if err := postOn(day3, alice.ID, bob.ID, "1.00"); err != nil {
+		t.Fatalf("post on an open day: %v", err)
+	}
+
+	if _, err := closer.Close(testOperator, day3); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	summary, err := closer.GetClose(day3)
+	if err != nil {
+		t.Fatalf("GetClose: %v", err)
+	}
+	if len(summary.Currencies) != 1 {
+		t.Fatalf("currencies = %+v", summary.Currencies)
+	}
+	total := summary.Currencies[0]
+	if total.Accounts != 3 || total.Inflows.String() != "6.00" || total.Outflows.String() != "6.00" || total.ClosingBalance.String() != "0.00" {
+		t.Fatalf("UZS total = %+v", total)
+	}
+	if _, err := closer.GetClose(today); !errors.Is(err, ErrCloseNotFound) {
+		t.Fatalf("GetClose open day = %v, want ErrCloseNotFound", err)
+	}
+
+	var snapshot models.DailyBalance
+	db.Where("account_id = ? AND date = ?", alice.ID, day3).First(&snapshot)
+	if snapshot.ClosingBalance.String() != "74.00" {
+		t.Fatalf("alice day 3 snapshot = %+v", snapshot)
+	}
+	var events int64
+	db.Model(&models.AuditEvent{}).Where("action = ?", models.AuditPeriodClose).Count(&events)
+	if events != 2 {
+		t.Fatalf("audit events = %d, want 2", events)
+	}
+}
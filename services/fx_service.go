@@ -0,0 +1,319 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const rateDateLayout = "2006-01-02"
+
+type FXService struct {
+	db        *gorm.DB
+	spreadBps int
+	quoteTTL  time.Duration
+}
+
+func NewFXService(db *gorm.DB, spreadBps int, quoteTTL time.Duration) *FXService {
+	return &FXService{
+		db:        db,
+		spreadBps: spreadBps,
+		quoteTTL:  quoteTTL,
+	}
+}
+
+type SetRateRequest struct {
+	BaseCurrency  string `json:"base_currency" binding:"required,len=3"`
+	QuoteCurrency string `json:"quote_currency" binding:"required,len=3"`
+	Rate          string `json:"rate" binding:"required"`
+	EffectiveDate string `json:"effective_date"` // YYYY-MM-DD, defaults to today
+	Source        string `json:"source"`
+}
+
+type QuoteRequest struct {
+	FromCurrency string `json:"from_currency" binding:"required,len=3"`
+	ToCurrency   string `json:"to_currency" binding:"required,len=3"`
+	Amount       string `json:"amount" binding:"required"`
+}
+
+var (
+	ErrRateNotFound  = errors.New("no exchange rate available for currency pair")
+	ErrQuoteNotFound = errors.New("fx quote not found")
+	ErrQuoteExpired  = errors.New("fx quote has expired")
+	ErrQuoteUsed     = errors.New("fx quote has already been used")
+)
+
+func (s *FXService) SetRate(actor Actor, req SetRateRequest) (*models.ExchangeRate, error) {
+	rate, err := newExchangeRate(req.BaseCurrency, req.QuoteCurrency, req.Rate, req.EffectiveDate, req.Source)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rate).Error; err != nil {
+			return fmt.Errorf("failed to store exchange rate: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditFXRateSet, EntityType: "fx_rate", EntityID: rate.ID, After: rate})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// ImportRatesCSV loads rows of base_currency,quote_currency,rate,effective_date
+// with an optional header line. The whole file is rejected if any row is
+// invalid.
+func (s *FXService) ImportRatesCSV(actor Actor, r io.Reader, source string) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	var rates []models.ExchangeRate
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && strings.EqualFold(strings.TrimSpace(record[0]), "base_currency") {
+			continue
+		}
+		if len(record) < 3 {
+			return 0, fmt.Errorf("line %d: expected base_currency,quote_currency,rate[,effective_date]", i+1)
+		}
+		date := ""
+		if len(record) > 3 {
+			date = record[3]
+		}
+		rate, err := newExchangeRate(record[0], record[1], record[2], date, source)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		rates = append(rates, *rate)
+	}
+
+	if len(rates) == 0 {
+		return 0, nil
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rates).Error; err != nil {
+			return fmt.Errorf("failed to store exchange rates: %w", err)
+		}
+		for i := range rates {
+			if err := recordAudit(tx, actor, AuditRecord{Action: models.AuditFXRateSet, EntityType: "fx_rate", EntityID: rates[i].ID, After: rates[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+func newExchangeRate(base, quote, rawRate, rawDate, source string) (*models.ExchangeRate, error) {
+	base = strings.ToUpper(strings.TrimSpace(base))
+	quote = strings.ToUpper(strings.TrimSpace(quote))
+	if len(base) != 3 || len(quote) != 3 {
+		return nil, errors.New("currencies must be 3-letter ISO codes")
+	}
+	if base == quote {
+		return nil, errors.New("base and quote currency must differ")
+	}
+
+	rate, err := money.ParseRate(rawRate)
+	if err != nil {
+		return nil, err
+	}
+
+	effective := time.Now().UTC().Truncate(24 * time.Hour)
+	if rawDate = strings.TrimSpace(rawDate); rawDate != "" {
+		effective, err = time.Parse(rateDateLayout, rawDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid effective_date %q, expected YYYY-MM-DD", rawDate)
+		}
+	}
+
+	return &models.ExchangeRate{
+		BaseCurrency:  base,
+		QuoteCurrency: quote,
+		Rate:          rate,
+		EffectiveDate: effective,
+		Source:        source,
+	}, nil
+}
+
+func (s *FXService) ListRates(base, quote string) ([]models.ExchangeRate, error) {
+	query := s.db.Order("effective_date DESC, id DESC")
+	if base != "" {
+		query = query.Where("base_currency = ?", strings.ToUpper(base))
+	}
+	if quote != "" {
+		query = query.Where("quote_currency = ?", strings.ToUpper(quote))
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+// MidRate returns the latest rate for converting from into to that is
+// effective at the given time, falling back to the inverse of the opposite
+// pair.
+func (s *FXService) MidRate(from, to string, at time.Time) (money.Rate, error) {
+	var rate models.ExchangeRate
+	err := s.db.Where("base_currency = ? AND quote_currency = ? AND effective_date <= ?", from, to, at).
+		Order("effective_date DESC, id DESC").First(&rate).Error
+	if err == nil {
+		return rate.Rate, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return money.Rate{}, fmt.Errorf("failed to look up exchange rate: %w", err)
+	}
+
+	err = s.db.Where("base_currency = ? AND quote_currency = ? AND effective_date <= ?", to, from, at).
+		Order("effective_date DESC, id DESC").First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return money.Rate{}, fmt.Errorf("%w: %s/%s", ErrRateNotFound, from, to)
+	}
+	if err != nil {
+		return money.Rate{}, fmt.Errorf("failed to look up exchange rate: %w", err)
+	}
+	return rate.Rate.Inverse(), nil
+}
+
+// CreateQuote prices a conversion with the configured spread and holds it
+// for the quote TTL. The quote belongs to the acting user.
+func (s *FXService) CreateQuote(actor Actor, req QuoteRequest) (*models.FXQuote, error) {
+	from := strings.ToUpper(req.FromCurrency)
+	to := strings.ToUpper(req.ToCurrency)
+	if from == to {
+		return nil, errors.New("quote currencies must differ")
+	}
+
+	amount, err := money.ParsePositive(req.Amount, from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quote amount: %w", err)
+	}
+
+	now := time.Now()
+	mid, err := s.MidRate(from, to, now)
+	if err != nil {
+		return nil, err
+	}
+	rate := mid.WithSpread(s.spreadBps)
+
+	// Round the credited amount down so the spread is never given away
+	target, err := amount.Convert(rate, to, money.RoundDown)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert amount: %w", err)
+	}
+	if !target.IsPositive() {
+		return nil, errors.New("amount is too small to convert")
+	}
+
+	id, err := newQuoteID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate quote id: %w", err)
+	}
+
+	quote := &models.FXQuote{
+		ID:           id,
+		UserID:       actor.UserID,
+		FromCurrency: from,
+		ToCurrency:   to,
+		SourceAmount: amount,
+		TargetAmount: target,
+		MidRate:      mid,
+		Rate:         rate,
+		SpreadBps:    s.spreadBps,
+		ExpiresAt:    now.Add(s.quoteTTL),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(quote).Error; err != nil {
+			return fmt.Errorf("failed to store quote: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditFXQuoteCreate, EntityType: "fx_quote", EntityID: quote.ID, After: quote})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+func (s *FXService) GetQuote(id string) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	if err := s.db.First(&quote, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrQuoteNotFound
+		}
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// consumeQuote locks the quote and checks that it matches the transfer
+// about to be made. The quote is marked used by markQuoteUsed once the
+// transfer row exists.
+func (s *FXService) consumeQuote(tx *gorm.DB, quoteID string, fromAccount, toAccount *models.Account, amount money.Amount) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&quote, "id = ?", quoteID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, reject(ErrQuoteNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load quote: %w", err)
+	}
+
+	switch {
+	case quote.UsedAt != nil:
+		return nil, reject(ErrQuoteUsed)
+	case quote.IsExpired(time.Now()):
+		return nil, reject(ErrQuoteExpired)
+	case quote.UserID != fromAccount.UserID:
+		return nil, reject(ErrQuoteNotFound)
+	case quote.FromCurrency != fromAccount.Currency || quote.ToCurrency != toAccount.Currency:
+		return nil, reject(errors.New("fx quote currencies do not match the accounts"))
+	case quote.SourceAmount.Cmp(amount) != 0:
+		return nil, reject(errors.New("fx quote amount does not match the transfer amount"))
+	}
+	return &quote, nil
+}
+
+func (s *FXService) markQuoteUsed(tx *gorm.DB, quote *models.FXQuote, transferID uint) error {
+	now := time.Now()
+	result := tx.Model(&models.FXQuote{}).
+		Where("id = ? AND used_at IS NULL", quote.ID).
+		Updates(map[string]interface{}{"used_at": now, "transfer_id": transferID})
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark quote used: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return reject(ErrQuoteUsed)
+	}
+	quote.UsedAt = &now
+	quote.TransferID = &transferID
+	return nil
+}
+
+func newQuoteID() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
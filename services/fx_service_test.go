@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+)
+
+func TestCrossCurrencyTransferWithQuote(t *testing.T) {
+	db := newTestDB(t)
+	fx := NewFXService(db, 50, time.Minute)
+	transfers := NewTransferService(db, NewJournalService(db), fx, StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "USD", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	csv := "base_currency,quote_currency,rate,effective_date\nUSD,UZS,12650.50,2020-01-01\n"
+	if n, err := fx.ImportRatesCSV(testOperator, strings.NewReader(csv), "test"); err != nil || n != 1 {
+		t.Fatalf("ImportRatesCSV = %d, %v", n, err)
+	}
+
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); err == nil {
+		t.Fatal("cross-currency transfer without a quote succeeded")
+	}
+
+	quote, err := fx.CreateQuote(Actor{UserID: "alice"}, QuoteRequest{FromCurrency: "USD", ToCurrency: "UZS", Amount: "10.00"})
+	if err != nil {
+		t.Fatalf("CreateQuote: %v", err)
+	}
+	if quote.TargetAmount.String() != "125872.47" {
+		t.Fatalf("quote target = %s, want 125872.47", quote.TargetAmount)
+	}
+
+	resp, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00", QuoteID: quote.ID})
+	if err != nil {
+		t.Fatalf("TransferMoney: %v", err)
+	}
+
+	var transfer models.Transfer
+	db.First(&transfer, resp.TransferID)
+	if transfer.DestinationAmount == nil || transfer.DestinationAmount.String() != "125872.47" || transfer.FXRate.String() != "12587.2475" {
+		t.Fatalf("transfer FX fields = %+v", transfer)
+	}
+	if got := balanceOf(t, db, alice.ID); got.String() != "90.00" {
+		t.Fatalf("alice balance = %s, want 90.00", got)
+	}
+	if got := balanceOf(t, db, bob.ID); got.String() != "125872.47" {
+		t.Fatalf("bob balance = %s, want 125872.47", got)
+	}
+	assertLedgerBalanced(t, db)
+
+	_, err = transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00", QuoteID: quote.ID})
+	if !errors.Is(err, ErrQuoteUsed) {
+		t.Fatalf("reusing quote error = %v, want ErrQuoteUsed", err)
+	}
+}
+
+func TestExpiredQuoteIsRejected(t *testing.T) {
+	db := newTestDB(t)
+	fx := NewFXService(db, 0, -time.Second)
+	transfers := NewTransferService(db, NewJournalService(db), fx, StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100000.00")
+	bob := createFundedAccount(t, db, "bob", "USD", "0")
+
+	if _, err := fx.SetRate(testOperator, SetRateRequest{BaseCurrency: "USD", QuoteCurrency: "UZS", Rate: "12500", EffectiveDate: "2020-01-01"}); err != nil {
+		t.Fatal(err)
+	}
+	quote, err := fx.CreateQuote(Actor{UserID: "alice"}, QuoteRequest{FromCurrency: "UZS", ToCurrency: "USD", Amount: "12500.00"})
+	if err != nil {
+		t.Fatalf("CreateQuote via inverse rate: %v", err)
+	}
+	if quote.TargetAmount.String() != "1.00" {
+		t.Fatalf("quote target = %s, want 1.00", quote.TargetAmount)
+	}
+
+	_, err = transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "12500.00", QuoteID: quote.ID})
+	if !errors.Is(err, ErrQuoteExpired) {
+		t.Fatalf("TransferMoney error = %v, want ErrQuoteExpired", err)
+	}
+}
@@ -1,109 +1,420 @@
 package services
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
-	"gorm.io/gorm"
 	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/statement"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 200
+)
+
+const (
+	HistoryDirectionIn  = "in"
+	HistoryDirectionOut = "out"
+)
+
+// Operation types reported in HistoryItem.Operation. Entries without a
+// transfer report their journal entry type.
+const (
+	OperationTransfer       = "transfer"
+	OperationOrder          = "order"
+	OperationRefund         = "refund"
+	OperationReversal       = "reversal"
+	OperationOpeningBalance = string(models.EntryTypeOpeningBalance)
+	OperationAdjustment     = string(models.EntryTypeAdjustment)
+	OperationFee            = string(models.EntryTypeFee)
 )
 
+var ErrInvalidCursor = errors.New("invalid history cursor")
+
 type HistoryService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	journal *JournalService
 }
 
-func NewHistoryService(db *gorm.DB) *HistoryService {
-	return &HistoryService{db: db}
+func NewHistoryService(db *gorm.DB, journal *JournalService) *HistoryService {
+	return &HistoryService{db: db, journal: journal}
 }
 
 type HistoryItem struct {
-	Date      time.Time `json:"date"`
-	Type      string    `json:"type"`      // "Расход" или "Доход"
-	Amount    string    `json:"amount"`
-	Counterparty string  `json:"counterparty"` // user_id контрагента
-	Reference string    `json:"reference"`     // ID операции (transfer_id или order_id)
+	Date         time.Time    `json:"date"`
+	Type         string       `json:"type"` // "Расход" или "Доход"
+	Direction    string       `json:"direction"`
+	Operation    string       `json:"operation"`
+	Amount       money.Amount `json:"amount"`
+	Currency     string       `json:"currency"`
+	Counterparty string       `json:"counterparty"` // user_id контрагента
+	Reference    string       `json:"reference"`    // ID операции (transfer_id, order_id или entry_id)
+	Description  string       `json:"description,omitempty"`
+	EntryID      uint         `json:"entry_id"`
+	TransferID   uint         `json:"transfer_id,omitempty"`
+	// BalanceAfter is the ledger balance right after this operation.
+	BalanceAfter *money.Amount `json:"balance_after,omitempty"`
 }
 
 type HistoryResponse struct {
-	UserID    string        `json:"user_id"`
-	History   []HistoryItem `json:"history"`
-	Balance   string        `json:"balance"`
-	Currency  string        `json:"currency"`
+	AccountID  uint          `json:"account_id"`
+	UserID     string        `json:"user_id"`
+	History    []HistoryItem `json:"history"`
+	Balance    money.Amount  `json:"balance"`
+	Currency   string        `json:"currency"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+
+	// OpeningBalance is the balance at the start of the requested period
+	// (zero without from) and ClosingBalance the balance at its end (the
+	// current balance without to).
+	OpeningBalance money.Amount `json:"opening_balance"`
+	ClosingBalance money.Amount `json:"closing_balance"`
 }
 
-func (s *HistoryService) GetAccountHistory(userID string) (*HistoryResponse, error) {
-	// Get user account
+// HistoryFilter narrows and pages an account's history. Zero values mean
+// "no filter"; Cursor is the NextCursor of the previous page.
+type HistoryFilter struct {
+	From         *time.Time
+	To           *time.Time
+	Direction    string
+	MinAmount    *money.Amount
+	MaxAmount    *money.Amount
+	Counterparty string
+	Operation    string
+	Cursor       string
+	Limit        int
+}
+
+// historyRow is one line of the history query.
+type historyRow struct {
+	PostingID    uint
+	EntryID      uint
+	TransferID   *uint
+	Description  string
+	CreatedAt    time.Time
+	Direction    string
+	Operation    string
+	Amount       money.Amount
+	Currency     string
+	Counterparty string
+	OrderID      *uint
+	BalanceAfter *money.Amount
+}
+
+// operationSQL classifies a posting: order payments and refunds are found
+// through the orders that reference the transfer or the transfer it reverses,
+// and entries without a transfer keep their entry type.
+const operationSQL = `CASE
+		WHEN o.id IS NOT NULL THEN 'order'
+		WHEN ro.id IS NOT NULL THEN 'refund'
+		WHEN t.reversal_of_id IS NOT NULL THEN 'reversal'
+		WHEN t.id IS NOT NULL THEN 'transfer'
+		ELSE e.type END`
+
+// counterpartyAccountSQL is the account on the other side: the other end of
+// the transfer, or for entries without one (funding, adjustments) the other
+// account posted to.
+const counterpartyAccountSQL = `CASE
+		WHEN t.id IS NULL THEN (SELECT op.account_id FROM postings op
+			WHERE op.entry_id = e.id AND op.account_id <> p.account_id ORDER BY op.id LIMIT 1)
+		WHEN t.from_account_id = p.account_id THEN t.to_account_id
+		ELSE t.from_account_id END`
+
+// counterpartySQL is the counterparty the history shows, and filters on:
+// order payments go to the marketplace, everything else names the user
+// owning the other account.
+const counterpartySQL = `CASE WHEN o.id IS NOT NULL THEN 'marketplace' ELSE c.user_id END`
+
+// historySQL lists every posting of an account with what the history shows
+// for it. Every change to the balance is a posting, so the lines always add
+// up from the opening to the closing balance.
+var historySQL = fmt.Sprintf(`SELECT p.id AS posting_id, p.entry_id AS entry_id, e.transfer_id AS transfer_id, e.description AS description,
+	p.created_at AS created_at, CASE WHEN p.direction = '%s' THEN '%s' ELSE '%s' END AS direction,
+	%s AS operation, p.amount AS amount, p.currency AS currency, %s AS counterparty,
+	o.id AS order_id, p.balance_after AS balance_after
+FROM postings p
+JOIN journal_entries e ON e.id = p.entry_id
+LEFT JOIN transfers t ON t.id = e.transfer_id
+LEFT JOIN accounts c ON c.id = %s
+LEFT JOIN orders o ON o.transfer_id = t.id AND o.deleted_at IS NULL
+LEFT JOIN orders ro ON ro.transfer_id = t.reversal_of_id AND ro.deleted_at IS NULL
+WHERE p.account_id = ?`,
+	models.PostingCredit, HistoryDirectionIn, HistoryDirectionOut, operationSQL, counterpartySQL, counterpartyAccountSQL)
+
+// GetAccountHistory returns one page of the account's journal postings,
+// newest first, ordered by (created_at, posting id) so pagination happens in
+// the database.
+func (s *HistoryService) GetAccountHistory(actor Actor, accountID uint, filter HistoryFilter) (*HistoryResponse, error) {
+	account, err := s.authorizedAccount(actor, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return s.accountHistory(account, filter)
+}
+
+// authorizedAccount loads an account whose history the actor may view.
+func (s *HistoryService) authorizedAccount(actor Actor, accountID uint) (*models.Account, error) {
 	var account models.Account
-	if err := s.db.Where("user_id = ?", userID).First(&account).Error; err != nil {
+	if err := s.db.First(&account, accountID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("account not found")
+			return nil, ErrAccountNotFound
 		}
 		return nil, fmt.Errorf("failed to get account: %w", err)
 	}
+	if err := actor.Authorize(s.db, account.UserID, "view history"); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
 
-	var history []HistoryItem
+// accountHistory is GetAccountHistory for an account already authorized.
+func (s *HistoryService) accountHistory(account *models.Account, filter HistoryFilter) (*HistoryResponse, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultHistoryLimit
+	}
+	if filter.Limit > MaxHistoryLimit {
+		filter.Limit = MaxHistoryLimit
+	}
+	switch filter.Direction {
+	case "", HistoryDirectionIn, HistoryDirectionOut:
+	default:
+		return nil, fmt.Errorf("invalid direction %q", filter.Direction)
+	}
+	switch filter.Operation {
+	case "", OperationTransfer, OperationOrder, OperationRefund, OperationReversal,
+		OperationOpeningBalance, OperationAdjustment, OperationFee:
+	default:
+		return nil, fmt.Errorf("invalid operation type %q", filter.Operation)
+	}
 
-	// Get transfers where user is sender (Расход)
-	var sentTransfers []models.Transfer
-	if err := s.db.Preload("ToAccount").Where("from_account_id = ?", account.ID).Find(&sentTransfers).Error; err != nil {
-		return nil, fmt.Errorf("failed to get sent transfers: %w", err)
+	query, args, err := historyQuery(account.ID, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, transfer := range sentTransfers {
-		history = append(history, HistoryItem{
-			Date:         transfer.CreatedAt,
-			Type:         "Расход",
-			Amount:       transfer.Amount,
-			Counterparty: transfer.ToAccount.UserID,
-			Reference:    fmt.Sprintf("transfer_%d", transfer.ID),
-		})
+	var rows []historyRow
+	if err = s.db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
 	}
 
-	// Get transfers where user is recipient (Доход)
-	var receivedTransfers []models.Transfer
-	if err := s.db.Preload("FromAccount").Where("to_account_id = ?", account.ID).Find(&receivedTransfers).Error; err != nil {
-		return nil, fmt.Errorf("failed to get received transfers: %w", err)
+	response := &HistoryResponse{
+		AccountID: account.ID,
+		UserID:    account.UserID,
+		History:   make([]HistoryItem, 0, len(rows)),
+		Balance:   account.Balance,
+		Currency:  account.Currency,
+	}
+	response.OpeningBalance = money.FromMinor(0, account.Currency)
+	if filter.From != nil {
+		if response.OpeningBalance, err = s.journal.BalanceAt(account, *filter.From); err != nil {
+			return nil, err
+		}
+	}
+	response.ClosingBalance = account.Balance
+	if filter.To != nil {
+		if response.ClosingBalance, err = s.journal.BalanceAt(account, *filter.To); err != nil {
+			return nil, err
+		}
 	}
 
-	for _, transfer := range receivedTransfers {
-		history = append(history, HistoryItem{
-			Date:         transfer.CreatedAt,
+	if len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+		last := rows[len(rows)-1]
+		response.NextCursor = encodeHistoryCursor(last.CreatedAt, last.PostingID)
+	}
+
+	for _, row := range rows {
+		item := HistoryItem{
+			Date:         row.CreatedAt,
 			Type:         "Доход",
-			Amount:       transfer.Amount,
-			Counterparty: transfer.FromAccount.UserID,
-			Reference:    fmt.Sprintf("transfer_%d", transfer.ID),
-		})
+			Direction:    row.Direction,
+			Operation:    row.Operation,
+			Amount:       row.Amount.Normalize(row.Currency),
+			Currency:     row.Currency,
+			Counterparty: row.Counterparty,
+			Reference:    fmt.Sprintf("entry_%d", row.EntryID),
+			Description:  row.Description,
+			EntryID:      row.EntryID,
+		}
+		if row.TransferID != nil {
+			item.TransferID = *row.TransferID
+			item.Reference = fmt.Sprintf("transfer_%d", *row.TransferID)
+		}
+		if row.Direction == HistoryDirectionOut {
+			item.Type = "Расход"
+		}
+		if row.BalanceAfter != nil {
+			balanceAfter := row.BalanceAfter.Normalize(account.Currency)
+			item.BalanceAfter = &balanceAfter
+		}
+		if row.OrderID != nil {
+			item.Reference = fmt.Sprintf("order_%d", *row.OrderID)
+		}
+		response.History = append(response.History, item)
+	}
+
+	return response, nil
+}
+
+// GetPeriodHistory returns every history line in [from, to) oldest first,
+// together with the opening and closing balances of the period. It is used
+// for statements, which are not paginated.
+func (s *HistoryService) GetPeriodHistory(actor Actor, accountID uint, from, to time.Time) (*HistoryResponse, error) {
+	account, err := s.authorizedAccount(actor, accountID)
+	if err != nil {
+		return nil, err
+	}
+	filter := HistoryFilter{From: &from, To: &to, Limit: MaxHistoryLimit}
+
+	var period *HistoryResponse
+	for {
+		page, err := s.accountHistory(account, filter)
+		if err != nil {
+			return nil, err
+		}
+		if period == nil {
+			period = page
+		} else {
+			period.History = append(period.History, page.History...)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
 	}
 
-	// Get orders where user is buyer (Расход)
-	var orders []models.Order
-	if err := s.db.Preload("Product").Where("user_id = ?", userID).Find(&orders).Error; err != nil {
-		return nil, fmt.Errorf("failed to get orders: %w", err)
+	for i, j := 0, len(period.History)-1; i < j; i, j = i+1, j-1 {
+		period.History[i], period.History[j] = period.History[j], period.History[i]
 	}
+	period.NextCursor = ""
+	return period, nil
+}
 
-	for _, order := range orders {
-		history = append(history, HistoryItem{
-			Date:         order.CreatedAt,
-			Type:         "Расход",
-			Amount:       order.Amount,
-			Counterparty: "marketplace", // Системный аккаунт маркетплейса
-			Reference:    fmt.Sprintf("order_%d", order.ID),
+// Statement returns GetPeriodHistory as a statement ready to render.
+func (s *HistoryService) Statement(actor Actor, accountID uint, from, to time.Time) (*statement.Statement, error) {
+	history, err := s.GetPeriodHistory(actor, accountID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &statement.Statement{
+		AccountID:      history.AccountID,
+		UserID:         history.UserID,
+		Currency:       history.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: history.OpeningBalance,
+		ClosingBalance: history.ClosingBalance,
+		GeneratedAt:    time.Now(),
+	}
+	for _, item := range history.History {
+		direction := statement.Credit
+		if item.Direction == HistoryDirectionOut {
+			direction = statement.Debit
+		}
+		// Transfer lines keep the IDs earlier statements gave them
+		id := fmt.Sprintf("E%d", item.EntryID)
+		if item.TransferID != 0 {
+			id = fmt.Sprintf("T%d", item.TransferID)
+		}
+		stmt.Lines = append(stmt.Lines, statement.Line{
+			ID:           id,
+			Date:         item.Date,
+			Direction:    direction,
+			Amount:       item.Amount,
+			Currency:     item.Currency,
+			Operation:    item.Operation,
+			Counterparty: item.Counterparty,
+			Reference:    item.Reference,
+			BalanceAfter: item.BalanceAfter,
 		})
 	}
+	return stmt, nil
+}
+
+// historyQuery applies the filter to historySQL and asks for one row more
+// than the page holds, which tells whether there is a next page.
+func historyQuery(accountID uint, filter HistoryFilter) (string, []interface{}, error) {
+	var conditions []string
+	args := []interface{}{accountID}
 
-	// Sort by date (newest first)
-	for i := 0; i < len(history)-1; i++ {
-		for j := i + 1; j < len(history); j++ {
-			if history[i].Date.Before(history[j].Date) {
-				history[i], history[j] = history[j], history[i]
-			}
+	if filter.Direction != "" {
+		conditions = append(conditions, "direction = ?")
+		args = append(args, filter.Direction)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, *filter.To)
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "amount >= CAST(? AS DECIMAL(15,2))")
+		args = append(args, filter.MinAmount.String())
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "amount <= CAST(? AS DECIMAL(15,2))")
+		args = append(args, filter.MaxAmount.String())
+	}
+	if filter.Counterparty != "" {
+		conditions = append(conditions, "counterparty = ?")
+		args = append(args, filter.Counterparty)
+	}
+	if filter.Operation != "" {
+		conditions = append(conditions, "operation = ?")
+		args = append(args, filter.Operation)
+	}
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeHistoryCursor(filter.Cursor)
+		if err != nil {
+			return "", nil, err
 		}
+		conditions = append(conditions, "(created_at < ? OR (created_at = ? AND posting_id < ?))")
+		args = append(args, createdAt, createdAt, id)
 	}
 
-	return &HistoryResponse{
-		UserID:   userID,
-		History:  history,
-		Balance:  account.Balance,
-		Currency: account.Currency,
-	}, nil
+	query := "SELECT * FROM (" + historySQL + ") history"
+	if len(conditions) > 0 {
+		query += "\nWHERE " + strings.Join(conditions, " AND ")
+	}
+	query += "\nORDER BY created_at DESC, posting_id DESC\nLIMIT ?"
+	args = append(args, filter.Limit+1)
+	return query, args, nil
+}
+
+// The cursor is the (created_at, posting id) of the last item of a page, so
+// pages stay stable when new postings arrive. An entry may post to the same
+// account more than once, so the entry id would not tell its lines apart.
+func encodeHistoryCursor(createdAt time.Time, id uint) string {
+	raw := fmt.Sprintf("%d:%d", createdAt.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(cursor string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, ErrInvalidCursor
+	}
+	return time.Unix(0, nanos), uint(id), nil
 }
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+func TestHistoryPagination(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	orders := NewOrderService(db, transfers, journal)
+	history := NewHistoryService(db, journal)
+
+	createFundedAccount(t, db, models.SystemUserID, "UZS", "0")
+	alice := createFundedAccount(t, db, "alice", "UZS", "1000.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "1000.00")
+
+	for i := 0; i < 5; i++ {
+		if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: bob.ID, ToAccountID: alice.ID, Amount: "250.00"}); err != nil {
+		t.Fatal(err)
+	}
+	product := models.Product{Name: "Book", Price: money.MustParse("30.00"), Stock: 1}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.CreateOrder(Actor{UserID: "alice"}, CreateOrderRequest{UserID: "alice", ProductID: product.ID, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	admin := Actor{UserID: "admin", Role: models.RoleAdmin}
+	if _, err := journal.Adjust(admin, AdjustmentRequest{AccountID: alice.ID, Amount: "-5.00", Reason: "duplicate fee"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []HistoryItem
+	cursor := ""
+	for page := 0; ; page++ {
+		resp, err := history.GetAccountHistory(testOperator, alice.ID, HistoryFilter{Limit: 3, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		seen = append(seen, resp.History...)
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	// The funding and the adjustment have no transfer but are listed too
+	if len(seen) != 9 {
+		t.Fatalf("paged through %d items, want 9", len(seen))
+	}
+	for i := 1; i < len(seen); i++ {
+		prev, cur := seen[i-1], seen[i]
+		if cur.Date.After(prev.Date) || (cur.Date.Equal(prev.Date) && cur.EntryID >= prev.EntryID) {
+			t.Fatalf("items %d and %d are out of order", i-1, i)
+		}
+	}
+	if adjustment := seen[0]; adjustment.Operation != OperationAdjustment || adjustment.Direction != HistoryDirectionOut ||
+		adjustment.Counterparty != models.IssuanceUserID || adjustment.Description != "Adjustment: duplicate fee" {
+		t.Fatalf("newest item = %+v, want the adjustment", adjustment)
+	}
+	if funding := seen[len(seen)-1]; funding.Operation != OperationOpeningBalance || funding.Amount.String() != "1000.00" || funding.TransferID != 0 {
+		t.Fatalf("oldest item = %+v, want the funding", funding)
+	}
+	// Walking back from the newest line must end at a zero balance
+	balance := balanceOf(t, db, alice.ID)
+	for _, item := range seen {
+		if item.BalanceAfter == nil || item.BalanceAfter.Cmp(balance) != 0 {
+			t.Fatalf("balance_after of %s = %v, want %s", item.Reference, item.BalanceAfter, balance)
+		}
+		if item.Direction == HistoryDirectionOut {
+			balance = balance.Add(item.Amount)
+		} else {
+			balance = balance.Sub(item.Amount)
+		}
+	}
+	if !balance.IsZero() {
+		t.Fatalf("balance before the first operation = %s, want 0", balance)
+	}
+
+	if seen[1].Operation != OperationOrder || seen[1].Counterparty != "marketplace" {
+		t.Fatalf("second newest item = %+v, want the order payment", seen[1])
+	}
+
+	incoming, err := history.GetAccountHistory(testOperator, alice.ID, HistoryFilter{Direction: HistoryDirectionIn, Operation: OperationTransfer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(incoming.History) != 1 || incoming.History[0].Amount.String() != "250.00" || incoming.History[0].Type != "Доход" {
+		t.Fatalf("incoming = %+v", incoming.History)
+	}
+
+	// Order payments show the marketplace as counterparty and filter on it
+	paid, err := history.GetAccountHistory(testOperator, alice.ID, HistoryFilter{Counterparty: "marketplace"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paid.History) != 1 || paid.History[0].Operation != OperationOrder {
+		t.Fatalf("marketplace filter = %+v, want the order payment", paid.History)
+	}
+
+	min := money.MustParse("20")
+	large, err := history.GetAccountHistory(testOperator, alice.ID, HistoryFilter{MinAmount: &min, Counterparty: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(large.History) != 1 {
+		t.Fatalf("min_amount filter returned %d items, want 1", len(large.History))
+	}
+
+	future := time.Now().Add(time.Hour)
+	if resp, err := history.GetAccountHistory(testOperator, alice.ID, HistoryFilter{From: &future}); err != nil || len(resp.History) != 0 {
+		t.Fatalf("from filter = %v, %v", resp, err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	period, err := history.GetAccountHistory(testOperator, alice.ID, HistoryFilter{From: &past, To: &future})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if period.OpeningBalance.String() != "0.00" || period.ClosingBalance.Cmp(balanceOf(t, db, alice.ID)) != 0 {
+		t.Fatalf("opening = %s, closing = %s", period.OpeningBalance, period.ClosingBalance)
+	}
+
+	if _, err := history.GetAccountHistory(testOperator, alice.ID, HistoryFilter{Cursor: "not-a-cursor"}); err == nil {
+		t.Fatal("invalid cursor accepted")
+	}
+	if _, err := history.GetAccountHistory(Actor{UserID: "bob", Role: models.RoleCustomer}, alice.ID, HistoryFilter{}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("history of another user's account = %v, want ErrForbidden", err)
+	}
+}
+
+// A statement lists every posting, so its lines lead from the opening to the
+// closing balance even with funding and adjustments in the period.
+func TestStatementBalances(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	history := NewHistoryService(db, journal)
+
+	from := time.Now().Add(-time.Hour)
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "30.00"}); err != nil {
+		t.Fatal(err)
+	}
+	admin := Actor{UserID: "admin", Role: models.RoleAdmin}
+	if _, err := journal.Adjust(admin, AdjustmentRequest{AccountID: alice.ID, Amount: "2.50", Reason: "goodwill"}); err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, err := history.Statement(testOperator, alice.ID, from, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmt.Lines) != 3 {
+		t.Fatalf("statement has %d lines, want 3", len(stmt.Lines))
+	}
+	balance := stmt.OpeningBalance
+	ids := map[string]bool{}
+	for _, line := range stmt.Lines {
+		balance = balance.Add(line.Signed())
+		if line.BalanceAfter == nil || line.BalanceAfter.Cmp(balance) != 0 {
+			t.Fatalf("line %s balance_after = %v, want %s", line.ID, line.BalanceAfter, balance)
+		}
+		if ids[line.ID] {
+			t.Fatalf("line ID %s is repeated", line.ID)
+		}
+		ids[line.ID] = true
+	}
+	if balance.Cmp(stmt.ClosingBalance) != 0 || stmt.ClosingBalance.String() != "72.50" {
+		t.Fatalf("lines end at %s, closing balance is %s", balance, stmt.ClosingBalance)
+	}
+}
+
+// An entry may post to one account many times. Paging through them must not
+// skip any, and a period spanning several pages is authorized, and an
+// override audited, once.
+func TestPeriodHistorySpansPages(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	history := NewHistoryService(db, journal)
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "10.00")
+	var lines []PostingLine
+	for len(lines) < MaxHistoryLimit+2 {
+		lines = append(lines,
+			PostingLine{AccountID: alice.ID, Direction: models.PostingDebit, Amount: money.MustParse("1.00")},
+			PostingLine{AccountID: alice.ID, Direction: models.PostingCredit, Amount: money.MustParse("1.00")})
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return journal.Post(tx, &models.JournalEntry{Type: models.EntryTypeAdjustment, Description: "churn"}, lines)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operator := Actor{UserID: "operator", Role: models.RoleOperator, OverrideReason: "statement request"}
+	period, err := history.GetPeriodHistory(operator, alice.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(period.History) != len(lines)+1 {
+		t.Fatalf("period has %d lines, want %d", len(period.History), len(lines)+1)
+	}
+	var overrides int64
+	db.Model(&models.AuditEvent{}).Where("action = ? AND actor_user_id = ?", models.AuditOwnershipOverride, "operator").Count(&overrides)
+	if overrides != 1 {
+		t.Fatalf("%d override audit events, want 1", overrides)
+	}
+}
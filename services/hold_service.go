@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type HoldService struct {
+	db      *gorm.DB
+	journal *JournalService
+	ttl     time.Duration
+	stepUp  StepUpPolicy
+}
+
+func NewHoldService(db *gorm.DB, journal *JournalService, ttl time.Duration, stepUp StepUpPolicy) *HoldService {
+	return &HoldService{db: db, journal: journal, ttl: ttl, stepUp: stepUp}
+}
+
+// AuthorizeRequest reserves Amount on the sender's account for a later
+// capture to ToAccountID. ExpiresIn is a Go duration such as "30m"; it
+// defaults to the configured hold TTL and may not exceed it.
+type AuthorizeRequest struct {
+	FromAccountID uint   `json:"from_account_id" binding:"required"`
+	ToAccountID   uint   `json:"to_account_id" binding:"required"`
+	Amount        string `json:"amount" binding:"required"`
+	ExpiresIn     string `json:"expires_in"`
+}
+
+// CaptureRequest settles a hold. An empty Amount captures the full hold; a
+// smaller amount captures part of it and releases the rest.
+type CaptureRequest struct {
+	Amount string `json:"amount"`
+}
+
+var (
+	ErrHoldNotFound   = errors.New("hold not found")
+	ErrHoldNotPending = errors.New("hold is no longer pending")
+	ErrHoldExpired    = errors.New("hold has expired")
+)
+
+func (s *HoldService) Authorize(actor Actor, req AuthorizeRequest) (*models.Hold, error) {
+	if req.FromAccountID == req.ToAccountID {
+		return nil, errors.New("cannot hold funds for the same account")
+	}
+
+	ttl := s.ttl
+	if req.ExpiresIn != "" {
+		parsed, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid expires_in %q", req.ExpiresIn)
+		}
+		if parsed > s.ttl {
+			return nil, fmt.Errorf("expires_in %s exceeds the maximum hold duration of %s", parsed, s.ttl)
+		}
+		ttl = parsed
+	}
+
+	var hold *models.Hold
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		fromAccount, toAccount, err := lockAccountPair(tx, req.FromAccountID, req.ToAccountID)
+		if err != nil {
+			return err
+		}
+		if err := actor.Authorize(tx, fromAccount.UserID, "hold funds"); err != nil {
+			return err
+		}
+		if err := checkMovement(fromAccount, toAccount); err != nil {
+			return err
+		}
+		if fromAccount.Currency != toAccount.Currency {
+			return errors.New("currency mismatch between accounts")
+		}
+
+		amount, err := money.ParsePositive(req.Amount, fromAccount.Currency)
+		if err != nil {
+			return fmt.Errorf("invalid hold amount: %w", err)
+		}
+		if err := s.stepUp.check(actor, amount, fromAccount.Currency); err != nil {
+			return err
+		}
+		if fromAccount.AvailableBalance().Cmp(amount) < 0 {
+			return errors.New("insufficient funds")
+		}
+
+		// The transfer stays pending until the hold is captured or released
+		transfer := models.Transfer{
+			FromAccountID: fromAccount.ID,
+			ToAccountID:   toAccount.ID,
+			Amount:        amount,
+			Currency:      fromAccount.Currency,
+			Status:        models.TransferStatusPending,
+		}
+		if err := tx.Create(&transfer).Error; err != nil {
+			return fmt.Errorf("failed to create transfer record: %w", err)
+		}
+
+		fromAccount.HeldBalance = fromAccount.HeldBalance.Add(amount)
+		if err := updateBalance(tx, fromAccount); err != nil {
+			return err
+		}
+
+		hold = &models.Hold{
+			AccountID:      fromAccount.ID,
+			ToAccountID:    toAccount.ID,
+			TransferID:     transfer.ID,
+			Amount:         amount,
+			CapturedAmount: money.FromMinor(0, fromAccount.Currency),
+			Currency:       fromAccount.Currency,
+			Status:         models.HoldStatusPending,
+			ExpiresAt:      time.Now().Add(ttl),
+		}
+		if err := tx.Create(hold).Error; err != nil {
+			return fmt.Errorf("failed to create hold: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditHoldAuthorize, EntityType: "hold", EntityID: hold.ID, After: hold})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (s *HoldService) Capture(actor Actor, id uint, req CaptureRequest) (*models.Hold, error) {
+	var hold *models.Hold
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		hold, err = lockHold(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := requireHoldPayee(tx, actor, hold); err != nil {
+			return err
+		}
+		if hold.Status != models.HoldStatusPending {
+			return ErrHoldNotPending
+		}
+		if !time.Now().Before(hold.ExpiresAt) {
+			return ErrHoldExpired
+		}
+
+		amount := hold.Amount
+		if req.Amount != "" {
+			amount, err = money.ParsePositive(req.Amount, hold.Currency)
+			if err != nil {
+				return fmt.Errorf("invalid capture amount: %w", err)
+			}
+			if amount.Cmp(hold.Amount) > 0 {
+				return fmt.Errorf("capture amount %s exceeds held amount %s", amount, hold.Amount)
+			}
+		}
+
+		fromAccount, _, err := lockAccountPair(tx, hold.AccountID, hold.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		// Release the whole reservation, then post what is actually captured
+		fromAccount.HeldBalance = fromAccount.HeldBalance.Sub(hold.Amount)
+		if err := updateBalance(tx, fromAccount); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Capture of hold %d from account %d to account %d", hold.ID, hold.AccountID, hold.ToAccountID)
+		if _, err := s.journal.Move(tx, models.EntryTypeTransfer, &hold.TransferID, hold.AccountID, hold.ToAccountID, amount, description); err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Transfer{}).Where("id = ?", hold.TransferID).
+			Updates(map[string]interface{}{"amount": amount, "status": models.TransferStatusCompleted}).Error
+		if err != nil {
+			return fmt.Errorf("failed to complete transfer: %w", err)
+		}
+
+		before := *hold
+		now := time.Now()
+		hold.Status = models.HoldStatusCaptured
+		hold.CapturedAmount = amount
+		hold.ReleasedAt = &now
+		if err := tx.Save(hold).Error; err != nil {
+			return fmt.Errorf("failed to update hold: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditHoldCapture, EntityType: "hold", EntityID: hold.ID, Before: before, After: hold})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (s *HoldService) Void(actor Actor, id uint) (*models.Hold, error) {
+	var hold *models.Hold
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		hold, err = lockHold(tx, id)
+		if err != nil {
+			return err
+		}
+		// Accounts are locked before an override is audited, see recordAudit
+		if _, err := lockAccounts(tx, hold.AccountID); err != nil {
+			return fmt.Errorf("failed to lock account: %w", err)
+		}
+		if err := authorizeHoldPayee(tx, actor, hold, "void a hold"); err != nil {
+			return err
+		}
+		if hold.Status != models.HoldStatusPending {
+			return ErrHoldNotPending
+		}
+		before := *hold
+		if err := releaseHold(tx, hold, models.HoldStatusVoided); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditHoldVoid, EntityType: "hold", EntityID: hold.ID, Before: before, After: hold})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (s *HoldService) GetHold(actor Actor, id uint) (*models.Hold, error) {
+	var hold models.Hold
+	if err := s.db.First(&hold, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+	if err := authorizeHoldParty(s.db, actor, &hold, "view a hold"); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// authorizeHoldParty lets the payer and the payee of a hold see it.
+func authorizeHoldParty(db *gorm.DB, actor Actor, hold *models.Hold, action string) error {
+	payer, payee, err := holdParties(db, hold)
+	if err != nil {
+		return err
+	}
+	if actor.owns(payee) {
+		return nil
+	}
+	return actor.Authorize(db, payer, action)
+}
+
+// authorizeHoldPayee lets the payee of a hold, or an ownership override,
+// act on it. The funds are reserved for the payee, so a payer releasing them
+// would leave the hold guaranteeing nothing.
+func authorizeHoldPayee(db *gorm.DB, actor Actor, hold *models.Hold, action string) error {
+	_, payee, err := holdParties(db, hold)
+	if err != nil {
+		return err
+	}
+	return actor.Authorize(db, payee, action)
+}
+
+// requireHoldPayee lets only the payee itself capture a hold, since only the
+// payee knows what it is owed.
+func requireHoldPayee(db *gorm.DB, actor Actor, hold *models.Hold) error {
+	_, payee, err := holdParties(db, hold)
+	if err != nil {
+		return err
+	}
+	if !actor.owns(payee) {
+		return fmt.Errorf("%w: only the payee can capture a hold", ErrForbidden)
+	}
+	return nil
+}
+
+// holdParties returns the users owning the payer and payee accounts of a hold.
+func holdParties(db *gorm.DB, hold *models.Hold) (payer, payee string, err error) {
+	var parties []models.Account
+	if err := db.Where("id IN ?", []uint{hold.AccountID, hold.ToAccountID}).Find(&parties).Error; err != nil {
+		return "", "", fmt.Errorf("failed to load hold accounts: %w", err)
+	}
+	for _, party := range parties {
+		switch party.ID {
+		case hold.AccountID:
+			payer = party.UserID
+		case hold.ToAccountID:
+			payee = party.UserID
+		}
+	}
+	return payer, payee, nil
+}
+
+// ExpireHolds releases every pending hold whose expiry is at or before now
+// and returns how many were expired.
+func (s *HoldService) ExpireHolds(now time.Time) (int, error) {
+	var ids []uint
+	err := s.db.Model(&models.Hold{}).
+		Where("status = ? AND expires_at <= ?", models.HoldStatusPending, now).
+		Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired holds: %w", err)
+	}
+
+	expired := 0
+	for _, id := range ids {
+		released := false
+		err := inTransaction(s.db, func(tx *gorm.DB) error {
+			released = false
+			hold, err := lockHold(tx, id)
+			if err != nil {
+				return err
+			}
+			// Captured or voided since the scan
+			if hold.Status != models.HoldStatusPending {
+				return nil
+			}
+			released = true
+			before := *hold
+			if err := releaseHold(tx, hold, models.HoldStatusExpired); err != nil {
+				return err
+			}
+			return recordAudit(tx, SystemActor, AuditRecord{Action: models.AuditHoldExpire, EntityType: "hold", EntityID: hold.ID, Before: before, After: hold})
+		})
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire hold %d: %w", id, err)
+		}
+		if released {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// RunExpiry calls ExpireHolds every interval until ctx is cancelled.
+func (s *HoldService) RunExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := s.ExpireHolds(now)
+			if err != nil {
+				log.Printf("Hold expiry failed: %v", err)
+			}
+			if expired > 0 {
+				log.Printf("Expired %d holds", expired)
+			}
+		}
+	}
+}
+
+func lockHold(tx *gorm.DB, id uint) (*models.Hold, error) {
+	var hold models.Hold
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&hold, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hold: %w", err)
+	}
+	return &hold, nil
+}
+
+// releaseHold returns the reserved funds to the available balance and closes
+// the hold and its pending transfer with the given status.
+func releaseHold(tx *gorm.DB, hold *models.Hold, status models.HoldStatus) error {
+	accounts, err := lockAccounts(tx, hold.AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to lock account: %w", err)
+	}
+	account := accounts[hold.AccountID]
+	account.HeldBalance = account.HeldBalance.Sub(hold.Amount)
+	if err := updateBalance(tx, account); err != nil {
+		return err
+	}
+
+	err = tx.Model(&models.Transfer{}).Where("id = ?", hold.TransferID).
+		Update("status", models.TransferStatusVoided).Error
+	if err != nil {
+		return fmt.Errorf("failed to void transfer: %w", err)
+	}
+
+	now := time.Now()
+	hold.Status = status
+	hold.ReleasedAt = &now
+	if err := tx.Save(hold).Error; err != nil {
+		return fmt.Errorf("failed to update hold: %w", err)
+	}
+	return nil
+}
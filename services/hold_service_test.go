@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+)
+
+func TestHoldCaptureAndVoid(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	holds := NewHoldService(db, journal, time.Hour, StepUpPolicy{})
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	hold, err := holds.Authorize(testOperator, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "60.00"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	var account models.Account
+	db.First(&account, alice.ID)
+	if account.Balance.String() != "100.00" || account.AvailableBalance().String() != "40.00" {
+		t.Fatalf("after authorize ledger = %s, available = %s, want 100.00 and 40.00", account.Balance, account.AvailableBalance())
+	}
+
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "50.00"}); err == nil {
+		t.Fatal("transfer spending held funds succeeded")
+	}
+
+	// The payer cannot take back or settle what it reserved for the payee
+	payer := Actor{UserID: "alice", Role: models.RoleCustomer}
+	if _, err := holds.Capture(payer, hold.ID, CaptureRequest{}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("payer capture = %v, want ErrForbidden", err)
+	}
+	if _, err := holds.Void(payer, hold.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("payer void = %v, want ErrForbidden", err)
+	}
+	if _, err := holds.Capture(testOperator, hold.ID, CaptureRequest{}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("operator capture = %v, want ErrForbidden", err)
+	}
+
+	payee := Actor{UserID: "bob", Role: models.RoleCustomer}
+	captured, err := holds.Capture(payee, hold.ID, CaptureRequest{Amount: "45.50"})
+	if err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if captured.Status != models.HoldStatusCaptured || captured.CapturedAmount.String() != "45.50" {
+		t.Fatalf("captured hold = %+v", captured)
+	}
+
+	db.First(&account, alice.ID)
+	if account.Balance.String() != "54.50" || !account.HeldBalance.IsZero() {
+		t.Fatalf("after capture ledger = %s, held = %s, want 54.50 and 0", account.Balance, account.HeldBalance)
+	}
+	if got := balanceOf(t, db, bob.ID); got.String() != "45.50" {
+		t.Fatalf("bob balance = %s, want 45.50", got)
+	}
+
+	var transfer models.Transfer
+	db.First(&transfer, hold.TransferID)
+	if transfer.Status != models.TransferStatusCompleted || transfer.Amount.String() != "45.50" {
+		t.Fatalf("transfer = %s %s, want completed 45.50", transfer.Status, transfer.Amount)
+	}
+
+	if _, err := holds.Capture(payee, hold.ID, CaptureRequest{}); !errors.Is(err, ErrHoldNotPending) {
+		t.Fatalf("second capture error = %v, want ErrHoldNotPending", err)
+	}
+
+	second, err := holds.Authorize(testOperator, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "54.50"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if _, err := holds.Void(testOperator, second.ID); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+
+	db.First(&account, alice.ID)
+	if account.AvailableBalance().String() != "54.50" {
+		t.Fatalf("available after void = %s, want 54.50", account.AvailableBalance())
+	}
+	var voided models.Transfer
+	db.First(&voided, second.TransferID)
+	if voided.Status != models.TransferStatusVoided {
+		t.Fatalf("voided hold transfer status = %s", voided.Status)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestExpireHolds(t *testing.T) {
+	db := newTestDB(t)
+	holds := NewHoldService(db, NewJournalService(db), time.Hour, StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	// A client cannot keep funds reserved for longer than the configured TTL
+	if _, err := holds.Authorize(testOperator, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "30.00", ExpiresIn: "61m"}); err == nil {
+		t.Fatal("hold longer than the TTL was authorized")
+	}
+
+	short, err := holds.Authorize(testOperator, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "30.00", ExpiresIn: "1ms"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	long, err := holds.Authorize(testOperator, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "20.00"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, err := holds.Capture(Actor{UserID: "bob"}, short.ID, CaptureRequest{}); !errors.Is(err, ErrHoldExpired) {
+		t.Fatalf("capturing an expired hold = %v, want ErrHoldExpired", err)
+	}
+
+	expired, err := holds.ExpireHolds(time.Now())
+	if err != nil || expired != 1 {
+		t.Fatalf("ExpireHolds = %d, %v, want 1", expired, err)
+	}
+
+	stored, _ := holds.GetHold(testOperator, short.ID)
+	if stored.Status != models.HoldStatusExpired {
+		t.Fatalf("short hold status = %s, want expired", stored.Status)
+	}
+	if stored, _ := holds.GetHold(testOperator, long.ID); stored.Status != models.HoldStatusPending {
+		t.Fatalf("long hold status = %s, want pending", stored.Status)
+	}
+
+	var account models.Account
+	db.First(&account, alice.ID)
+	if account.HeldBalance.String() != "20.00" {
+		t.Fatalf("held balance = %s, want 20.00", account.HeldBalance)
+	}
+	assertLedgerBalanced(t, db)
+}
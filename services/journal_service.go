@@ -0,0 +1,434 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+type JournalService struct {
+	db *gorm.DB
+}
+
+func NewJournalService(db *gorm.DB) *JournalService {
+	return &JournalService{db: db}
+}
+
+// PostingLine is one side of a journal entry before it is persisted.
+type PostingLine struct {
+	AccountID uint
+	Direction models.PostingDirection
+	Amount    money.Amount
+}
+
+type CurrencyTotal struct {
+	Currency string       `json:"currency"`
+	Debits   money.Amount `json:"debits"`
+	Credits  money.Amount `json:"credits"`
+	Balanced bool         `json:"balanced"`
+}
+
+type BalanceMismatch struct {
+	AccountID uint         `json:"account_id"`
+	UserID    string       `json:"user_id"`
+	Currency  string       `json:"currency"`
+	Cached    money.Amount `json:"cached_balance"`
+	Projected money.Amount `json:"projected_balance"`
+}
+
+// AdjustmentRequest corrects an account balance by a signed amount: a
+// positive amount credits the account, a negative one debits it.
+type AdjustmentRequest struct {
+	AccountID uint   `json:"account_id" binding:"required"`
+	Amount    string `json:"amount" binding:"required"`
+	Reason    string `json:"reason" binding:"required,max=255"`
+}
+
+type JournalCheckResponse struct {
+	Balanced   bool              `json:"balanced"`
+	Currencies []CurrencyTotal   `json:"currencies"`
+	Mismatches []BalanceMismatch `json:"mismatches"`
+}
+
+var ErrUnbalancedEntry = errors.New("journal entry does not balance")
+
+// Post validates that the lines balance per currency, persists the entry with
+// its postings and applies them to the cached account balances. It must run
+// inside the caller's transaction. Entries dated on or before the last closed
+// day are rejected with ErrPeriodClosed.
+func (s *JournalService) Post(tx *gorm.DB, entry *models.JournalEntry, lines []PostingLine) error {
+	if len(lines) < 2 {
+		return fmt.Errorf("%w: at least two postings are required", ErrUnbalancedEntry)
+	}
+
+	postedAt := entry.CreatedAt
+	if postedAt.IsZero() {
+		postedAt = time.Now()
+	}
+	if err := checkPeriodOpen(tx, postedAt); err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(lines))
+	for _, line := range lines {
+		ids = append(ids, line.AccountID)
+	}
+	accounts, err := lockAccounts(tx, ids...)
+	if err != nil {
+		return fmt.Errorf("posting account not found: %w", err)
+	}
+
+	totals := make(map[string]money.Amount)
+	postings := make([]models.Posting, 0, len(lines))
+	for _, line := range lines {
+		account := accounts[line.AccountID]
+		if err := money.Validate(line.Amount, account.Currency); err != nil {
+			return fmt.Errorf("invalid posting amount for account %d: %w", account.ID, err)
+		}
+
+		switch line.Direction {
+		case models.PostingDebit:
+			err = checkCanSend(account)
+		case models.PostingCredit:
+			err = checkCanReceive(account)
+		default:
+			return fmt.Errorf("invalid posting direction %q", line.Direction)
+		}
+		if err != nil {
+			return err
+		}
+
+		posting := models.Posting{
+			AccountID: account.ID,
+			Direction: line.Direction,
+			Amount:    line.Amount,
+			Currency:  account.Currency,
+		}
+
+		totals[account.Currency] = totals[account.Currency].Add(posting.Signed())
+		account.Balance = account.Balance.Add(posting.Signed())
+		balanceAfter := account.Balance.Round(money.Scale(account.Currency), money.RoundHalfEven)
+		posting.BalanceAfter = &balanceAfter
+		postings = append(postings, posting)
+	}
+
+	for currency, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("%w: %s is off by %s", ErrUnbalancedEntry, currency, total)
+		}
+	}
+
+	for _, account := range accounts {
+		if account.AvailableBalance().IsNegative() && !account.AllowsNegativeBalance() {
+			return reject(errors.New("insufficient funds"))
+		}
+	}
+
+	if err := tx.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+	for i := range postings {
+		postings[i].EntryID = entry.ID
+		postings[i].CreatedAt = entry.CreatedAt
+	}
+	if err := tx.Create(&postings).Error; err != nil {
+		return fmt.Errorf("failed to create postings: %w", err)
+	}
+	entry.Postings = postings
+
+	for _, account := range accounts {
+		account.Balance = account.Balance.Round(money.Scale(account.Currency), money.RoundHalfEven)
+		if err := updateBalance(tx, account); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Move posts a two-legged entry debiting from and crediting to.
+func (s *JournalService) Move(tx *gorm.DB, entryType models.EntryType, transferID *uint, fromAccountID, toAccountID uint, amount money.Amount, description string) (*models.JournalEntry, error) {
+	entry := &models.JournalEntry{
+		Type:        entryType,
+		TransferID:  transferID,
+		Description: description,
+	}
+	err := s.Post(tx, entry, []PostingLine{
+		{AccountID: fromAccountID, Direction: models.PostingDebit, Amount: amount},
+		{AccountID: toAccountID, Direction: models.PostingCredit, Amount: amount},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Fund brings new money into the ledger by crediting the account against
+// the issuance account of its currency.
+func (s *JournalService) Fund(tx *gorm.DB, entryType models.EntryType, account *models.Account, amount money.Amount, description string) (*models.JournalEntry, error) {
+	issuance, err := s.systemAccount(tx, models.IssuanceUserID, account.Currency)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.Move(tx, entryType, nil, issuance.ID, account.ID, amount, description)
+	if err != nil {
+		return nil, err
+	}
+	account.Balance = account.Balance.Add(amount)
+	return entry, nil
+}
+
+// Adjust posts a manual correction against the issuance account of the
+// account's currency. The reason is kept as the entry description.
+func (s *JournalService) Adjust(actor Actor, req AdjustmentRequest) (*models.JournalEntry, error) {
+	if !actor.Can(models.PermissionLedgerAdjust) {
+		return nil, fmt.Errorf("%w: cannot adjust balances", ErrForbidden)
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("an adjustment needs a reason")
+	}
+
+	var entry *models.JournalEntry
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var account models.Account
+		if err := tx.First(&account, req.AccountID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAccountNotFound
+			}
+			return fmt.Errorf("failed to find account: %w", err)
+		}
+		amount, err := money.ParseIn(req.Amount, account.Currency)
+		if err != nil {
+			return fmt.Errorf("invalid amount: %w", err)
+		}
+		if err := money.Validate(amount.Abs(), account.Currency); err != nil {
+			return fmt.Errorf("invalid amount: %w", err)
+		}
+
+		issuance, err := s.systemAccount(tx, models.IssuanceUserID, account.Currency)
+		if err != nil {
+			return err
+		}
+		fromID, toID := issuance.ID, account.ID
+		if amount.IsNegative() {
+			fromID, toID = account.ID, issuance.ID
+		}
+		entry, err = s.Move(tx, models.EntryTypeAdjustment, nil, fromID, toID, amount.Abs(), "Adjustment: "+reason)
+		if err != nil {
+			return err
+		}
+
+		after := account
+		if err := tx.First(&after, account.ID).Error; err != nil {
+			return fmt.Errorf("failed to reload account: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditLedgerAdjust, EntityType: "account", EntityID: account.ID, Before: account, After: after})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// systemAccount returns the internal account of userID in currency,
+// creating it on first use.
+func (s *JournalService) systemAccount(tx *gorm.DB, userID, currency string) (*models.Account, error) {
+	var account models.Account
+	err := tx.Where("user_id = ? AND currency = ?", userID, currency).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to find %s account: %w", userID, err)
+	}
+
+	account = models.Account{
+		UserID:   userID,
+		Currency: currency,
+		Balance:  money.FromMinor(0, currency),
+	}
+	if err := tx.Create(&account).Error; err != nil {
+		return nil, fmt.Errorf("failed to create %s account: %w", userID, err)
+	}
+	return &account, nil
+}
+
+// Check verifies that debits equal credits for every currency and that each
+// cached account balance equals the sum of its postings.
+func (s *JournalService) Check() (*JournalCheckResponse, error) {
+	response := &JournalCheckResponse{
+		Balanced:   true,
+		Currencies: []CurrencyTotal{},
+		Mismatches: []BalanceMismatch{},
+	}
+
+	projected := make(map[uint]money.Amount)
+	totals := make(map[string]*CurrencyTotal)
+	var order []string
+
+	rows, err := s.db.Model(&models.Posting{}).Select("account_id, direction, amount, currency").Order("id").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var posting models.Posting
+		if err := rows.Scan(&posting.AccountID, &posting.Direction, &posting.Amount, &posting.Currency); err != nil {
+			return nil, fmt.Errorf("failed to read posting: %w", err)
+		}
+
+		total, ok := totals[posting.Currency]
+		if !ok {
+			total = &CurrencyTotal{Currency: posting.Currency}
+			totals[posting.Currency] = total
+			order = append(order, posting.Currency)
+		}
+		if posting.Direction == models.PostingDebit {
+			total.Debits = total.Debits.Add(posting.Amount)
+		} else {
+			total.Credits = total.Credits.Add(posting.Amount)
+		}
+		projected[posting.AccountID] = projected[posting.AccountID].Add(posting.Signed())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+	rows.Close()
+
+	for _, currency := range order {
+		total := totals[currency]
+		total.Debits = total.Debits.Normalize(currency)
+		total.Credits = total.Credits.Normalize(currency)
+		total.Balanced = total.Debits.Cmp(total.Credits) == 0
+		if !total.Balanced {
+			response.Balanced = false
+		}
+		response.Currencies = append(response.Currencies, *total)
+	}
+
+	var accounts []models.Account
+	if err := s.db.Order("id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+	for _, account := range accounts {
+		expected := projected[account.ID].Normalize(account.Currency)
+		if account.Balance.Cmp(expected) != 0 {
+			response.Balanced = false
+			response.Mismatches = append(response.Mismatches, BalanceMismatch{
+				AccountID: account.ID,
+				UserID:    account.UserID,
+				Currency:  account.Currency,
+				Cached:    account.Balance,
+				Projected: expected,
+			})
+		}
+	}
+
+	return response, nil
+}
+
+// BackfillOpeningBalances gives every account that predates the journal an
+// opening entry for its current balance, so that its cached balance is
+// backed by postings.
+func (s *JournalService) BackfillOpeningBalances() error {
+	var accounts []models.Account
+	err := s.db.Where("NOT EXISTS (SELECT 1 FROM postings WHERE postings.account_id = accounts.id)").
+		Find(&accounts).Error
+	if err != nil {
+		return fmt.Errorf("failed to find accounts without postings: %w", err)
+	}
+
+	for _, account := range accounts {
+		if account.Balance.IsZero() || account.AllowsNegativeBalance() {
+			continue
+		}
+
+		err := inTransaction(s.db, func(tx *gorm.DB) error {
+			locked, err := lockAccounts(tx, account.ID)
+			if err != nil {
+				return err
+			}
+			current := locked[account.ID]
+			opening := current.Balance
+			if opening.IsNegative() {
+				return fmt.Errorf("account %d has a negative balance %s", account.ID, opening)
+			}
+			current.Balance = money.FromMinor(0, current.Currency)
+			if err := updateBalance(tx, current); err != nil {
+				return err
+			}
+			_, err = s.Fund(tx, models.EntryTypeOpeningBalance, current, opening, "Opening balance carried over from pre-journal ledger")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to backfill account %d: %w", account.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// BackfillBalanceAfter fills Posting.BalanceAfter for postings written before
+// the column existed by replaying each affected account's postings in order.
+func (s *JournalService) BackfillBalanceAfter() error {
+	var accountIDs []uint
+	err := s.db.Model(&models.Posting{}).Where("balance_after IS NULL").
+		Distinct("account_id").Pluck("account_id", &accountIDs).Error
+	if err != nil {
+		return fmt.Errorf("failed to find postings without balance_after: %w", err)
+	}
+
+	for _, accountID := range accountIDs {
+		err := inTransaction(s.db, func(tx *gorm.DB) error {
+			if _, err := lockAccounts(tx, accountID); err != nil {
+				return err
+			}
+
+			var postings []models.Posting
+			if err := tx.Where("account_id = ?", accountID).Order("id").Find(&postings).Error; err != nil {
+				return err
+			}
+
+			var balance money.Amount
+			for _, posting := range postings {
+				balance = balance.Add(posting.Signed())
+				if posting.BalanceAfter != nil {
+					continue
+				}
+				after := balance.Round(money.Scale(posting.Currency), money.RoundHalfEven)
+				if err := tx.Model(&models.Posting{}).Where("id = ?", posting.ID).Update("balance_after", after).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to backfill balances of account %d: %w", accountID, err)
+		}
+	}
+
+	return nil
+}
+
+// BalanceAt returns the account's ledger balance just before the given time,
+// taken from the last posting made earlier.
+func (s *JournalService) BalanceAt(account *models.Account, at time.Time) (money.Amount, error) {
+	var posting models.Posting
+	err := s.db.Where("account_id = ? AND created_at < ? AND balance_after IS NOT NULL", account.ID, at).
+		Order("id DESC").Limit(1).Find(&posting).Error
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("failed to get balance of account %d: %w", account.ID, err)
+	}
+	if posting.ID == 0 {
+		return money.FromMinor(0, account.Currency), nil
+	}
+	return posting.BalanceAfter.Normalize(account.Currency), nil
+}
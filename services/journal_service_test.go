@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+func TestPostRejectsUnbalancedEntry(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return journal.Post(tx, &models.JournalEntry{Type: models.EntryTypeAdjustment}, []PostingLine{
+			{AccountID: alice.ID, Direction: models.PostingDebit, Amount: money.MustParse("10.00")},
+			{AccountID: bob.ID, Direction: models.PostingCredit, Amount: money.MustParse("9.99")},
+		})
+	})
+	if !errors.Is(err, ErrUnbalancedEntry) {
+		t.Fatalf("Post error = %v, want ErrUnbalancedEntry", err)
+	}
+
+	if got := balanceOf(t, db, alice.ID); got.Cmp(money.MustParse("100")) != 0 {
+		t.Fatalf("alice balance = %s, want unchanged 100.00", got)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestTransfersAndOrdersKeepLedgerBalanced(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	orders := NewOrderService(db, transfers, journal)
+
+	createFundedAccount(t, db, models.SystemUserID, "UZS", "0")
+	alice := createFundedAccount(t, db, "alice", "UZS", "1000.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	product := models.Product{Name: "Cheeseburger", Price: money.MustParse("35.50"), Stock: 10}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "100.25"}); err != nil {
+		t.Fatalf("TransferMoney: %v", err)
+	}
+	if _, err := orders.CreateOrder(Actor{UserID: "bob"}, CreateOrderRequest{UserID: "bob", ProductID: product.ID, Quantity: 2}); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	if got := balanceOf(t, db, bob.ID); got.String() != "29.25" {
+		t.Fatalf("bob balance = %s, want 29.25", got)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestBackfillOpeningBalances(t *testing.T) {
+	db := newTestDB(t)
+
+	legacy := models.Account{UserID: "legacy", Currency: "USD", Balance: money.MustParse("42.10")}
+	if err := db.Create(&legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	journal := NewJournalService(db)
+	if err := journal.BackfillOpeningBalances(); err != nil {
+		t.Fatalf("BackfillOpeningBalances: %v", err)
+	}
+	if err := journal.BackfillOpeningBalances(); err != nil {
+		t.Fatalf("second BackfillOpeningBalances: %v", err)
+	}
+
+	if got := balanceOf(t, db, legacy.ID); got.String() != "42.10" {
+		t.Fatalf("legacy balance = %s, want 42.10", got)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestBackfillBalanceAfter(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+	for _, amount := range []string{"10.00", "2.50"} {
+		if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: amount}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Simulate postings written before balance_after existed
+	if err := db.Model(&models.Posting{}).Where("1 = 1").Update("balance_after", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.BackfillBalanceAfter(); err != nil {
+		t.Fatalf("BackfillBalanceAfter: %v", err)
+	}
+
+	var postings []models.Posting
+	db.Where("account_id = ?", alice.ID).Order("id").Find(&postings)
+	want := []string{"100.00", "90.00", "87.50"}
+	if len(postings) != len(want) {
+		t.Fatalf("alice has %d postings, want %d", len(postings), len(want))
+	}
+	for i, posting := range postings {
+		if posting.BalanceAfter == nil || posting.BalanceAfter.Normalize("UZS").String() != want[i] {
+			t.Fatalf("posting %d balance_after = %v, want %s", i, posting.BalanceAfter, want[i])
+		}
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	account := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	admin := Actor{UserID: "root", Role: models.RoleAdmin}
+
+	if _, err := journal.Adjust(testOperator, AdjustmentRequest{AccountID: account.ID, Amount: "5.00", Reason: "goodwill"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("adjust by operator = %v, want ErrForbidden", err)
+	}
+	if _, err := journal.Adjust(admin, AdjustmentRequest{AccountID: account.ID, Amount: "5.00", Reason: " "}); err == nil {
+		t.Fatal("adjustment without a reason succeeded")
+	}
+	for _, amount := range []string{"0", "1.005", "abc"} {
+		if _, err := journal.Adjust(admin, AdjustmentRequest{AccountID: account.ID, Amount: amount, Reason: "typo"}); err == nil {
+			t.Fatalf("adjustment of %q succeeded", amount)
+		}
+	}
+
+	entry, err := journal.Adjust(admin, AdjustmentRequest{AccountID: account.ID, Amount: "-30.00", Reason: "duplicate fee"})
+	if err != nil {
+		t.Fatalf("debit adjustment: %v", err)
+	}
+	if entry.Type != models.EntryTypeAdjustment || entry.Description != "Adjustment: duplicate fee" {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if _, err := journal.Adjust(admin, AdjustmentRequest{AccountID: account.ID, Amount: "12.50", Reason: "goodwill"}); err != nil {
+		t.Fatalf("credit adjustment: %v", err)
+	}
+	if got := balanceOf(t, db, account.ID); got.String() != "82.50" {
+		t.Fatalf("balance = %s, want 82.50", got)
+	}
+	if _, err := journal.Adjust(admin, AdjustmentRequest{AccountID: account.ID, Amount: "-100.00", Reason: "too much"}); err == nil {
+		t.Fatal("adjustment below zero succeeded")
+	}
+
+	var events int64
+	db.Model(&models.AuditEvent{}).Where("action = ?", models.AuditLedgerAdjust).Count(&events)
+	if events != 2 {
+		t.Fatalf("audit events = %d, want 2", events)
+	}
+	assertLedgerBalanced(t, db)
+}
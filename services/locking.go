@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"bank-ledger-core/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrConcurrentUpdate is returned when an account row changed between being
+// read and written. It only occurs on databases without row locks (SQLite),
+// where the version column provides optimistic concurrency control.
+var ErrConcurrentUpdate = errors.New("account was modified concurrently")
+
+// ErrRejected matches a movement of money the ledger refused because of the
+// request or the state of the accounts, such as a malformed amount, a frozen
+// account or missing funds. Retrying such a request fails the same way;
+// other failures come from storage and may succeed on a retry.
+var ErrRejected = errors.New("rejected")
+
+// rejection marks an error as ErrRejected without changing its message.
+type rejection struct{ err error }
+
+func reject(err error) error { return rejection{err} }
+
+func (r rejection) Error() string        { return r.err.Error() }
+func (r rejection) Unwrap() error        { return r.err }
+func (r rejection) Is(target error) bool { return target == ErrRejected }
+
+// maxTransactionAttempts bounds how often a transaction that lost an
+// optimistic-locking race is retried.
+const maxTransactionAttempts = 5
+
+// lockOrder returns the distinct ids in the one global order accounts are
+// locked in, so that two transactions touching the same accounts cannot
+// deadlock: customer accounts first, then the ledger's own accounts
+// (marketplace, issuance, FX positions), each group in ascending ID order.
+// Post relies on this when it locks the system accounts of an entry after
+// the caller already holds the customer pair. The owner of an account never
+// changes, so it is read without a lock.
+func lockOrder(tx *gorm.DB, ids ...uint) ([]uint, error) {
+	sorted := make([]uint, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			sorted = append(sorted, id)
+		}
+	}
+
+	var owners []models.Account
+	if err := tx.Select("id", "user_id").Where("id IN ?", sorted).Find(&owners).Error; err != nil {
+		return nil, fmt.Errorf("failed to read account owners: %w", err)
+	}
+	ledger := make(map[uint]bool, len(owners))
+	for _, owner := range owners {
+		ledger[owner.ID] = models.IsReservedUserID(owner.UserID)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if ledger[sorted[i]] != ledger[sorted[j]] {
+			return !ledger[sorted[i]]
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted, nil
+}
+
+// lockAccounts loads the given accounts with SELECT ... FOR UPDATE in lock
+// order.
+func lockAccounts(tx *gorm.DB, ids ...uint) (map[uint]*models.Account, error) {
+	sorted, err := lockOrder(tx, ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make(map[uint]*models.Account, len(sorted))
+	for _, id := range sorted {
+		var account models.Account
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&account, id).Error; err != nil {
+			return nil, err
+		}
+		accounts[id] = &account
+	}
+	return accounts, nil
+}
+
+// lockAccountPair locks the sender and recipient of a movement in lock order
+// and reports which side is missing.
+func lockAccountPair(tx *gorm.DB, fromAccountID, toAccountID uint) (*models.Account, *models.Account, error) {
+	var fromAccount, toAccount models.Account
+	steps := []struct {
+		id      uint
+		account *models.Account
+		role    string
+	}{
+		{fromAccountID, &fromAccount, "sender"},
+		{toAccountID, &toAccount, "recipient"},
+	}
+	order, err := lockOrder(tx, fromAccountID, toAccountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order[0] != fromAccountID {
+		steps[0], steps[1] = steps[1], steps[0]
+	}
+
+	for _, step := range steps {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(step.account, step.id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, reject(fmt.Errorf("%s account not found", step.role))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to lock %s account: %w", step.role, err)
+		}
+	}
+	return &fromAccount, &toAccount, nil
+}
+
+// updateBalance writes the cached ledger and held balances guarded by the row
+// version read under lock, and bumps the version.
+func updateBalance(tx *gorm.DB, account *models.Account) error {
+	result := tx.Model(&models.Account{}).
+		Where("id = ? AND version = ?", account.ID, account.Version).
+		Updates(map[string]interface{}{
+			"balance":      account.Balance,
+			"held_balance": account.HeldBalance,
+			"version":      gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update balance of account %d: %w", account.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrConcurrentUpdate
+	}
+	account.Version++
+	return nil
+}
+
+// inTransaction runs fn in a database transaction and retries it when it
+// fails with ErrConcurrentUpdate.
+func inTransaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	var err error
+	for attempt := 0; attempt < maxTransactionAttempts; attempt++ {
+		err = db.Transaction(fn)
+		if !errors.Is(err, ErrConcurrentUpdate) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ThrottleLimits sets how many failed logins a key gets before backoff
+// starts and before it is locked out.
+type ThrottleLimits struct {
+	FreeAttempts int
+	MaxFailures  int
+}
+
+// LoginThrottleConfig tunes brute-force protection. Backoff after the free
+// attempts doubles from BackoffBase up to BackoffMax. Lockout is how long a
+// lock lasts and how long failures are remembered.
+type LoginThrottleConfig struct {
+	User        ThrottleLimits
+	IP          ThrottleLimits
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	Lockout     time.Duration
+}
+
+type LoginThrottleService struct {
+	db     *gorm.DB
+	clock  Clock
+	config LoginThrottleConfig
+}
+
+func NewLoginThrottleService(db *gorm.DB, clock Clock, config LoginThrottleConfig) *LoginThrottleService {
+	return &LoginThrottleService{db: db, clock: clock, config: config}
+}
+
+// LoginBlock explains why a login attempt is refused: the key is either
+// locked out or backing off after recent failures.
+type LoginBlock struct {
+	Locked     bool
+	Until      time.Time
+	RetryAfter time.Duration
+}
+
+var ErrNotLocked = errors.New("user has no failed logins")
+
+// Check reports whether a login for userID from ip must wait. It returns nil
+// when the attempt may go ahead.
+func (s *LoginThrottleService) Check(userID, ip string) (*LoginBlock, error) {
+	var throttles []models.LoginThrottle
+	if err := s.db.Where("key IN ?", []string{userThrottleKey(userID), ipThrottleKey(ip)}).Find(&throttles).Error; err != nil {
+		return nil, fmt.Errorf("failed to check login throttle: %w", err)
+	}
+
+	now := s.clock.Now()
+	var worst *LoginBlock
+	for i := range throttles {
+		block := s.block(&throttles[i], now)
+		if block == nil {
+			continue
+		}
+		if worst == nil || (block.Locked && !worst.Locked) || (block.Locked == worst.Locked && block.Until.After(worst.Until)) {
+			worst = block
+		}
+	}
+	if worst != nil {
+		worst.RetryAfter = worst.Until.Sub(now)
+	}
+	return worst, nil
+}
+
+// RecordFailure counts a failed password or two-factor code against both the
+// user and the address, locking either once it reaches its limit.
+func (s *LoginThrottleService) RecordFailure(actor Actor, userID, ip string) error {
+	keys := []string{userThrottleKey(userID), ipThrottleKey(ip)}
+	sort.Strings(keys)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		now := s.clock.Now()
+		for _, key := range keys {
+			throttle, err := lockLoginThrottle(tx, key)
+			if err != nil {
+				return err
+			}
+			if s.expired(throttle, now) {
+				throttle.Failures = 0
+				throttle.LockedUntil = nil
+			}
+			throttle.Failures++
+			throttle.LastFailedAt = now
+
+			limits, entityType, entityID := s.config.User, "user", userID
+			if key == ipThrottleKey(ip) {
+				limits, entityType, entityID = s.config.IP, "ip", ip
+			}
+			lockedNow := throttle.LockedUntil == nil && throttle.Failures >= limits.MaxFailures
+			if lockedNow {
+				until := now.Add(s.config.Lockout)
+				throttle.LockedUntil = &until
+			}
+
+			if err := tx.Save(throttle).Error; err != nil {
+				return fmt.Errorf("failed to record login failure: %w", err)
+			}
+			if lockedNow {
+				err := recordAudit(tx, actor, AuditRecord{Action: models.AuditAuthLockout, EntityType: entityType, EntityID: entityID, After: throttle})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// RecordSuccess clears the user's failures after a complete login. Failures
+// by address are kept, so one valid account cannot reset them.
+func (s *LoginThrottleService) RecordSuccess(userID string) error {
+	if err := s.db.Where("key = ?", userThrottleKey(userID)).Delete(&models.LoginThrottle{}).Error; err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
+
+// Unlock lifts a user's lockout and clears their failures before the
+// lockout runs out.
+func (s *LoginThrottleService) Unlock(actor Actor, userID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var throttle models.LoginThrottle
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", userThrottleKey(userID)).First(&throttle).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotLocked
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load login throttle: %w", err)
+		}
+		if err := tx.Delete(&throttle).Error; err != nil {
+			return fmt.Errorf("failed to unlock user: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAuthUnlock, EntityType: "user", EntityID: userID, Before: throttle})
+	})
+}
+
+func (s *LoginThrottleService) block(throttle *models.LoginThrottle, now time.Time) *LoginBlock {
+	if s.expired(throttle, now) {
+		return nil
+	}
+	if throttle.LockedUntil != nil {
+		return &LoginBlock{Locked: true, Until: *throttle.LockedUntil}
+	}
+
+	limits := s.config.User
+	if strings.HasPrefix(throttle.Key, ipThrottleKey("")) {
+		limits = s.config.IP
+	}
+	excess := throttle.Failures - limits.FreeAttempts
+	if excess < 0 {
+		return nil
+	}
+	delay := s.config.BackoffMax
+	if excess < 32 && s.config.BackoffBase<<excess < s.config.BackoffMax {
+		delay = s.config.BackoffBase << excess
+	}
+	if until := throttle.LastFailedAt.Add(delay); now.Before(until) {
+		return &LoginBlock{Until: until}
+	}
+	return nil
+}
+
+// expired reports whether a throttle's lock has run out or its failures are
+// too old to count.
+func (s *LoginThrottleService) expired(throttle *models.LoginThrottle, now time.Time) bool {
+	if throttle.LockedUntil != nil {
+		return !now.Before(*throttle.LockedUntil)
+	}
+	return now.Sub(throttle.LastFailedAt) > s.config.Lockout
+}
+
+func lockLoginThrottle(tx *gorm.DB, key string) (*models.LoginThrottle, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.LoginThrottle{Key: key}).Error; err != nil {
+		return nil, fmt.Errorf("failed to create login throttle: %w", err)
+	}
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&throttle).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock login throttle: %w", err)
+	}
+	return &throttle, nil
+}
+
+func userThrottleKey(userID string) string { return "user:" + userID }
+
+func ipThrottleKey(ip string) string { return "ip:" + ip }
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestLoginThrottle(t *testing.T) {
+	db := newTestDB(t)
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	throttle := NewLoginThrottleService(db, clock, LoginThrottleConfig{
+		User:        ThrottleLimits{FreeAttempts: 2, MaxFailures: 5},
+		IP:          ThrottleLimits{FreeAttempts: 100, MaxFailures: 100},
+		BackoffBase: time.Second,
+		BackoffMax:  4 * time.Second,
+		Lockout:     15 * time.Minute,
+	})
+	actor := Actor{UserID: "alice"}
+
+	check := func() *LoginBlock {
+		t.Helper()
+		block, err := throttle.Check("alice", "10.0.0.1")
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		return block
+	}
+	fail := func() {
+		t.Helper()
+		if err := throttle.RecordFailure(actor, "alice", "10.0.0.1"); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+	}
+
+	// Free attempts, then a delay that doubles up to the maximum
+	fail()
+	fail()
+	if block := check(); block == nil || block.Locked || block.RetryAfter != time.Second {
+		t.Fatalf("after 2 failures: %+v, want a 1s backoff", block)
+	}
+	clock.Advance(time.Second)
+	if block := check(); block != nil {
+		t.Fatalf("after backoff: %+v", block)
+	}
+	fail()
+	if block := check(); block == nil || block.RetryAfter != 2*time.Second {
+		t.Fatalf("after 3 failures: %+v, want a 2s backoff", block)
+	}
+	clock.Advance(2 * time.Second)
+	fail()
+	if block := check(); block == nil || block.RetryAfter != 4*time.Second {
+		t.Fatalf("after 4 failures: %+v, want the 4s maximum", block)
+	}
+
+	// Another user from a different address is unaffected
+	if block, _ := throttle.Check("bob", "10.0.0.2"); block != nil {
+		t.Fatalf("bob blocked: %+v", block)
+	}
+
+	clock.Advance(4 * time.Second)
+	fail()
+	block := check()
+	if block == nil || !block.Locked || block.RetryAfter != 15*time.Minute {
+		t.Fatalf("after 5 failures: %+v, want a 15m lockout", block)
+	}
+	var lockouts int64
+	db.Model(&models.AuditEvent{}).Where("action = ? AND entity_id = ?", models.AuditAuthLockout, "alice").Count(&lockouts)
+	if lockouts != 1 {
+		t.Fatalf("got %d lockout audit events, want 1", lockouts)
+	}
+
+	// The lockout ends on its own, and the count starts over
+	clock.Advance(15 * time.Minute)
+	if block := check(); block != nil {
+		t.Fatalf("after lockout: %+v", block)
+	}
+	fail()
+	if block := check(); block != nil {
+		t.Fatalf("first failure after lockout: %+v", block)
+	}
+
+	// An operator can unlock early; success also clears the count
+	for i := 0; i < 5; i++ {
+		clock.Advance(time.Minute)
+		fail()
+	}
+	if block := check(); block == nil || !block.Locked {
+		t.Fatalf("relocked: %+v", block)
+	}
+	if err := throttle.Unlock(testOperator, "alice"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if block := check(); block != nil {
+		t.Fatalf("after unlock: %+v", block)
+	}
+	if err := throttle.Unlock(testOperator, "alice"); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("second unlock = %v, want ErrNotLocked", err)
+	}
+	fail()
+	if err := throttle.RecordSuccess("alice"); err != nil {
+		t.Fatalf("record success: %v", err)
+	}
+	if err := throttle.Unlock(testOperator, "alice"); !errors.Is(err, ErrNotLocked) {
+		t.Fatalf("unlock after success = %v, want ErrNotLocked", err)
+	}
+}
+
+func TestLoginThrottleByAddress(t *testing.T) {
+	db := newTestDB(t)
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	throttle := NewLoginThrottleService(db, clock, LoginThrottleConfig{
+		User:        ThrottleLimits{FreeAttempts: 100, MaxFailures: 100},
+		IP:          ThrottleLimits{FreeAttempts: 100, MaxFailures: 3},
+		BackoffBase: time.Second,
+		BackoffMax:  time.Minute,
+		Lockout:     time.Hour,
+	})
+
+	// Spraying one password across many users still locks the address
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		if err := throttle.RecordFailure(Actor{UserID: userID}, userID, "10.0.0.9"); err != nil {
+			t.Fatalf("record failure: %v", err)
+		}
+	}
+	if block, _ := throttle.Check("dave", "10.0.0.9"); block == nil || !block.Locked {
+		t.Fatalf("address not locked: %+v", block)
+	}
+	if block, _ := throttle.Check("dave", "10.0.0.10"); block != nil {
+		t.Fatalf("other address blocked: %+v", block)
+	}
+
+	// Stale failures are forgotten
+	clock.Advance(2 * time.Hour)
+	if block, _ := throttle.Check("dave", "10.0.0.9"); block != nil {
+		t.Fatalf("after lockout: %+v", block)
+	}
+}
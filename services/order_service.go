@@ -3,105 +3,131 @@ package services
 import (
 	"errors"
 	"fmt"
-	"math/big"
 
-	"gorm.io/gorm"
 	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderService struct {
-	db               *gorm.DB
-	transferService  *TransferService
+	db              *gorm.DB
+	transferService *TransferService
+	journal         *JournalService
 }
 
-func NewOrderService(db *gorm.DB, transferService *TransferService) *OrderService {
+func NewOrderService(db *gorm.DB, transferService *TransferService, journal *JournalService) *OrderService {
 	return &OrderService{
 		db:              db,
 		transferService: transferService,
+		journal:         journal,
 	}
 }
 
+// CreateOrderRequest buys a product for UserID, which defaults to the acting
+// user, paying from AccountID or, without it, from the user's current account
+// in the marketplace currency.
 type CreateOrderRequest struct {
-	UserID    string `json:"user_id" binding:"required"`
+	UserID    string `json:"user_id"`
+	AccountID uint   `json:"account_id"`
 	ProductID uint   `json:"product_id" binding:"required"`
 	Quantity  int    `json:"quantity" binding:"required,min=1"`
 }
 
+// RefundOrderRequest refunds either Quantity items, which are restocked and
+// refunded at the price paid, or an arbitrary Amount without restocking. An
+// empty request refunds everything not yet refunded.
+type RefundOrderRequest struct {
+	Quantity int    `json:"quantity" binding:"omitempty,min=1"`
+	Amount   string `json:"amount"`
+}
+
+type RefundOrderResponse struct {
+	OrderID          uint         `json:"order_id"`
+	RefundTransferID uint         `json:"refund_transfer_id"`
+	Amount           money.Amount `json:"amount"`
+	Quantity         int          `json:"quantity"`
+	Status           string       `json:"status"`
+}
+
+var ErrOrderNotFound = errors.New("order not found")
+
 type CreateOrderResponse struct {
 	OrderID uint   `json:"order_id"`
 	Status  string `json:"status"`
 	Message string `json:"message"`
 }
 
-func (s *OrderService) CreateOrder(req CreateOrderRequest) (*CreateOrderResponse, error) {
+func (s *OrderService) CreateOrder(actor Actor, req CreateOrderRequest) (*CreateOrderResponse, error) {
+	if req.UserID == "" {
+		req.UserID = actor.UserID
+	}
+	if err := actor.Authorize(s.db, req.UserID, "place an order"); err != nil {
+		return &CreateOrderResponse{Status: "failed", Message: err.Error()}, err
+	}
+
 	var result *CreateOrderResponse
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		// Products are always locked before accounts
 		var product models.Product
-		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&product, req.ProductID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, req.ProductID).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				return errors.New("product not found")
+				return reject(errors.New("product not found"))
 			}
 			return fmt.Errorf("failed to lock product: %w", err)
 		}
 
 		if product.Stock < req.Quantity {
-			return errors.New("out of stock")
+			return reject(errors.New("out of stock"))
 		}
 
-		productPrice, err := parseDecimal(product.Price)
+		totalAmount, err := product.Price.MulInt(int64(req.Quantity))
 		if err != nil {
-			return fmt.Errorf("invalid product price: %w", err)
+			return reject(fmt.Errorf("invalid order amount: %w", err))
 		}
 
-		quantity := new(big.Float).SetInt64(int64(req.Quantity))
-		totalAmount := new(big.Float).Mul(productPrice, quantity)
-
-		var userAccount models.Account
-		if err := tx.Where("user_id = ?", req.UserID).First(&userAccount).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return errors.New("user account not found")
-			}
-			return fmt.Errorf("failed to find user account: %w", err)
+		marketplace, err := marketplaceAccount(tx)
+		if err != nil {
+			return err
 		}
 
-		var systemAccount models.Account
-		if err := tx.Where("user_id = ?", "0").First(&systemAccount).Error; err != nil {
-			return fmt.Errorf("failed to find system account: %w", err)
+		buyer, err := s.payerAccount(tx, req, marketplace.Currency)
+		if err != nil {
+			return err
 		}
 
-		// Perform money transfer within the same transaction
-		fromBalance, err := parseDecimal(userAccount.Balance)
+		userAccount, systemAccount, err := lockAccountPair(tx, buyer.ID, marketplace.ID)
 		if err != nil {
-			return fmt.Errorf("invalid sender balance: %w", err)
+			return err
 		}
-
-		if fromBalance.Cmp(totalAmount) < 0 {
-			return errors.New("insufficient funds")
+		if err := checkMovement(userAccount, systemAccount); err != nil {
+			return err
 		}
 
-		newFromBalance := new(big.Float).Sub(fromBalance, totalAmount)
-		newToBalance, err := parseDecimal(systemAccount.Balance)
+		totalAmount, err = totalAmount.In(userAccount.Currency)
 		if err != nil {
-			return fmt.Errorf("invalid recipient balance: %w", err)
+			return reject(fmt.Errorf("invalid order amount: %w", err))
+		}
+		if err := money.Validate(totalAmount, userAccount.Currency); err != nil {
+			return reject(fmt.Errorf("invalid order amount: %w", err))
 		}
-		newToBalance = new(big.Float).Add(newToBalance, totalAmount)
 
-		// Update user balance
-		if err := tx.Model(&userAccount).Update("balance", newFromBalance.String()).Error; err != nil {
-			return fmt.Errorf("failed to update sender balance: %w", err)
+		if userAccount.Currency != systemAccount.Currency {
+			return reject(errors.New("currency mismatch between accounts"))
 		}
 
-		// Update system account balance
-		if err := tx.Model(&systemAccount).Update("balance", newToBalance.String()).Error; err != nil {
-			return fmt.Errorf("failed to update recipient balance: %w", err)
+		// Perform money transfer within the same transaction
+		if userAccount.AvailableBalance().Cmp(totalAmount) < 0 {
+			return reject(errors.New("insufficient funds"))
 		}
 
 		// Create transfer record
 		transfer := models.Transfer{
 			FromAccountID: userAccount.ID,
 			ToAccountID:   systemAccount.ID,
-			Amount:        totalAmount.String(),
+			Amount:        totalAmount,
+			Currency:      userAccount.Currency,
 			Status:        models.TransferStatusCompleted,
 		}
 
@@ -109,24 +135,42 @@ func (s *OrderService) CreateOrder(req CreateOrderRequest) (*CreateOrderResponse
 			return fmt.Errorf("failed to create transfer record: %w", err)
 		}
 
-		// Update product stock
-		newStock := product.Stock - req.Quantity
-		if err := tx.Model(&product).Update("stock", newStock).Error; err != nil {
-			return fmt.Errorf("failed to update product stock: %w", err)
+		description := fmt.Sprintf("Payment for %d x product %d", req.Quantity, product.ID)
+		if _, err := s.journal.Move(tx, models.EntryTypeOrderPayment, &transfer.ID, userAccount.ID, systemAccount.ID, totalAmount, description); err != nil {
+			return err
+		}
+
+		// Update product stock, guarded for databases without row locks
+		stockUpdate := tx.Model(&models.Product{}).
+			Where("id = ? AND stock >= ?", product.ID, req.Quantity).
+			Update("stock", gorm.Expr("stock - ?", req.Quantity))
+		if stockUpdate.Error != nil {
+			return fmt.Errorf("failed to update product stock: %w", stockUpdate.Error)
+		}
+		if stockUpdate.RowsAffected == 0 {
+			return errors.New("out of stock")
 		}
 
 		// Create order
 		order := models.Order{
 			UserID:    req.UserID,
+			AccountID: userAccount.ID,
 			ProductID: req.ProductID,
-			Amount:    totalAmount.String(),
+			Amount:    totalAmount,
+			Currency:  userAccount.Currency,
 			Quantity:  req.Quantity,
 			Status:    models.OrderStatusPaid,
+
+			TransferID:     &transfer.ID,
+			RefundedAmount: money.FromMinor(0, userAccount.Currency),
 		}
 
 		if err := tx.Create(&order).Error; err != nil {
 			return fmt.Errorf("failed to create order: %w", err)
 		}
+		if err := recordAudit(tx, actor, AuditRecord{Action: models.AuditOrderCreate, EntityType: "order", EntityID: order.ID, After: auditOrder(&order)}); err != nil {
+			return err
+		}
 
 		result = &CreateOrderResponse{
 			OrderID: order.ID,
@@ -162,3 +206,195 @@ func (s *OrderService) GetOrderByID(orderID uint) (*models.Order, error) {
 	}
 	return &order, nil
 }
+
+// RefundOrder pays back part or all of a paid order from the marketplace
+// account and links the refund transfer to the original payment. Only the
+// merchant who listed the product may refund it, or an operator overriding
+// ownership.
+func (s *OrderService) RefundOrder(actor Actor, orderID uint, req RefundOrderRequest) (*RefundOrderResponse, error) {
+	if req.Quantity > 0 && req.Amount != "" {
+		return nil, reject(errors.New("refund either a quantity or an amount, not both"))
+	}
+
+	var result *RefundOrderResponse
+
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrOrderNotFound
+			}
+			return fmt.Errorf("failed to lock order: %w", err)
+		}
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Unscoped().First(&product, order.ProductID).Error; err != nil {
+			return fmt.Errorf("failed to lock product: %w", err)
+		}
+		buyerID, marketplaceID, err := s.orderAccounts(tx, &order)
+		if err != nil {
+			return err
+		}
+		// Accounts are locked before an override is audited, see recordAudit
+		marketplace, buyer, err := lockAccountPair(tx, marketplaceID, buyerID)
+		if err != nil {
+			return err
+		}
+		if err := actor.Authorize(tx, product.MerchantID, "refund orders"); err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusPaid && order.Status != models.OrderStatusPartiallyRefunded {
+			return reject(fmt.Errorf("order with status %s cannot be refunded", order.Status))
+		}
+
+		remainingAmount := order.Amount.Sub(order.RefundedAmount)
+		remainingQuantity := order.Quantity - order.RefundedQuantity
+
+		quantity := req.Quantity
+		var amount money.Amount
+		switch {
+		case req.Amount != "":
+			var err error
+			amount, err = money.ParsePositive(req.Amount, order.Currency)
+			if err != nil {
+				return reject(fmt.Errorf("invalid refund amount: %w", err))
+			}
+		case quantity == 0 || quantity == remainingQuantity:
+			// Refund whatever is left so prorating never leaves a remainder
+			quantity = remainingQuantity
+			amount = remainingAmount
+		default:
+			var err error
+			amount, err = order.Amount.MulFrac(int64(quantity), int64(order.Quantity), money.RoundHalfEven)
+			if err != nil {
+				return reject(fmt.Errorf("invalid refund amount: %w", err))
+			}
+		}
+
+		if quantity > remainingQuantity {
+			return reject(fmt.Errorf("cannot refund %d items, only %d left to refund", quantity, remainingQuantity))
+		}
+		if !amount.IsPositive() || amount.Cmp(remainingAmount) > 0 {
+			return reject(fmt.Errorf("refund of %s exceeds the %s left to refund", amount, remainingAmount))
+		}
+
+		if quantity > 0 {
+			if err := tx.Model(&product).Update("stock", gorm.Expr("stock + ?", quantity)).Error; err != nil {
+				return fmt.Errorf("failed to restock product: %w", err)
+			}
+		}
+
+		refund := models.Transfer{
+			FromAccountID: marketplace.ID,
+			ToAccountID:   buyer.ID,
+			Amount:        amount,
+			Currency:      order.Currency,
+			Status:        models.TransferStatusCompleted,
+			ReversalOfID:  order.TransferID,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return fmt.Errorf("failed to create refund transfer: %w", err)
+		}
+
+		description := fmt.Sprintf("Refund for order %d", order.ID)
+		if _, err := s.journal.Move(tx, models.EntryTypeRefund, &refund.ID, marketplace.ID, buyer.ID, amount, description); err != nil {
+			return err
+		}
+
+		before := order
+		order.RefundedAmount = order.RefundedAmount.Add(amount)
+		order.RefundedQuantity += quantity
+		order.Status = models.OrderStatusPartiallyRefunded
+		if order.RefundedAmount.Cmp(order.Amount) == 0 {
+			order.Status = models.OrderStatusRefunded
+		}
+		err = tx.Model(&order).Updates(map[string]interface{}{
+			"refunded_amount":   order.RefundedAmount,
+			"refunded_quantity": order.RefundedQuantity,
+			"status":            order.Status,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		err = recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditOrderRefund,
+			EntityType: "order",
+			EntityID:   order.ID,
+			Before:     auditOrder(&before),
+			After:      map[string]interface{}{"order": auditOrder(&order), "refund": auditTransfer(&refund)},
+		})
+		if err != nil {
+			return err
+		}
+
+		result = &RefundOrderResponse{
+			OrderID:          order.ID,
+			RefundTransferID: refund.ID,
+			Amount:           amount,
+			Quantity:         quantity,
+			Status:           string(order.Status),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// orderAccounts returns the buyer and marketplace account IDs of an order.
+// The buyer is taken from the payment transfer when there is one; refunds
+// always come from the current marketplace account, since the one that was
+// paid may have been rotated out since.
+func (s *OrderService) orderAccounts(tx *gorm.DB, order *models.Order) (uint, uint, error) {
+	buyerID := order.AccountID
+	if order.TransferID != nil {
+		var payment models.Transfer
+		if err := tx.First(&payment, *order.TransferID).Error; err != nil {
+			return 0, 0, fmt.Errorf("failed to find payment transfer: %w", err)
+		}
+		buyerID = payment.FromAccountID
+	}
+
+	marketplace, err := marketplaceAccount(tx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return buyerID, marketplace.ID, nil
+}
+
+// payerAccount finds the account an order is paid from, which must belong to
+// the buyer.
+func (s *OrderService) payerAccount(tx *gorm.DB, req CreateOrderRequest, currency string) (*models.Account, error) {
+	if req.AccountID == 0 {
+		account, err := primaryAccount(tx, req.UserID, currency)
+		if errors.Is(err, ErrAccountNotFound) {
+			return nil, reject(errors.New("user account not found"))
+		}
+		return account, err
+	}
+
+	var account models.Account
+	if err := tx.First(&account, req.AccountID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, reject(errors.New("user account not found"))
+		}
+		return nil, fmt.Errorf("failed to find user account: %w", err)
+	}
+	if account.UserID != req.UserID {
+		return nil, fmt.Errorf("%w: account %d does not belong to %s", ErrForbidden, account.ID, req.UserID)
+	}
+	return &account, nil
+}
+
+// BackfillAccounts records the paying account on orders placed before orders
+// kept it, taking it from the payment transfer or the buyer's first account.
+func (s *OrderService) BackfillAccounts() error {
+	err := s.db.Exec(`UPDATE orders SET account_id = COALESCE(
+			(SELECT t.from_account_id FROM transfers t WHERE t.id = orders.transfer_id),
+			(SELECT MIN(a.id) FROM accounts a WHERE a.user_id = orders.user_id))
+		WHERE account_id IS NULL OR account_id = 0`).Error
+	if err != nil {
+		return fmt.Errorf("failed to backfill order accounts: %w", err)
+	}
+	return nil
+}
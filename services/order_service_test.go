@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+)
+
+func TestRefundOrder(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	orders := NewOrderService(db, transfers, journal)
+
+	createFundedAccount(t, db, models.SystemUserID, "UZS", "0")
+	buyer := createFundedAccount(t, db, "buyer", "UZS", "1000.00")
+	product := models.Product{MerchantID: "shop", Name: "Lamp", Price: money.MustParse("100.00"), Stock: 5}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := orders.CreateOrder(Actor{UserID: "buyer"}, CreateOrderRequest{UserID: "buyer", ProductID: product.ID, Quantity: 3})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if order, _ := orders.GetOrderByID(created.OrderID); order.AccountID != buyer.ID {
+		t.Fatalf("order account = %d, want %d", order.AccountID, buyer.ID)
+	}
+	other := createFundedAccount(t, db, "other", "UZS", "1000.00")
+	if _, err := orders.CreateOrder(Actor{UserID: "buyer"}, CreateOrderRequest{AccountID: other.ID, ProductID: product.ID, Quantity: 1}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("paying from another user's account = %v, want ErrForbidden", err)
+	}
+
+	// Only the merchant who listed the product refunds its orders
+	rival := Actor{UserID: "rival", Role: models.RoleMerchant}
+	if _, err := orders.RefundOrder(rival, created.OrderID, RefundOrderRequest{Quantity: 1}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("refund by another merchant = %v, want ErrForbidden", err)
+	}
+	merchant := Actor{UserID: "shop", Role: models.RoleMerchant}
+	refund, err := orders.RefundOrder(merchant, created.OrderID, RefundOrderRequest{Quantity: 1})
+	if err != nil {
+		t.Fatalf("RefundOrder by quantity: %v", err)
+	}
+	if refund.Amount.String() != "100.00" || refund.Status != string(models.OrderStatusPartiallyRefunded) {
+		t.Fatalf("quantity refund = %+v", refund)
+	}
+
+	if _, err := orders.RefundOrder(testOperator, created.OrderID, RefundOrderRequest{Amount: "25.50"}); err != nil {
+		t.Fatalf("RefundOrder by amount: %v", err)
+	}
+	if _, err := orders.RefundOrder(testOperator, created.OrderID, RefundOrderRequest{Amount: "174.51"}); err == nil {
+		t.Fatal("refunding more than was paid succeeded")
+	}
+
+	rest, err := orders.RefundOrder(testOperator, created.OrderID, RefundOrderRequest{})
+	if err != nil {
+		t.Fatalf("RefundOrder remainder: %v", err)
+	}
+	if rest.Amount.String() != "174.50" || rest.Quantity != 2 || rest.Status != string(models.OrderStatusRefunded) {
+		t.Fatalf("remainder refund = %+v", rest)
+	}
+
+	if _, err := orders.RefundOrder(testOperator, created.OrderID, RefundOrderRequest{}); err == nil {
+		t.Fatal("refunding a fully refunded order succeeded")
+	}
+
+	var order models.Order
+	db.First(&order, created.OrderID)
+	var refundTransfer models.Transfer
+	db.First(&refundTransfer, rest.RefundTransferID)
+	if refundTransfer.ReversalOfID == nil || order.TransferID == nil || *refundTransfer.ReversalOfID != *order.TransferID {
+		t.Fatalf("refund transfer is not linked to the payment: %+v", refundTransfer)
+	}
+
+	var stored models.Product
+	db.First(&stored, product.ID)
+	if stored.Stock != 5 {
+		t.Fatalf("stock = %d, want 5", stored.Stock)
+	}
+	if got := balanceOf(t, db, buyer.ID); got.String() != "1000.00" {
+		t.Fatalf("buyer balance = %s, want 1000.00", got)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestRotateSystemAccount(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	orders := NewOrderService(db, NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{}), journal)
+	accounts := NewAccountService(db)
+	admin := Actor{UserID: "root", Role: models.RoleAdmin}
+
+	old := createFundedAccount(t, db, models.SystemUserID, "UZS", "0")
+	buyer := createFundedAccount(t, db, "buyer", "UZS", "1000.00")
+	product := models.Product{Name: "Lamp", Price: money.MustParse("100.00"), Stock: 5}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	created, err := orders.CreateOrder(Actor{UserID: "buyer"}, CreateOrderRequest{UserID: "buyer", ProductID: product.ID, Quantity: 2})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	if _, err := accounts.RotateSystemAccount(testOperator, "key ceremony"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("rotation by operator = %v, want ErrForbidden", err)
+	}
+	rotation, err := accounts.RotateSystemAccount(admin, "key ceremony")
+	if err != nil {
+		t.Fatalf("RotateSystemAccount: %v", err)
+	}
+	if rotation.Previous.ID != old.ID || rotation.Previous.Status != models.AccountStatusClosed || !rotation.Previous.Balance.IsZero() {
+		t.Fatalf("previous = %+v", rotation.Previous)
+	}
+	if rotation.Current.UserID != models.SystemUserID || rotation.Current.Balance.String() != "200.00" {
+		t.Fatalf("current = %+v", rotation.Current)
+	}
+
+	// Refunds and new orders use the new account
+	if _, err := orders.RefundOrder(testOperator, created.OrderID, RefundOrderRequest{Quantity: 1}); err != nil {
+		t.Fatalf("refund after rotation: %v", err)
+	}
+	if _, err := orders.CreateOrder(Actor{UserID: "buyer"}, CreateOrderRequest{UserID: "buyer", ProductID: product.ID, Quantity: 1}); err != nil {
+		t.Fatalf("order after rotation: %v", err)
+	}
+	if got := balanceOf(t, db, rotation.Current.ID); got.String() != "200.00" {
+		t.Fatalf("system balance = %s, want 200.00", got)
+	}
+	if got := balanceOf(t, db, buyer.ID); got.String() != "800.00" {
+		t.Fatalf("buyer balance = %s, want 800.00", got)
+	}
+	assertLedgerBalanced(t, db)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// bcryptMaxLength is the longest password bcrypt hashes in full.
+const bcryptMaxLength = 72
+
+// PasswordPolicy sets the rules new passwords must follow.
+type PasswordPolicy struct {
+	MinLength        int
+	RequireMixedCase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+}
+
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// Validate checks password against the policy. userID is passed so the
+// password cannot simply repeat it.
+func (p PasswordPolicy) Validate(userID, password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("%w: use at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	if len(password) > bcryptMaxLength {
+		return fmt.Errorf("%w: use at most %d bytes", ErrWeakPassword, bcryptMaxLength)
+	}
+	if userID != "" && strings.EqualFold(password, userID) {
+		return fmt.Errorf("%w: must differ from the user ID", ErrWeakPassword)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	switch {
+	case p.RequireMixedCase && !(upper && lower):
+		return fmt.Errorf("%w: use both upper and lower case letters", ErrWeakPassword)
+	case p.RequireDigit && !digit:
+		return fmt.Errorf("%w: include a digit", ErrWeakPassword)
+	case p.RequireSymbol && !symbol:
+		return fmt.Errorf("%w: include a symbol", ErrWeakPassword)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/notify"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// passwordResetResendInterval stops reset requests from flooding a user
+// with messages.
+const passwordResetResendInterval = time.Minute
+
+type PasswordService struct {
+	db       *gorm.DB
+	clock    Clock
+	notifier notify.Notifier
+	policy   PasswordPolicy
+	resetTTL time.Duration
+}
+
+func NewPasswordService(db *gorm.DB, clock Clock, notifier notify.Notifier, policy PasswordPolicy, resetTTL time.Duration) *PasswordService {
+	return &PasswordService{db: db, clock: clock, notifier: notifier, policy: policy, resetTTL: resetTTL}
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type PasswordResetRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+var (
+	ErrWrongPassword     = errors.New("current password is incorrect")
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	ErrPasswordUnchanged = errors.New("new password must differ from the current one")
+)
+
+// Hash validates and hashes a new password.
+func (s *PasswordService) Hash(userID, password string) (string, error) {
+	if err := s.policy.Validate(userID, password); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// Change sets a new password for the actor after checking the current one,
+// and ends the actor's other sessions.
+func (s *PasswordService) Change(actor Actor, req ChangePasswordRequest) error {
+	if actor.SessionID == "" {
+		return fmt.Errorf("%w: passwords can only be changed from a logged-in session", ErrForbidden)
+	}
+	current, err := s.passwordHash(s.db, actor.UserID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(current), []byte(req.CurrentPassword)) != nil {
+		return ErrWrongPassword
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return ErrPasswordUnchanged
+	}
+	hash, err := s.Hash(actor.UserID, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := setPasswordHash(tx, actor.UserID, hash); err != nil {
+			return err
+		}
+		revoked := tx.Where("user_id = ? AND id <> ?", actor.UserID, actor.SessionID).Delete(&models.Session{})
+		if revoked.Error != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", revoked.Error)
+		}
+		return recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditAuthPasswordChange,
+			EntityType: "user",
+			EntityID:   actor.UserID,
+			After:      map[string]int64{"revoked_sessions": revoked.RowsAffected},
+		})
+	})
+}
+
+// RequestReset sends a reset token to the user. Unknown users are ignored
+// without an error so the endpoint does not reveal which users exist.
+func (s *PasswordService) RequestReset(actor Actor, userID string) error {
+	if _, err := s.passwordHash(s.db, userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	now := s.clock.Now()
+	reset := models.PasswordResetToken{
+		UserID:      userID,
+		TokenHash:   hashResetToken(token),
+		RequestedIP: actor.IP,
+		ExpiresAt:   now.Add(s.resetTTL),
+	}
+
+	sent := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var recent int64
+		err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL AND created_at > ?", userID, now.Add(-passwordResetResendInterval)).
+			Count(&recent).Error
+		if err != nil {
+			return fmt.Errorf("failed to check reset tokens: %w", err)
+		}
+		if recent > 0 {
+			return nil
+		}
+
+		// Only the newest token works
+		err = tx.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", userID).Update("expires_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to expire reset tokens: %w", err)
+		}
+		reset.CreatedAt = now
+		if err := tx.Create(&reset).Error; err != nil {
+			return fmt.Errorf("failed to store reset token: %w", err)
+		}
+		if err := recordAudit(tx, actor, AuditRecord{Action: models.AuditAuthPasswordResetRequest, EntityType: "user", EntityID: userID, After: reset}); err != nil {
+			return err
+		}
+		sent = true
+		return nil
+	})
+	if err != nil || !sent {
+		return err
+	}
+
+	err = s.notifier.Notify(notify.Message{
+		UserID:  userID,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Use this token to set a new password before %s:\n%s\nIf you did not ask for a reset, ignore this message.",
+			reset.ExpiresAt.UTC().Format(time.RFC1123), token),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset to %s: %v", userID, err)
+		return fmt.Errorf("failed to send reset token: %w", err)
+	}
+	return nil
+}
+
+// Reset sets a new password with a reset token, ends all of the user's
+// sessions and lifts any login lockout.
+func (s *PasswordService) Reset(actor Actor, req ResetPasswordRequest) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordResetToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL", hashResetToken(req.Token)).First(&reset).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load reset token: %w", err)
+		}
+		now := s.clock.Now()
+		if !now.Before(reset.ExpiresAt) {
+			return ErrInvalidResetToken
+		}
+
+		hash, err := s.Hash(reset.UserID, req.NewPassword)
+		if err != nil {
+			return err
+		}
+		if err := setPasswordHash(tx, reset.UserID, hash); err != nil {
+			return err
+		}
+		if err := tx.Model(&reset).Update("used_at", now).Error; err != nil {
+			return fmt.Errorf("failed to use reset token: %w", err)
+		}
+		if err := tx.Where("user_id = ?", reset.UserID).Delete(&models.Session{}).Error; err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		if err := tx.Where("key = ?", userThrottleKey(reset.UserID)).Delete(&models.LoginThrottle{}).Error; err != nil {
+			return fmt.Errorf("failed to reset login throttle: %w", err)
+		}
+
+		actor.UserID = reset.UserID
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAuthPasswordReset, EntityType: "user", EntityID: reset.UserID})
+	})
+}
+
+func (s *PasswordService) passwordHash(tx *gorm.DB, userID string) (string, error) {
+	var user models.User
+	if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("failed to load user: %w", err)
+	}
+	return user.PasswordHash, nil
+}
+
+func setPasswordHash(tx *gorm.DB, userID, hash string) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("password_hash", hash).Error; err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
+
+// hashResetToken needs no salt: tokens are 256 random bits.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/notify"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Notify(msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 8, RequireMixedCase: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"Sh0rt!", false},
+		{"alllowercase1!", false},
+		{"NoDigitsHere!", false},
+		{"NoSymbols123", false},
+		{"Alice123!", true},
+		{"Пароль-2024", true},
+		{strings.Repeat("Aa1!", 19), false},
+	}
+	for _, tt := range tests {
+		err := policy.Validate("bob", tt.password)
+		if (err == nil) != tt.ok {
+			t.Errorf("Validate(%q) = %v, want ok=%v", tt.password, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("Validate(%q) = %v, want ErrWeakPassword", tt.password, err)
+		}
+	}
+	if err := (PasswordPolicy{MinLength: 4}).Validate("Alice99", "alice99"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("password equal to user ID accepted: %v", err)
+	}
+}
+
+func TestPasswordChangeAndReset(t *testing.T) {
+	db := newTestDB(t)
+	clock := &fakeClock{now: time.Now()}
+	notifier := &recordingNotifier{}
+	passwords := NewPasswordService(db, clock, notifier, PasswordPolicy{MinLength: 8, RequireDigit: true}, 30*time.Minute)
+	sessions := NewSessionService(db, clock, SessionConfig{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour})
+
+	createFundedAccount(t, db, "alice", "UZS", "0.00")
+	hash, _ := passwords.Hash("alice", "original1")
+	db.Model(&models.User{}).Where("id = ?", "alice").Update("password_hash", hash)
+
+	current, _ := sessions.Create(db, "alice", "laptop", "", false)
+	other, _ := sessions.Create(db, "alice", "phone", "", false)
+	alice := Actor{UserID: "alice", Role: models.RoleCustomer, SessionID: current.ID}
+
+	if err := passwords.Change(alice, ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "changed12"}); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("change with wrong password = %v, want ErrWrongPassword", err)
+	}
+	if err := passwords.Change(alice, ChangePasswordRequest{CurrentPassword: "original1", NewPassword: "weak"}); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("change to weak password = %v, want ErrWeakPassword", err)
+	}
+	if err := passwords.Change(alice, ChangePasswordRequest{CurrentPassword: "original1", NewPassword: "changed12"}); err != nil {
+		t.Fatalf("change: %v", err)
+	}
+	assertPassword(t, db, "alice", "changed12")
+	if _, err := sessions.Authenticate(other.ID, ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("other session after change = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := sessions.Authenticate(current.ID, ""); err != nil {
+		t.Fatalf("current session after change: %v", err)
+	}
+
+	// Unknown users get no token and no error
+	if err := passwords.RequestReset(Actor{}, "nobody"); err != nil || len(notifier.messages) != 0 {
+		t.Fatalf("reset for unknown user = %v, %d messages", err, len(notifier.messages))
+	}
+
+	if err := passwords.RequestReset(Actor{IP: "10.0.0.1"}, "alice"); err != nil {
+		t.Fatalf("request reset: %v", err)
+	}
+	if err := passwords.RequestReset(Actor{IP: "10.0.0.1"}, "alice"); err != nil || len(notifier.messages) != 1 {
+		t.Fatalf("repeated request = %v, %d messages, want 1", err, len(notifier.messages))
+	}
+	first := resetToken(t, notifier.messages[0])
+
+	// A newer token replaces the first one
+	clock.Advance(2 * time.Minute)
+	passwords.RequestReset(Actor{}, "alice")
+	second := resetToken(t, notifier.messages[1])
+	if err := passwords.Reset(Actor{}, ResetPasswordRequest{Token: first, NewPassword: "reset1234"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("superseded token = %v, want ErrInvalidResetToken", err)
+	}
+	if err := passwords.Reset(Actor{}, ResetPasswordRequest{Token: second, NewPassword: "short"}); !errors.Is(err, ErrWeakPassword) {
+		t.Fatalf("reset to weak password = %v, want ErrWeakPassword", err)
+	}
+	if err := passwords.Reset(Actor{}, ResetPasswordRequest{Token: second, NewPassword: "reset1234"}); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	assertPassword(t, db, "alice", "reset1234")
+	if _, err := sessions.Authenticate(current.ID, ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("session after reset = %v, want ErrSessionNotFound", err)
+	}
+	if err := passwords.Reset(Actor{}, ResetPasswordRequest{Token: second, NewPassword: "again1234"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("reused token = %v, want ErrInvalidResetToken", err)
+	}
+
+	// Tokens expire
+	clock.Advance(2 * time.Minute)
+	passwords.RequestReset(Actor{}, "alice")
+	clock.Advance(31 * time.Minute)
+	if err := passwords.Reset(Actor{}, ResetPasswordRequest{Token: resetToken(t, notifier.messages[2]), NewPassword: "late12345"}); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("expired token = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+// resetToken pulls the token from the second line of a reset message.
+func resetToken(t *testing.T, msg notify.Message) string {
+	t.Helper()
+	lines := strings.Split(msg.Body, "\n")
+	if len(lines) < 2 || msg.UserID != "alice" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	return lines[1]
+}
+
+func assertPassword(t *testing.T, db *gorm.DB, userID, password string) {
+	t.Helper()
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		t.Fatalf("load user: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		t.Fatalf("password for %s is not %q", userID, password)
+	}
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoSigningKey           = errors.New("reconciliation signing key is not configured")
+	ErrReconciliationNotFound = errors.New("reconciliation report not found")
+)
+
+// Problems reported for a transfer.
+const (
+	TransferProblemNoEntry         = "no journal entry"
+	TransferProblemDebitMismatch   = "sender postings do not match the transfer amount"
+	TransferProblemCreditMismatch  = "recipient postings do not match the credited amount"
+	TransferProblemUnsettledPosted = "transfer is not settled but has journal postings"
+)
+
+// Outcomes of checking a stored report's signature.
+const (
+	SignatureValid = "valid"
+	// SignatureInvalid means the report was changed after it was signed.
+	SignatureInvalid = "invalid"
+	// SignatureUnknownKey means the report was signed with another key, so
+	// it cannot be checked.
+	SignatureUnknownKey = "unknown_key"
+)
+
+// AccountDiscrepancy is an account whose cached balance differs from the
+// balance recomputed from its transfers. TransferIDs are the offending
+// transfers that touch the account.
+type AccountDiscrepancy struct {
+	AccountID   uint         `json:"account_id"`
+	UserID      string       `json:"user_id"`
+	Currency    string       `json:"currency"`
+	Cached      money.Amount `json:"cached_balance"`
+	Recomputed  money.Amount `json:"recomputed_balance"`
+	Difference  money.Amount `json:"difference"`
+	TransferIDs []uint       `json:"transfer_ids,omitempty"`
+}
+
+// TransferDiscrepancy is a transfer row that the journal does not bear out.
+type TransferDiscrepancy struct {
+	TransferID    uint                  `json:"transfer_id"`
+	FromAccountID uint                  `json:"from_account_id"`
+	ToAccountID   uint                  `json:"to_account_id"`
+	Amount        money.Amount          `json:"amount"`
+	Currency      string                `json:"currency"`
+	Status        models.TransferStatus `json:"status"`
+	Problem       string                `json:"problem"`
+}
+
+// ReconciliationResult is what a run found. It is what gets signed.
+type ReconciliationResult struct {
+	GeneratedAt      time.Time             `json:"generated_at"`
+	Balanced         bool                  `json:"balanced"`
+	AccountsChecked  int                   `json:"accounts_checked"`
+	TransfersChecked int                   `json:"transfers_checked"`
+	Accounts         []AccountDiscrepancy  `json:"accounts"`
+	Transfers        []TransferDiscrepancy `json:"transfers"`
+}
+
+// ReconciliationService recomputes every account balance from the transfer
+// history and compares it with Account.Balance. Money that enters or moves
+// without a transfer row, such as opening balances, adjustments and the FX
+// position legs of a conversion, is taken from the journal.
+type ReconciliationService struct {
+	db    *gorm.DB
+	clock Clock
+	key   []byte
+}
+
+func NewReconciliationService(db *gorm.DB, clock Clock, signingKey string) *ReconciliationService {
+	return &ReconciliationService{db: db, clock: clock, key: []byte(signingKey)}
+}
+
+// settledTransferStatuses are the transfers that moved money. A reversed
+// transfer still counts, alongside the reversal that compensates it.
+var settledTransferStatuses = []models.TransferStatus{models.TransferStatusCompleted, models.TransferStatusReversed}
+
+// transferRow is the part of a transfer reconciliation needs.
+type transferRow struct {
+	ID                  uint
+	FromAccountID       uint
+	ToAccountID         uint
+	Amount              money.Amount
+	Currency            string
+	Status              models.TransferStatus
+	DestinationAmount   *money.Amount
+	DestinationCurrency string
+}
+
+func (t transferRow) settled() bool {
+	for _, status := range settledTransferStatuses {
+		if t.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// credited is what the recipient received, which differs from Amount for
+// cross-currency transfers.
+func (t transferRow) credited() money.Amount {
+	if t.DestinationAmount != nil {
+		return *t.DestinationAmount
+	}
+	return t.Amount
+}
+
+// Reconcile computes the findings without storing them. Transfers, postings
+// and accounts are read in one read-only REPEATABLE READ transaction, so they
+// come from the same snapshot and a transfer committed between the reads
+// cannot show up as a discrepancy.
+func (s *ReconciliationService) Reconcile() (*ReconciliationResult, error) {
+	var result *ReconciliationResult
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = s.reconcile(tx)
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *ReconciliationService) reconcile(tx *gorm.DB) (*ReconciliationResult, error) {
+	result := &ReconciliationResult{
+		GeneratedAt: s.clock.Now().UTC(),
+		Balanced:    true,
+		Accounts:    []AccountDiscrepancy{},
+		Transfers:   []TransferDiscrepancy{},
+	}
+
+	var transfers []transferRow
+	err := tx.Model(&models.Transfer{}).
+		Select("id, from_account_id, to_account_id, amount, currency, status, destination_amount, destination_currency").
+		Order("id").Scan(&transfers).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfers: %w", err)
+	}
+	byID := make(map[uint]*transferRow, len(transfers))
+	for i := range transfers {
+		byID[transfers[i].ID] = &transfers[i]
+	}
+	result.TransfersChecked = len(transfers)
+
+	recomputed := make(map[uint]money.Amount)
+	// posted[transfer][account] is the net of the journal postings made for
+	// a transfer on one of its two parties
+	posted := make(map[uint]map[uint]money.Amount)
+
+	rows, err := tx.Table("postings AS p").
+		Select("p.account_id, p.direction, p.amount, e.transfer_id").
+		Joins("JOIN journal_entries e ON e.id = p.entry_id").
+		Order("p.id").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var posting models.Posting
+		var transferID *uint
+		if err := rows.Scan(&posting.AccountID, &posting.Direction, &posting.Amount, &transferID); err != nil {
+			return nil, fmt.Errorf("failed to read posting: %w", err)
+		}
+		transfer := (*transferRow)(nil)
+		if transferID != nil {
+			transfer = byID[*transferID]
+		}
+		if transfer == nil || (posting.AccountID != transfer.FromAccountID && posting.AccountID != transfer.ToAccountID) {
+			recomputed[posting.AccountID] = recomputed[posting.AccountID].Add(posting.Signed())
+			continue
+		}
+		if posted[transfer.ID] == nil {
+			posted[transfer.ID] = make(map[uint]money.Amount)
+		}
+		posted[transfer.ID][posting.AccountID] = posted[transfer.ID][posting.AccountID].Add(posting.Signed())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+	rows.Close()
+
+	offending := make(map[uint][]uint)
+	for _, transfer := range transfers {
+		legs, hasPostings := posted[transfer.ID]
+		problem := ""
+		switch {
+		case !transfer.settled():
+			if hasPostings {
+				problem = TransferProblemUnsettledPosted
+			}
+		case !hasPostings:
+			problem = TransferProblemNoEntry
+		case legs[transfer.FromAccountID].Cmp(transfer.Amount.Neg()) != 0:
+			problem = TransferProblemDebitMismatch
+		case legs[transfer.ToAccountID].Cmp(transfer.credited()) != 0:
+			problem = TransferProblemCreditMismatch
+		}
+
+		if transfer.settled() {
+			recomputed[transfer.FromAccountID] = recomputed[transfer.FromAccountID].Sub(transfer.Amount)
+			recomputed[transfer.ToAccountID] = recomputed[transfer.ToAccountID].Add(transfer.credited())
+		}
+		if problem == "" {
+			continue
+		}
+		result.Transfers = append(result.Transfers, TransferDiscrepancy{
+			TransferID:    transfer.ID,
+			FromAccountID: transfer.FromAccountID,
+			ToAccountID:   transfer.ToAccountID,
+			Amount:        transfer.Amount,
+			Currency:      transfer.Currency,
+			Status:        transfer.Status,
+			Problem:       problem,
+		})
+		offending[transfer.FromAccountID] = append(offending[transfer.FromAccountID], transfer.ID)
+		offending[transfer.ToAccountID] = append(offending[transfer.ToAccountID], transfer.ID)
+	}
+
+	var accounts []models.Account
+	if err := tx.Unscoped().Order("id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+	result.AccountsChecked = len(accounts)
+	for _, account := range accounts {
+		expected := recomputed[account.ID].Normalize(account.Currency)
+		if account.Balance.Cmp(expected) == 0 {
+			continue
+		}
+		transferIDs := offending[account.ID]
+		sort.Slice(transferIDs, func(i, j int) bool { return transferIDs[i] < transferIDs[j] })
+		result.Accounts = append(result.Accounts, AccountDiscrepancy{
+			AccountID:   account.ID,
+			UserID:      account.UserID,
+			Currency:    account.Currency,
+			Cached:      account.Balance,
+			Recomputed:  expected,
+			Difference:  account.Balance.Sub(expected).Normalize(account.Currency),
+			TransferIDs: transferIDs,
+		})
+	}
+
+	result.Balanced = len(result.Accounts) == 0 && len(result.Transfers) == 0
+	return result, nil
+}
+
+// Run reconciles, then stores the signed report and audits the run.
+func (s *ReconciliationService) Run(actor Actor, trigger models.ReconciliationTrigger) (*models.ReconciliationReport, error) {
+	if !actor.Can(models.PermissionLedgerReconcile) {
+		return nil, fmt.Errorf("%w: cannot run reconciliation", ErrForbidden)
+	}
+	if len(s.key) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	startedAt := s.clock.Now().UTC()
+	result, err := s.Reconcile()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reconciliation report: %w", err)
+	}
+
+	report := &models.ReconciliationReport{
+		Trigger:          trigger,
+		TriggeredBy:      actor.UserID,
+		StartedAt:        startedAt,
+		FinishedAt:       s.clock.Now().UTC(),
+		Balanced:         result.Balanced,
+		AccountsChecked:  result.AccountsChecked,
+		TransfersChecked: result.TransfersChecked,
+		Discrepancies:    len(result.Accounts) + len(result.Transfers),
+		Report:           string(body),
+		KeyID:            s.keyID(),
+		Signature:        s.sign(body),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(report).Error; err != nil {
+			return fmt.Errorf("failed to store reconciliation report: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditReconciliationRun,
+			EntityType: "reconciliation_report",
+			EntityID:   report.ID,
+			After: map[string]interface{}{
+				"trigger":       report.Trigger,
+				"balanced":      report.Balanced,
+				"discrepancies": report.Discrepancies,
+				"signature":     report.Signature,
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// List returns the most recent reports first, without their findings.
+func (s *ReconciliationService) List(limit int) ([]models.ReconciliationReport, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var reports []models.ReconciliationReport
+	err := s.db.Omit("report").Order("id DESC").Limit(limit).Find(&reports).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reconciliation reports: %w", err)
+	}
+	return reports, nil
+}
+
+// Get returns a report with the outcome of checking its signature.
+func (s *ReconciliationService) Get(id uint) (*models.ReconciliationReport, string, error) {
+	var report models.ReconciliationReport
+	if err := s.db.First(&report, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrReconciliationNotFound
+		}
+		return nil, "", fmt.Errorf("failed to find reconciliation report: %w", err)
+	}
+	return &report, s.Verify(&report), nil
+}
+
+// Verify checks that the report was signed with the configured key and has
+// not been changed since.
+func (s *ReconciliationService) Verify(report *models.ReconciliationReport) string {
+	if len(s.key) == 0 || report.KeyID != s.keyID() {
+		return SignatureUnknownKey
+	}
+	if !hmac.Equal([]byte(s.sign([]byte(report.Report))), []byte(report.Signature)) {
+		return SignatureInvalid
+	}
+	return SignatureValid
+}
+
+// RunSchedule reconciles every interval until ctx is cancelled.
+func (s *ReconciliationService) RunSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := s.Run(SystemActor, models.ReconciliationTriggerSchedule)
+			if err != nil {
+				log.Printf("Reconciliation failed: %v", err)
+				continue
+			}
+			if !report.Balanced {
+				log.Printf("Reconciliation report %d found %d discrepancies", report.ID, report.Discrepancies)
+			}
+		}
+	}
+}
+
+func (s *ReconciliationService) sign(body []byte) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// keyID names the signing key without revealing it, so that reports signed
+// before a key change are recognised as such rather than as tampered.
+func (s *ReconciliationService) keyID() string {
+	sum := sha256.Sum256(s.key)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+)
+
+func TestReconcile(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	fx := NewFXService(db, 50, time.Minute)
+	transfers := NewTransferService(db, journal, fx, StepUpPolicy{})
+	orders := NewOrderService(db, transfers, journal)
+	holds := NewHoldService(db, journal, time.Hour, StepUpPolicy{})
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	reconciliation := NewReconciliationService(db, clock, "secret")
+
+	createFundedAccount(t, db, models.SystemUserID, "UZS", "0")
+	alice := createFundedAccount(t, db, "alice", "UZS", "1000.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+	dollars := createFundedAccount(t, db, "carol", "USD", "100.00")
+
+	// Every way money moves: transfers, reversals, orders and refunds,
+	// captured holds, conversions and manual adjustments
+	sent, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "100.00"})
+	if err != nil {
+		t.Fatalf("transfer: %v", err)
+	}
+	if _, err := transfers.ReverseTransfer(testOperator, sent.TransferID); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+	product := models.Product{Name: "Lamp", Price: money.MustParse("50.00"), Stock: 5}
+	db.Create(&product)
+	order, err := orders.CreateOrder(Actor{UserID: "alice"}, CreateOrderRequest{UserID: "alice", ProductID: product.ID, Quantity: 2})
+	if err != nil {
+		t.Fatalf("order: %v", err)
+	}
+	if _, err := orders.RefundOrder(testOperator, order.OrderID, RefundOrderRequest{Quantity: 1}); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	hold, err := holds.Authorize(testOperator, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "60.00"})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if _, err := holds.Capture(Actor{UserID: "bob"}, hold.ID, CaptureRequest{Amount: "45.50"}); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if _, err := holds.Authorize(testOperator, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); err != nil {
+		t.Fatalf("pending hold: %v", err)
+	}
+	csv := "base_currency,quote_currency,rate,effective_date\nUSD,UZS,12650.50,2020-01-01\n"
+	if _, err := fx.ImportRatesCSV(testOperator, strings.NewReader(csv), "test"); err != nil {
+		t.Fatalf("rates: %v", err)
+	}
+	quote, err := fx.CreateQuote(Actor{UserID: "carol"}, QuoteRequest{FromCurrency: "USD", ToCurrency: "UZS", Amount: "10.00"})
+	if err != nil {
+		t.Fatalf("quote: %v", err)
+	}
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: dollars.ID, ToAccountID: bob.ID, Amount: "10.00", QuoteID: quote.ID}); err != nil {
+		t.Fatalf("fx transfer: %v", err)
+	}
+	if _, err := journal.Adjust(SystemActor, AdjustmentRequest{AccountID: bob.ID, Amount: "-5.00", Reason: "fee"}); err != nil {
+		t.Fatalf("adjust: %v", err)
+	}
+
+	result, err := reconciliation.Reconcile()
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if !result.Balanced || len(result.Accounts) != 0 || len(result.Transfers) != 0 {
+		t.Fatalf("clean ledger = %+v", result)
+	}
+
+	// A transfer row edited after the fact, and one that never hit the
+	// journal, are reported along with the accounts they throw off
+	db.Model(&models.Transfer{}).Where("id = ?", hold.TransferID).Update("amount", "40.00")
+	ghost := models.Transfer{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: money.MustParse("7.00"), Currency: "UZS", Status: models.TransferStatusCompleted}
+	db.Create(&ghost)
+	db.Model(&models.Account{}).Where("id = ?", dollars.ID).Update("balance", "1000.00")
+
+	result, err = reconciliation.Reconcile()
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if result.Balanced || len(result.Transfers) != 2 {
+		t.Fatalf("transfers = %+v", result.Transfers)
+	}
+	if result.Transfers[0].TransferID != hold.TransferID || result.Transfers[0].Problem != TransferProblemDebitMismatch {
+		t.Fatalf("edited transfer = %+v", result.Transfers[0])
+	}
+	if result.Transfers[1].TransferID != ghost.ID || result.Transfers[1].Problem != TransferProblemNoEntry {
+		t.Fatalf("ghost transfer = %+v", result.Transfers[1])
+	}
+	discrepancies := map[uint]AccountDiscrepancy{}
+	for _, account := range result.Accounts {
+		discrepancies[account.AccountID] = account
+	}
+	if len(discrepancies) != 3 {
+		t.Fatalf("accounts = %+v", result.Accounts)
+	}
+	if got := discrepancies[alice.ID]; got.Difference.String() != "1.50" || len(got.TransferIDs) != 2 {
+		t.Fatalf("alice = %+v", got)
+	}
+	if got := discrepancies[dollars.ID]; got.Difference.String() != "910.00" || len(got.TransferIDs) != 0 {
+		t.Fatalf("carol = %+v", got)
+	}
+}
+
+// Transfers committing while a reconciliation reads must not be reported as
+// discrepancies, which they would be if the reads saw different states.
+func TestReconcileReadsOneSnapshot(t *testing.T) {
+	db := newTestDB(t)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	reconciliation := NewReconciliationService(db, SystemClock, "secret")
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "1000.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "1000.00")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			from, to := alice.ID, bob.ID
+			if i%2 == 1 {
+				from, to = to, from
+			}
+			if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: from, ToAccountID: to, Amount: "1.00"}); err != nil {
+				t.Errorf("transfer %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		result, err := reconciliation.Reconcile()
+		if err != nil || !result.Balanced {
+			<-done
+			if err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+			t.Fatalf("reconciliation during transfers found %+v and %+v", result.Accounts, result.Transfers)
+		}
+	}
+}
+
+func TestReconciliationReports(t *testing.T) {
+	db := newTestDB(t)
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	createFundedAccount(t, db, "alice", "UZS", "10.00")
+
+	if _, err := NewReconciliationService(db, clock, "").Run(SystemActor, models.ReconciliationTriggerManual); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("run without a key = %v, want ErrNoSigningKey", err)
+	}
+
+	reconciliation := NewReconciliationService(db, clock, "secret")
+	report, err := reconciliation.Run(testOperator, models.ReconciliationTriggerManual)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !report.Balanced || report.TriggeredBy != testOperator.UserID || report.AccountsChecked != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	stored, signature, err := reconciliation.Get(report.ID)
+	if err != nil || signature != SignatureValid || stored.Report != report.Report {
+		t.Fatalf("Get = %+v, %s, %v", stored, signature, err)
+	}
+	if _, signature, _ := NewReconciliationService(db, clock, "rotated").Get(report.ID); signature != SignatureUnknownKey {
+		t.Fatalf("signature under another key = %s", signature)
+	}
+	db.Model(&models.ReconciliationReport{}).Where("id = ?", report.ID).
+		Update("report", strings.Replace(report.Report, `"balanced":true`, `"balanced":false`, 1))
+	if _, signature, _ := reconciliation.Get(report.ID); signature != SignatureInvalid {
+		t.Fatalf("signature of an edited report = %s", signature)
+	}
+	if _, _, err := reconciliation.Get(report.ID + 1); !errors.Is(err, ErrReconciliationNotFound) {
+		t.Fatalf("Get unknown = %v", err)
+	}
+
+	list, err := reconciliation.List(10)
+	if err != nil || len(list) != 1 || list[0].Report != "" {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+
+	var events int64
+	db.Model(&models.AuditEvent{}).Where("action = ?", models.AuditReconciliationRun).Count(&events)
+	if events != 1 {
+		t.Fatalf("audit events = %d, want 1", events)
+	}
+}
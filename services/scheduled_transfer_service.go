@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrScheduledTransferNotFound   = errors.New("scheduled transfer not found")
+	ErrScheduledTransferNotPending = errors.New("scheduled transfer is no longer pending")
+)
+
+const (
+	DefaultScheduledTransferLimit = 50
+	MaxScheduledTransferLimit     = 200
+
+	// scheduledTransferBatch caps how many due transfers one worker pass runs.
+	scheduledTransferBatch = 100
+)
+
+// ScheduleTransferRequest submits a same-currency transfer to run later.
+// ExecuteAt is RFC 3339, or YYYY-MM-DD for midnight UTC of that day.
+type ScheduleTransferRequest struct {
+	FromAccountID uint   `json:"from_account_id" binding:"required"`
+	ToAccountID   uint   `json:"to_account_id" binding:"required"`
+	Amount        string `json:"amount" binding:"required"`
+	ExecuteAt     string `json:"execute_at" binding:"required"`
+}
+
+// ScheduledTransferFilter selects one user's scheduled transfers; UserID
+// defaults to the caller.
+type ScheduledTransferFilter struct {
+	UserID string
+	Status models.ScheduledTransferStatus
+	Limit  int
+}
+
+type ScheduledTransferService struct {
+	db        *gorm.DB
+	transfers *TransferService
+	clock     Clock
+	maxAhead  time.Duration
+}
+
+func NewScheduledTransferService(db *gorm.DB, transfers *TransferService, clock Clock, maxAhead time.Duration) *ScheduledTransferService {
+	return &ScheduledTransferService{db: db, transfers: transfers, clock: clock, maxAhead: maxAhead}
+}
+
+// Schedule checks what can be checked now (ownership, account states, the
+// amount and step-up) and stores the transfer for the worker. Everything is
+// checked again by TransferService when the transfer runs.
+func (s *ScheduledTransferService) Schedule(actor Actor, req ScheduleTransferRequest) (*models.ScheduledTransfer, error) {
+	if req.FromAccountID == req.ToAccountID {
+		return nil, errors.New("cannot transfer to the same account")
+	}
+
+	now := s.clock.Now()
+	executeAt, err := parseExecuteAt(req.ExecuteAt)
+	if err != nil {
+		return nil, err
+	}
+	if !executeAt.After(now) {
+		return nil, errors.New("execute_at must be in the future")
+	}
+	if executeAt.After(now.Add(s.maxAhead)) {
+		return nil, fmt.Errorf("execute_at must be within %s", s.maxAhead)
+	}
+
+	var scheduled *models.ScheduledTransfer
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var fromAccount, toAccount models.Account
+		for id, account := range map[uint]*models.Account{req.FromAccountID: &fromAccount, req.ToAccountID: &toAccount} {
+			if err := tx.First(account, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %d", ErrAccountNotFound, id)
+				}
+				return fmt.Errorf("failed to find account: %w", err)
+			}
+		}
+		if err := actor.Authorize(tx, fromAccount.UserID, "schedule transfers"); err != nil {
+			return err
+		}
+		if err := checkMovement(&fromAccount, &toAccount); err != nil {
+			return err
+		}
+		if fromAccount.Currency != toAccount.Currency {
+			return errors.New("currency mismatch between accounts: scheduled transfers must be in one currency")
+		}
+
+		amount, err := money.ParsePositive(req.Amount, fromAccount.Currency)
+		if err != nil {
+			return fmt.Errorf("invalid transfer amount: %w", err)
+		}
+		if err := s.transfers.stepUp.check(actor, amount, fromAccount.Currency); err != nil {
+			return err
+		}
+
+		scheduled = &models.ScheduledTransfer{
+			UserID:        fromAccount.UserID,
+			FromAccountID: fromAccount.ID,
+			ToAccountID:   toAccount.ID,
+			Amount:        amount,
+			Currency:      fromAccount.Currency,
+			ExecuteAt:     executeAt.UTC(),
+			Status:        models.ScheduledTransferStatusScheduled,
+		}
+		if err := tx.Create(scheduled).Error; err != nil {
+			return fmt.Errorf("failed to schedule transfer: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditScheduledTransferCreate, EntityType: "scheduled_transfer", EntityID: scheduled.ID, After: scheduled})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+func parseExecuteAt(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return parsed, nil
+	}
+	if parsed, err := time.Parse("2006-01-02", raw); err == nil {
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid execute_at %q, expected RFC 3339 or YYYY-MM-DD", raw)
+}
+
+// List returns scheduled transfers in the order they run.
+func (s *ScheduledTransferService) List(actor Actor, filter ScheduledTransferFilter) ([]models.ScheduledTransfer, error) {
+	if filter.UserID == "" {
+		filter.UserID = actor.UserID
+	}
+	if err := actor.Authorize(s.db, filter.UserID, "view scheduled transfers"); err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultScheduledTransferLimit
+	}
+	if filter.Limit > MaxScheduledTransferLimit {
+		filter.Limit = MaxScheduledTransferLimit
+	}
+
+	query := s.db.Where("user_id = ?", filter.UserID)
+	switch filter.Status {
+	case "":
+	case models.ScheduledTransferStatusScheduled, models.ScheduledTransferStatusExecuting, models.ScheduledTransferStatusCompleted,
+		models.ScheduledTransferStatusFailed, models.ScheduledTransferStatusCancelled:
+		query = query.Where("status = ?", filter.Status)
+	default:
+		return nil, fmt.Errorf("invalid status %q", filter.Status)
+	}
+
+	transfers := []models.ScheduledTransfer{}
+	if err := query.Order("execute_at, id").Limit(filter.Limit).Find(&transfers).Error; err != nil {
+		return nil, fmt.Errorf("failed to list scheduled transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+func (s *ScheduledTransferService) Get(actor Actor, id uint) (*models.ScheduledTransfer, error) {
+	var scheduled models.ScheduledTransfer
+	if err := s.db.First(&scheduled, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduledTransferNotFound
+		}
+		return nil, fmt.Errorf("failed to find scheduled transfer: %w", err)
+	}
+	if err := actor.Authorize(s.db, scheduled.UserID, "view scheduled transfers"); err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+// Cancel stops a transfer that has not been picked up by the worker yet.
+func (s *ScheduledTransferService) Cancel(actor Actor, id uint) (*models.ScheduledTransfer, error) {
+	var scheduled models.ScheduledTransfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&scheduled, id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrScheduledTransferNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find scheduled transfer: %w", err)
+		}
+		if err := actor.Authorize(tx, scheduled.UserID, "cancel scheduled transfers"); err != nil {
+			return err
+		}
+		if scheduled.Status != models.ScheduledTransferStatusScheduled {
+			return ErrScheduledTransferNotPending
+		}
+
+		before := scheduled
+		now := s.clock.Now()
+		scheduled.Status = models.ScheduledTransferStatusCancelled
+		scheduled.CancelledAt = &now
+		// The status condition loses to a worker that claimed it meanwhile
+		result := tx.Model(&scheduled).Where("status = ?", models.ScheduledTransferStatusScheduled).
+			Updates(map[string]interface{}{"status": scheduled.Status, "cancelled_at": now})
+		if result.Error != nil {
+			return fmt.Errorf("failed to cancel scheduled transfer: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrScheduledTransferNotPending
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditScheduledTransferCancel, EntityType: "scheduled_transfer", EntityID: scheduled.ID, Before: before, After: scheduled})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &scheduled, nil
+}
+
+// ExecuteDue runs the transfers due at now, oldest first, and returns how
+// many it ran, whether they succeeded or failed.
+func (s *ScheduledTransferService) ExecuteDue(now time.Time) (int, error) {
+	var due []models.ScheduledTransfer
+	err := s.db.Where("status = ? AND execute_at <= ?", models.ScheduledTransferStatusScheduled, now.UTC()).
+		Order("execute_at, id").Limit(scheduledTransferBatch).Find(&due).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find due scheduled transfers: %w", err)
+	}
+
+	executed := 0
+	for i := range due {
+		ran, err := s.execute(&due[i])
+		if err != nil {
+			return executed, err
+		}
+		if ran {
+			executed++
+		}
+	}
+	return executed, nil
+}
+
+// execute claims one scheduled transfer, runs it as its owner and records
+// the outcome in a single transaction, so a crash leaves it scheduled rather
+// than half done. It returns false when the transfer was cancelled or
+// claimed by another worker first. A transfer the ledger refuses is marked
+// failed; a storage failure leaves it scheduled for the next pass.
+func (s *ScheduledTransferService) execute(scheduled *models.ScheduledTransfer) (bool, error) {
+	ran := false
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		ran = false
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ?", models.ScheduledTransferStatusScheduled).First(scheduled, scheduled.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim scheduled transfer %d: %w", scheduled.ID, err)
+		}
+
+		actor, runErr := s.ownerActor(tx, scheduled)
+		var response *TransferResponse
+		if runErr == nil {
+			// A savepoint, so a refused transfer leaves nothing behind but
+			// its outcome
+			runErr = tx.Transaction(func(tx *gorm.DB) error {
+				var err error
+				response, err = s.transfers.transferMoney(tx, actor, TransferRequest{
+					FromAccountID: scheduled.FromAccountID,
+					ToAccountID:   scheduled.ToAccountID,
+					Amount:        scheduled.Amount.String(),
+				})
+				return err
+			})
+		}
+		if runErr != nil && !refusesScheduledTransfer(runErr) {
+			return runErr
+		}
+
+		executedAt := s.clock.Now()
+		scheduled.ExecutedAt = &executedAt
+		if runErr == nil {
+			scheduled.Status = models.ScheduledTransferStatusCompleted
+			scheduled.TransferID = &response.TransferID
+		} else {
+			scheduled.Status = models.ScheduledTransferStatusFailed
+			scheduled.FailureReason = runErr.Error()
+			if len(scheduled.FailureReason) > 255 {
+				scheduled.FailureReason = scheduled.FailureReason[:255]
+			}
+		}
+
+		err = tx.Model(scheduled).Updates(map[string]interface{}{
+			"status":         scheduled.Status,
+			"transfer_id":    scheduled.TransferID,
+			"failure_reason": scheduled.FailureReason,
+			"executed_at":    executedAt,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to record outcome of scheduled transfer %d: %w", scheduled.ID, err)
+		}
+		ran = true
+		if runErr == nil {
+			return nil
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditScheduledTransferFail, EntityType: "scheduled_transfer", EntityID: scheduled.ID, After: scheduled})
+	})
+	if err != nil {
+		return false, err
+	}
+	return ran, nil
+}
+
+// refusesScheduledTransfer reports whether running a scheduled transfer
+// failed for good rather than because of storage or a lost race.
+func refusesScheduledTransfer(err error) bool {
+	return errors.Is(err, ErrRejected) || errors.Is(err, ErrForbidden) ||
+		errors.Is(err, ErrStepUpRequired) || errors.Is(err, ErrUserNotFound)
+}
+
+// ownerActor is who a scheduled transfer runs as: its owner with the role
+// they hold now. Step-up was checked when the transfer was scheduled.
+func (s *ScheduledTransferService) ownerActor(tx *gorm.DB, scheduled *models.ScheduledTransfer) (Actor, error) {
+	actor := Actor{UserID: scheduled.UserID, RequestID: fmt.Sprintf("scheduled-transfer:%d", scheduled.ID)}
+	var user models.User
+	if err := tx.First(&user, "id = ?", scheduled.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return actor, ErrUserNotFound
+		}
+		return actor, fmt.Errorf("failed to find user: %w", err)
+	}
+	steppedUp := s.clock.Now()
+	actor.Role = user.Role
+	actor.StepUpAt = &steppedUp
+	return actor, nil
+}
+
+// RunWorker executes due scheduled transfers every interval until ctx is
+// cancelled.
+func (s *ScheduledTransferService) RunWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			executed, err := s.ExecuteDue(s.clock.Now())
+			if err != nil {
+				log.Printf("Scheduled transfers failed: %v", err)
+			}
+			if executed > 0 {
+				log.Printf("Executed %d scheduled transfers", executed)
+			}
+		}
+	}
+}
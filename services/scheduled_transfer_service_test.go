@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+)
+
+func TestScheduledTransfers(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	clock := &fakeClock{now: time.Now()}
+	scheduler := NewScheduledTransferService(db, transfers, clock, 30*24*time.Hour)
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+	dollars := createFundedAccount(t, db, "bob", "USD", "0")
+	aliceActor := Actor{UserID: "alice", Role: models.RoleCustomer}
+
+	at := func(d time.Duration) string { return clock.Now().Add(d).UTC().Format(time.RFC3339) }
+	schedule := func(amount string, in time.Duration) *models.ScheduledTransfer {
+		t.Helper()
+		scheduled, err := scheduler.Schedule(aliceActor, ScheduleTransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: amount, ExecuteAt: at(in)})
+		if err != nil {
+			t.Fatalf("Schedule %s: %v", amount, err)
+		}
+		return scheduled
+	}
+
+	for name, req := range map[string]ScheduleTransferRequest{
+		"in the past":    {FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "1.00", ExecuteAt: at(-time.Minute)},
+		"too far ahead":  {FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "1.00", ExecuteAt: at(60 * 24 * time.Hour)},
+		"cross-currency": {FromAccountID: alice.ID, ToAccountID: dollars.ID, Amount: "1.00", ExecuteAt: at(time.Hour)},
+		"bad amount":     {FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "1.005", ExecuteAt: at(time.Hour)},
+		"bad date":       {FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "1.00", ExecuteAt: "tomorrow"},
+	} {
+		if _, err := scheduler.Schedule(aliceActor, req); err == nil {
+			t.Errorf("scheduling %s: expected an error", name)
+		}
+	}
+	_, err := scheduler.Schedule(Actor{UserID: "bob", Role: models.RoleCustomer}, ScheduleTransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "1.00", ExecuteAt: at(time.Hour)})
+	if !errors.Is(err, ErrForbidden) {
+		t.Fatalf("scheduling from another user's account = %v, want ErrForbidden", err)
+	}
+
+	first := schedule("30.00", time.Hour)
+	second := schedule("90.00", 2*time.Hour)
+	third := schedule("5.00", 3*time.Hour)
+
+	if _, err := scheduler.Cancel(Actor{UserID: "bob", Role: models.RoleCustomer}, third.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("cancel by another user = %v, want ErrForbidden", err)
+	}
+	cancelled, err := scheduler.Cancel(aliceActor, third.ID)
+	if err != nil || cancelled.Status != models.ScheduledTransferStatusCancelled {
+		t.Fatalf("Cancel = %+v, %v", cancelled, err)
+	}
+	if _, err := scheduler.Cancel(aliceActor, third.ID); !errors.Is(err, ErrScheduledTransferNotPending) {
+		t.Fatalf("second cancel = %v, want ErrScheduledTransferNotPending", err)
+	}
+
+	if executed, err := scheduler.ExecuteDue(clock.Now()); err != nil || executed != 0 {
+		t.Fatalf("ExecuteDue before anything is due = %d, %v", executed, err)
+	}
+
+	// Both run in order; the second no longer has the funds
+	clock.Advance(4 * time.Hour)
+	if executed, err := scheduler.ExecuteDue(clock.Now()); err != nil || executed != 2 {
+		t.Fatalf("ExecuteDue = %d, %v", executed, err)
+	}
+	if executed, err := scheduler.ExecuteDue(clock.Now()); err != nil || executed != 0 {
+		t.Fatalf("ExecuteDue again = %d, %v", executed, err)
+	}
+
+	done, err := scheduler.Get(aliceActor, first.ID)
+	if err != nil || done.Status != models.ScheduledTransferStatusCompleted || done.TransferID == nil || done.ExecutedAt == nil {
+		t.Fatalf("first = %+v, %v", done, err)
+	}
+	var transfer models.Transfer
+	db.First(&transfer, *done.TransferID)
+	if transfer.Amount.String() != "30.00" || transfer.Status != models.TransferStatusCompleted {
+		t.Fatalf("transfer = %+v", transfer)
+	}
+	failed, err := scheduler.Get(aliceActor, second.ID)
+	if err != nil || failed.Status != models.ScheduledTransferStatusFailed || !strings.Contains(failed.FailureReason, "insufficient funds") {
+		t.Fatalf("second = %+v, %v", failed, err)
+	}
+	var account models.Account
+	db.First(&account, alice.ID)
+	if account.Balance.String() != "70.00" {
+		t.Fatalf("alice balance = %s, want 70.00", account.Balance)
+	}
+
+	list, err := scheduler.List(aliceActor, ScheduledTransferFilter{})
+	if err != nil || len(list) != 3 || list[0].ID != first.ID {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	list, err = scheduler.List(aliceActor, ScheduledTransferFilter{Status: models.ScheduledTransferStatusFailed})
+	if err != nil || len(list) != 1 || list[0].ID != second.ID {
+		t.Fatalf("List failed = %+v, %v", list, err)
+	}
+	if _, err := scheduler.List(Actor{UserID: "bob", Role: models.RoleCustomer}, ScheduledTransferFilter{UserID: "alice"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("List another user's = %v, want ErrForbidden", err)
+	}
+	if _, err := scheduler.Get(aliceActor, third.ID+100); !errors.Is(err, ErrScheduledTransferNotFound) {
+		t.Fatalf("Get unknown = %v, want ErrScheduledTransferNotFound", err)
+	}
+
+	for action, want := range map[string]int64{
+		models.AuditScheduledTransferCreate: 3,
+		models.AuditScheduledTransferCancel: 1,
+		models.AuditScheduledTransferFail:   1,
+		models.AuditTransferCreate:          1,
+	} {
+		var events int64
+		db.Model(&models.AuditEvent{}).Where("action = ?", action).Count(&events)
+		if events != want {
+			t.Errorf("%s events = %d, want %d", action, events, want)
+		}
+	}
+}
+
+// A run that fails for a reason other than the ledger refusing the transfer
+// leaves it scheduled for the next pass, never stuck half done.
+func TestScheduledTransferSurvivesStorageFailure(t *testing.T) {
+	db := newTestDB(t)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	clock := &fakeClock{now: time.Now()}
+	scheduler := NewScheduledTransferService(db, transfers, clock, 24*time.Hour)
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+	scheduled, err := scheduler.Schedule(Actor{UserID: "alice", Role: models.RoleCustomer}, ScheduleTransferRequest{
+		FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "30.00", ExecuteAt: clock.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("Schedule: %v", err)
+	}
+	clock.Advance(2 * time.Hour)
+
+	if err := db.Exec("ALTER TABLE transfers RENAME TO transfers_offline").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scheduler.ExecuteDue(clock.Now()); err == nil {
+		t.Fatal("ExecuteDue without a transfers table succeeded")
+	}
+	var pending models.ScheduledTransfer
+	db.First(&pending, scheduled.ID)
+	if pending.Status != models.ScheduledTransferStatusScheduled {
+		t.Fatalf("status after a storage failure = %s, want scheduled", pending.Status)
+	}
+
+	if err := db.Exec("ALTER TABLE transfers_offline RENAME TO transfers").Error; err != nil {
+		t.Fatal(err)
+	}
+	if executed, err := scheduler.ExecuteDue(clock.Now()); err != nil || executed != 1 {
+		t.Fatalf("ExecuteDue after recovery = %d, %v", executed, err)
+	}
+	if got := balanceOf(t, db, bob.ID); got.String() != "30.00" {
+		t.Fatalf("bob balance = %s, want 30.00", got)
+	}
+
+	// The owner's step-up is dated by the injected clock like the rest
+	actor, err := scheduler.ownerActor(db, scheduled)
+	if err != nil || actor.StepUpAt == nil || !actor.StepUpAt.Equal(clock.Now()) {
+		t.Fatalf("owner actor = %+v, %v, want a step-up at %v", actor, err, clock.Now())
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"bank-ledger-core/models"
+
+	"gorm.io/gorm"
+)
+
+// SessionConfig bounds session lifetimes. IdleTimeout is how long a session
+// survives without use, MaxLifetime how long it can live at all, and
+// PendingTTL how long a partial two-factor session has to finish login.
+type SessionConfig struct {
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+	PendingTTL  time.Duration
+}
+
+// sessionTouchInterval limits how often sliding expiry writes to a session.
+const sessionTouchInterval = time.Minute
+
+type SessionService struct {
+	db     *gorm.DB
+	clock  Clock
+	config SessionConfig
+}
+
+func NewSessionService(db *gorm.DB, clock Clock, config SessionConfig) *SessionService {
+	return &SessionService{db: db, clock: clock, config: config}
+}
+
+// SessionInfo describes a session to its owner. ID is a handle derived from
+// the session ID, which is a bearer credential and never shown; it matches
+// the session recorded in the audit log.
+type SessionInfo struct {
+	ID                string    `json:"id"`
+	UserAgent         string    `json:"user_agent"`
+	IP                string    `json:"ip"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastSeenAt        time.Time `json:"last_seen_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	Current           bool      `json:"current"`
+}
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionExpired  = errors.New("session expired")
+)
+
+// Create stores a new session inside tx. A session awaiting two-factor
+// verification lives only for PendingTTL.
+func (s *SessionService) Create(tx *gorm.DB, userID, userAgent, ip string, mfaPending bool) (*models.Session, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	now := s.clock.Now()
+	session := &models.Session{
+		ID:                hex.EncodeToString(bytes),
+		UserID:            userID,
+		MFAPending:        mfaPending,
+		UserAgent:         truncate(userAgent, 512),
+		IP:                ip,
+		LastSeenAt:        now,
+		ExpiresAt:         now.Add(s.config.IdleTimeout),
+		AbsoluteExpiresAt: now.Add(s.config.MaxLifetime),
+	}
+	if mfaPending {
+		session.ExpiresAt = now.Add(s.config.PendingTTL)
+		session.AbsoluteExpiresAt = session.ExpiresAt
+	} else if session.ExpiresAt.After(session.AbsoluteExpiresAt) {
+		session.ExpiresAt = session.AbsoluteExpiresAt
+	}
+
+	if err := tx.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return session, nil
+}
+
+// Authenticate loads a session and slides its idle expiry forward. Expired
+// sessions are deleted.
+func (s *SessionService) Authenticate(sessionID, ip string) (*models.Session, error) {
+	var session models.Session
+	if err := s.db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	now := s.clock.Now()
+	if session.IsExpiredAt(now) {
+		s.db.Where("id = ?", sessionID).Delete(&models.Session{})
+		return nil, ErrSessionExpired
+	}
+	if session.MFAPending || now.Sub(session.LastSeenAt) < sessionTouchInterval {
+		return &session, nil
+	}
+
+	session.LastSeenAt = now
+	session.IP = ip
+	session.ExpiresAt = now.Add(s.config.IdleTimeout)
+	if !session.AbsoluteExpiresAt.IsZero() && session.ExpiresAt.After(session.AbsoluteExpiresAt) {
+		session.ExpiresAt = session.AbsoluteExpiresAt
+	}
+	err := s.db.Model(&models.Session{}).Where("id = ?", session.ID).
+		Updates(map[string]interface{}{"last_seen_at": now, "ip": ip, "expires_at": session.ExpiresAt}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to extend session: %w", err)
+	}
+	return &session, nil
+}
+
+// List returns the actor's live sessions, most recently used first.
+func (s *SessionService) List(actor Actor) ([]SessionInfo, error) {
+	sessions, err := s.liveSessions(s.db, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]SessionInfo, len(sessions))
+	for i, session := range sessions {
+		infos[i] = SessionInfo{
+			ID:                SessionHandle(session.ID),
+			UserAgent:         session.UserAgent,
+			IP:                session.IP,
+			CreatedAt:         session.CreatedAt,
+			LastSeenAt:        session.LastSeenAt,
+			ExpiresAt:         session.ExpiresAt,
+			AbsoluteExpiresAt: session.AbsoluteExpiresAt,
+			Current:           session.ID == actor.SessionID,
+		}
+	}
+	return infos, nil
+}
+
+// Revoke ends one of the actor's sessions by its handle.
+func (s *SessionService) Revoke(actor Actor, handle string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		sessions, err := s.liveSessions(tx, actor.UserID)
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if SessionHandle(session.ID) != handle {
+				continue
+			}
+			if err := tx.Where("id = ?", session.ID).Delete(&models.Session{}).Error; err != nil {
+				return fmt.Errorf("failed to revoke session: %w", err)
+			}
+			return recordAudit(tx, actor, AuditRecord{Action: models.AuditAuthSessionRevoke, EntityType: "session", EntityID: handle, Before: session})
+		}
+		return ErrSessionNotFound
+	})
+}
+
+// RevokeAll logs the actor out everywhere, optionally keeping the session
+// making the request, and returns how many sessions ended.
+func (s *SessionService) RevokeAll(actor Actor, keepCurrent bool) (int64, error) {
+	var revoked int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("user_id = ?", actor.UserID)
+		if keepCurrent && actor.SessionID != "" {
+			query = query.Where("id <> ?", actor.SessionID)
+		}
+		result := query.Delete(&models.Session{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", result.Error)
+		}
+		revoked = result.RowsAffected
+		return recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditAuthLogoutAll,
+			EntityType: "user",
+			EntityID:   actor.UserID,
+			After:      map[string]interface{}{"revoked": revoked, "kept_current": keepCurrent},
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+// Sweep deletes sessions that have expired by now. Sliding never moves
+// ExpiresAt past the absolute expiry, so it is the only column to check.
+func (s *SessionService) Sweep(now time.Time) (int64, error) {
+	result := s.db.Where("expires_at <= ?", now).Delete(&models.Session{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to sweep sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// RunSweeper purges expired sessions every interval until ctx is cancelled.
+func (s *SessionService) RunSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			swept, err := s.Sweep(s.clock.Now())
+			if err != nil {
+				log.Printf("Session sweep failed: %v", err)
+			}
+			if swept > 0 {
+				log.Printf("Purged %d expired sessions", swept)
+			}
+		}
+	}
+}
+
+func (s *SessionService) liveSessions(tx *gorm.DB, userID string) ([]models.Session, error) {
+	var sessions []models.Session
+	if err := tx.Where("user_id = ? AND mfa_pending = ?", userID, false).Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	now := s.clock.Now()
+	live := sessions[:0]
+	for _, session := range sessions {
+		if !session.IsExpiredAt(now) {
+			live = append(live, session)
+		}
+	}
+	return live, nil
+}
+
+// SessionHandle is the public name of a session: a prefix of the SHA-256 of
+// its ID, as recorded in the audit log.
+func SessionHandle(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// truncate shortens s to at most max bytes without splitting a character.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
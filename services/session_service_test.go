@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+)
+
+func TestSessionSlidingExpiry(t *testing.T) {
+	db := newTestDB(t)
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	sessions := NewSessionService(db, clock, SessionConfig{IdleTimeout: time.Hour, MaxLifetime: 3 * time.Hour, PendingTTL: 5 * time.Minute})
+
+	session, err := sessions.Create(db, "alice", "curl/8.0", "10.0.0.1", false)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !session.ExpiresAt.Equal(clock.now.Add(time.Hour)) || !session.AbsoluteExpiresAt.Equal(clock.now.Add(3*time.Hour)) {
+		t.Fatalf("session = %+v", session)
+	}
+
+	// Each use pushes idle expiry out, but never past the absolute limit
+	for i := 1; i <= 3; i++ {
+		clock.Advance(50 * time.Minute)
+		if _, err := sessions.Authenticate(session.ID, "10.0.0.2"); err != nil {
+			t.Fatalf("authenticate after %d minutes: %v", 50*i, err)
+		}
+	}
+	var stored models.Session
+	db.First(&stored, "id = ?", session.ID)
+	if !stored.ExpiresAt.Equal(stored.AbsoluteExpiresAt) || stored.IP != "10.0.0.2" {
+		t.Fatalf("stored = %+v, want expiry capped at the absolute limit", stored)
+	}
+	clock.Advance(31 * time.Minute)
+	if _, err := sessions.Authenticate(session.ID, "10.0.0.2"); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("past absolute limit = %v, want ErrSessionExpired", err)
+	}
+	if _, err := sessions.Authenticate(session.ID, "10.0.0.2"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expired session not deleted: %v", err)
+	}
+
+	// Idle sessions expire and are swept
+	idle, _ := sessions.Create(db, "alice", "", "10.0.0.1", false)
+	live, _ := sessions.Create(db, "alice", "", "10.0.0.1", false)
+	clock.Advance(59 * time.Minute)
+	sessions.Authenticate(live.ID, "10.0.0.1")
+	clock.Advance(2 * time.Minute)
+	swept, err := sessions.Sweep(clock.Now())
+	if err != nil || swept != 1 {
+		t.Fatalf("sweep = %d, %v, want 1", swept, err)
+	}
+	if _, err := sessions.Authenticate(idle.ID, ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("idle session = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := sessions.Authenticate(live.ID, ""); err != nil {
+		t.Fatalf("live session: %v", err)
+	}
+}
+
+func TestSessionListAndRevoke(t *testing.T) {
+	db := newTestDB(t)
+	clock := &fakeClock{now: time.Now()}
+	sessions := NewSessionService(db, clock, SessionConfig{IdleTimeout: time.Hour, MaxLifetime: 24 * time.Hour, PendingTTL: 5 * time.Minute})
+
+	laptop, _ := sessions.Create(db, "alice", "laptop", "10.0.0.1", false)
+	phone, _ := sessions.Create(db, "alice", "phone", "10.0.0.2", false)
+	tablet, _ := sessions.Create(db, "alice", "tablet", "10.0.0.3", false)
+	sessions.Create(db, "alice", "pending", "10.0.0.4", true)
+	bobs, _ := sessions.Create(db, "bob", "bob", "10.0.0.5", false)
+	alice := Actor{UserID: "alice", Role: models.RoleCustomer, SessionID: laptop.ID}
+
+	infos, err := sessions.List(alice)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("got %d sessions, want 3 without the pending one", len(infos))
+	}
+	for _, info := range infos {
+		if info.ID == laptop.ID || info.Current != (info.ID == SessionHandle(laptop.ID)) {
+			t.Fatalf("session info = %+v", info)
+		}
+	}
+
+	if err := sessions.Revoke(alice, SessionHandle(bobs.ID)); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoking another user's session = %v, want ErrSessionNotFound", err)
+	}
+	if err := sessions.Revoke(alice, SessionHandle(phone.ID)); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := sessions.Authenticate(phone.ID, ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("revoked session = %v", err)
+	}
+
+	revoked, err := sessions.RevokeAll(alice, true)
+	if err != nil || revoked != 2 {
+		t.Fatalf("revoke others = %d, %v, want tablet and pending", revoked, err)
+	}
+	if _, err := sessions.Authenticate(tablet.ID, ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("tablet session = %v", err)
+	}
+	if _, err := sessions.Authenticate(laptop.ID, ""); err != nil {
+		t.Fatalf("current session: %v", err)
+	}
+	if revoked, _ := sessions.RevokeAll(alice, false); revoked != 1 {
+		t.Fatalf("log out everywhere revoked %d, want 1", revoked)
+	}
+	if _, err := sessions.Authenticate(bobs.ID, ""); err != nil {
+		t.Fatalf("bob's session: %v", err)
+	}
+}
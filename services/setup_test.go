@@ -0,0 +1,104 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bank-ledger-core/migrations"
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testOperator may act on any account, so tests that are not about ownership
+// need not build an actor per call.
+var testOperator = Actor{UserID: "operator", Role: models.RoleOperator, OverrideReason: "test"}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	// Several connections let the concurrency tests contend for real. WAL
+	// keeps readers off the writer, immediate transactions take the write
+	// lock at BEGIN instead of failing on upgrade, and busy_timeout makes a
+	// waiting writer block rather than error out.
+	dsn := filepath.Join(t.TempDir(), "ledger.db") +
+		"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(10000)&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(8)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// Tests run against the same schema scripts as production so that a
+	// model change without a migration shows up here
+	migrator, err := migrations.New(db, "sqlite")
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	return db
+}
+
+// createFundedAccount opens an account and funds it through the journal so
+// that the ledger stays balanced.
+func createFundedAccount(t *testing.T, db *gorm.DB, userID, currency, balance string) *models.Account {
+	t.Helper()
+
+	account := &models.Account{
+		UserID:   userID,
+		Currency: currency,
+		Balance:  money.FromMinor(0, currency),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.FirstOrCreate(&models.User{}, models.User{ID: userID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(account).Error; err != nil {
+			return err
+		}
+		amount := money.MustParse(balance)
+		if amount.IsZero() {
+			return nil
+		}
+		_, err := NewJournalService(db).Fund(tx, models.EntryTypeOpeningBalance, account, amount, "test funding")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to create account %s: %v", userID, err)
+	}
+	return account
+}
+
+func balanceOf(t *testing.T, db *gorm.DB, accountID uint) money.Amount {
+	t.Helper()
+
+	var account models.Account
+	if err := db.First(&account, accountID).Error; err != nil {
+		t.Fatalf("failed to load account %d: %v", accountID, err)
+	}
+	return account.Balance
+}
+
+func assertLedgerBalanced(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	report, err := NewJournalService(db).Check()
+	if err != nil {
+		t.Fatalf("ledger check failed: %v", err)
+	}
+	if !report.Balanced {
+		t.Fatalf("ledger is not balanced: %+v", report)
+	}
+}
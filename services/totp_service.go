@@ -0,0 +1,346 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/totp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const recoveryCodeCount = 10
+
+// MaxMFAAttempts bounds how many wrong codes a session may send, while
+// logging in or afterwards, before it is ended.
+const MaxMFAAttempts = 5
+
+type TOTPService struct {
+	db     *gorm.DB
+	issuer string
+}
+
+func NewTOTPService(db *gorm.DB, issuer string) *TOTPService {
+	return &TOTPService{db: db, issuer: issuer}
+}
+
+// TOTPEnrollmentResponse is what the authenticator app needs; render
+// ProvisioningURI as a QR code or enter Secret by hand.
+type TOTPEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TOTPCodeRequest carries an authenticator code or a recovery code.
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+var (
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTOTPCode    = errors.New("invalid verification code")
+	ErrTOTPAttemptsUsedUp = errors.New("too many invalid verification codes, log in again")
+)
+
+// Enroll starts enrollment with a fresh secret, replacing any unconfirmed
+// one. Two-factor authentication is enforced only after Confirm.
+func (s *TOTPService) Enroll(actor Actor) (*TOTPEnrollmentResponse, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.TOTPEnrollment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", actor.UserID).First(&existing).Error
+		switch {
+		case err == nil && existing.IsConfirmed():
+			return ErrTOTPAlreadyEnabled
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			return fmt.Errorf("failed to load enrollment: %w", err)
+		}
+
+		enrollment := models.TOTPEnrollment{UserID: actor.UserID, Secret: secret}
+		if err := tx.Where("user_id = ?", actor.UserID).Delete(&models.TOTPEnrollment{}).Error; err != nil {
+			return fmt.Errorf("failed to replace enrollment: %w", err)
+		}
+		if err := tx.Create(&enrollment).Error; err != nil {
+			return fmt.Errorf("failed to store enrollment: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditTOTPEnroll, EntityType: "user", EntityID: actor.UserID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.issuer, actor.UserID, secret),
+	}, nil
+}
+
+// Confirm checks the first code from the authenticator, turns two-factor
+// authentication on and returns the recovery codes, which are shown once.
+func (s *TOTPService) Confirm(actor Actor, code string) ([]string, error) {
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var enrollment models.TOTPEnrollment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", actor.UserID).First(&enrollment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTOTPNotEnabled
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load enrollment: %w", err)
+		}
+		if enrollment.IsConfirmed() {
+			return ErrTOTPAlreadyEnabled
+		}
+
+		step, ok := totp.Validate(enrollment.Secret, normalizeCode(code), time.Now())
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+		now := time.Now()
+		err = tx.Model(&enrollment).Updates(map[string]interface{}{"confirmed_at": now, "last_used_step": step}).Error
+		if err != nil {
+			return fmt.Errorf("failed to confirm enrollment: %w", err)
+		}
+
+		codes, err = replaceRecoveryCodes(tx, actor.UserID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditTOTPConfirm, EntityType: "user", EntityID: actor.UserID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after checking a code.
+func (s *TOTPService) Disable(actor Actor, code string) error {
+	return s.withSessionCode(actor, code, func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", actor.UserID).Delete(&models.TOTPEnrollment{}).Error; err != nil {
+			return fmt.Errorf("failed to remove enrollment: %w", err)
+		}
+		if err := tx.Where("user_id = ?", actor.UserID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to remove recovery codes: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditTOTPDisable, EntityType: "user", EntityID: actor.UserID})
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a code.
+func (s *TOTPService) RegenerateRecoveryCodes(actor Actor, code string) ([]string, error) {
+	var codes []string
+	err := s.withSessionCode(actor, code, func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, actor.UserID)
+		if err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditTOTPRecoveryCodes, EntityType: "user", EntityID: actor.UserID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// IsEnabled reports whether the user has confirmed two-factor
+// authentication.
+func (s *TOTPService) IsEnabled(userID string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.TOTPEnrollment{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check two-factor enrollment: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Verify accepts an authenticator code or consumes a recovery code.
+func (s *TOTPService) Verify(userID, code string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return verifyTOTP(tx, userID, code)
+	})
+}
+
+// StepUp verifies a code for an already logged-in session so it may make
+// transfers above the step-up threshold for a while.
+func (s *TOTPService) StepUp(actor Actor, code string) (time.Time, error) {
+	now := time.Now()
+	err := s.withSessionCode(actor, code, func(tx *gorm.DB) error {
+		err := tx.Model(&models.Session{}).Where("id = ? AND user_id = ?", actor.SessionID, actor.UserID).Update("step_up_at", now).Error
+		if err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{Action: models.AuditAuthStepUp, EntityType: "user", EntityID: actor.UserID})
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now, nil
+}
+
+// withSessionCode runs fn in a transaction once code checks out. Wrong codes
+// count against the actor's session, which is ended after MaxMFAAttempts so
+// that a stolen session cannot guess its way past the second factor.
+func (s *TOTPService) withSessionCode(actor Actor, code string, fn func(tx *gorm.DB) error) error {
+	if actor.SessionID == "" {
+		return fmt.Errorf("%w: a verification code requires a logged-in session", ErrForbidden)
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := verifyTOTP(tx, actor.UserID, code); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Session{}).Where("id = ?", actor.SessionID).Update("mfa_attempts", 0).Error; err != nil {
+			return fmt.Errorf("failed to reset code attempts: %w", err)
+		}
+		return fn(tx)
+	})
+	if errors.Is(err, ErrInvalidTOTPCode) {
+		if failErr := s.recordCodeFailure(actor); failErr != nil {
+			return failErr
+		}
+	}
+	return err
+}
+
+// recordCodeFailure counts a wrong code against the actor's session and
+// ends the session once it runs out of attempts.
+func (s *TOTPService) recordCodeFailure(actor Actor) error {
+	ended := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND user_id = ?", actor.SessionID, actor.UserID).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load session: %w", err)
+		}
+
+		attempts := session.MFAAttempts + 1
+		if attempts >= MaxMFAAttempts {
+			ended = true
+			err = tx.Where("id = ?", session.ID).Delete(&models.Session{}).Error
+		} else {
+			err = tx.Model(&session).Update("mfa_attempts", attempts).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to count code attempt: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditAuthMFAFailed,
+			EntityType: "user",
+			EntityID:   actor.UserID,
+			After:      map[string]int{"attempts": attempts},
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if ended {
+		return ErrTOTPAttemptsUsedUp
+	}
+	return nil
+}
+
+func verifyTOTP(tx *gorm.DB, userID, code string) error {
+	var enrollment models.TOTPEnrollment
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !enrollment.IsConfirmed()) {
+		return ErrTOTPNotEnabled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load enrollment: %w", err)
+	}
+
+	code = normalizeCode(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(enrollment.Secret, code, time.Now())
+		if !ok || step <= enrollment.LastUsedStep {
+			return ErrInvalidTOTPCode
+		}
+		if err := tx.Model(&enrollment).Update("last_used_step", step).Error; err != nil {
+			return fmt.Errorf("failed to record code use: %w", err)
+		}
+		return nil
+	}
+
+	used := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if used.Error != nil {
+		return fmt.Errorf("failed to use recovery code: %w", used.Error)
+	}
+	if used.RowsAffected == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to remove recovery codes: %w", err)
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := base32.StdEncoding.EncodeToString(raw)
+		codes[i] = code[:4] + "-" + code[4:]
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// normalizeCode drops the separators users type or paste with codes.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// StepUpPolicy requires a recent two-factor verification on the session for
+// money movements above a per-currency threshold. Currencies without a
+// threshold are not checked.
+type StepUpPolicy struct {
+	Thresholds map[string]money.Amount
+	Window     time.Duration
+}
+
+var ErrStepUpRequired = errors.New("two-factor verification required")
+
+func (p StepUpPolicy) check(actor Actor, amount money.Amount, currency string) error {
+	threshold, ok := p.Thresholds[currency]
+	if !ok || amount.Cmp(threshold) <= 0 {
+		return nil
+	}
+	if actor.StepUpAt != nil && time.Since(*actor.StepUpAt) <= p.Window {
+		return nil
+	}
+	return fmt.Errorf("%w: amounts above %s %s need a code from your authenticator", ErrStepUpRequired, threshold, currency)
+}
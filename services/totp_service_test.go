@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+	"bank-ledger-core/totp"
+)
+
+func TestTOTPEnrollmentAndVerify(t *testing.T) {
+	db := newTestDB(t)
+	service := NewTOTPService(db, "Bank Ledger")
+	session := models.Session{ID: "alice-session", UserID: "alice", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	alice := Actor{UserID: "alice", Role: models.RoleCustomer, SessionID: session.ID}
+
+	enrollment, err := service.Enroll(alice)
+	if err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if enabled, _ := service.IsEnabled("alice"); enabled {
+		t.Fatal("two-factor enabled before confirmation")
+	}
+	if _, err := service.Confirm(alice, "000000"); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("confirm with wrong code = %v, want ErrInvalidTOTPCode", err)
+	}
+
+	now := time.Now()
+	code, _ := totp.Code(enrollment.Secret, now)
+	recoveryCodes, err := service.Confirm(alice, code)
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes", len(recoveryCodes))
+	}
+	if enabled, _ := service.IsEnabled("alice"); !enabled {
+		t.Fatal("two-factor not enabled after confirmation")
+	}
+	if _, err := service.Enroll(alice); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Fatalf("second enroll = %v, want ErrTOTPAlreadyEnabled", err)
+	}
+
+	// A code cannot be replayed, but the next step's code is accepted
+	if err := service.Verify("alice", code); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("replayed code = %v, want ErrInvalidTOTPCode", err)
+	}
+	next, _ := totp.Code(enrollment.Secret, now.Add(totp.Period))
+	if err := service.Verify("alice", next); err != nil {
+		t.Fatalf("next code: %v", err)
+	}
+
+	// Recovery codes work once, with or without the separator
+	recovery := recoveryCodes[0]
+	if err := service.Verify("alice", recovery[:4]+recovery[5:]); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := service.Verify("alice", recovery); !errors.Is(err, ErrInvalidTOTPCode) {
+		t.Fatalf("reused recovery code = %v, want ErrInvalidTOTPCode", err)
+	}
+
+	if err := service.Disable(alice, recoveryCodes[1]); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if err := service.Verify("alice", recoveryCodes[2]); !errors.Is(err, ErrTOTPNotEnabled) {
+		t.Fatalf("verify after disable = %v, want ErrTOTPNotEnabled", err)
+	}
+}
+
+// Wrong codes after login count against the session like they do while
+// logging in, so a stolen session cannot guess codes without limit.
+func TestWrongCodesEndTheSession(t *testing.T) {
+	db := newTestDB(t)
+	service := NewTOTPService(db, "Bank Ledger")
+	session := models.Session{ID: "alice-session", UserID: "alice", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	alice := Actor{UserID: "alice", Role: models.RoleCustomer, SessionID: session.ID}
+
+	enrollment, _ := service.Enroll(alice)
+	now := time.Now()
+	code, _ := totp.Code(enrollment.Secret, now)
+	if _, err := service.Confirm(alice, code); err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+
+	// A correct code resets the count
+	for i := 0; i < MaxMFAAttempts-1; i++ {
+		if _, err := service.StepUp(alice, "000000"); !errors.Is(err, ErrInvalidTOTPCode) {
+			t.Fatalf("wrong step-up code = %v, want ErrInvalidTOTPCode", err)
+		}
+	}
+	next, _ := totp.Code(enrollment.Secret, now.Add(totp.Period))
+	if _, err := service.StepUp(alice, next); err != nil {
+		t.Fatalf("step-up: %v", err)
+	}
+
+	attempts := []func() error{
+		func() error { _, err := service.StepUp(alice, "000000"); return err },
+		func() error { return service.Disable(alice, "000000") },
+		func() error { _, err := service.RegenerateRecoveryCodes(alice, "000000"); return err },
+	}
+	for i := 0; i < MaxMFAAttempts-1; i++ {
+		if err := attempts[i%len(attempts)](); !errors.Is(err, ErrInvalidTOTPCode) {
+			t.Fatalf("attempt %d = %v, want ErrInvalidTOTPCode", i+1, err)
+		}
+	}
+	if err := service.Disable(alice, "000000"); !errors.Is(err, ErrTOTPAttemptsUsedUp) {
+		t.Fatalf("last attempt = %v, want ErrTOTPAttemptsUsedUp", err)
+	}
+	var sessions int64
+	db.Model(&models.Session{}).Where("id = ?", session.ID).Count(&sessions)
+	if sessions != 0 {
+		t.Fatal("session survived running out of attempts")
+	}
+	if enabled, _ := service.IsEnabled("alice"); !enabled {
+		t.Fatal("two-factor disabled by wrong codes")
+	}
+
+	var failures int64
+	db.Model(&models.AuditEvent{}).Where("action = ?", models.AuditAuthMFAFailed).Count(&failures)
+	if failures != 2*MaxMFAAttempts-1 {
+		t.Fatalf("%d failures audited, want %d", failures, 2*MaxMFAAttempts-1)
+	}
+}
+
+func TestStepUpForLargeTransfers(t *testing.T) {
+	db := newTestDB(t)
+	stepUp := StepUpPolicy{
+		Thresholds: map[string]money.Amount{"UZS": money.MustParse("1000.00")},
+		Window:     5 * time.Minute,
+	}
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), stepUp)
+	service := NewTOTPService(db, "Bank Ledger")
+
+	from := createFundedAccount(t, db, "alice", "UZS", "5000.00")
+	to := createFundedAccount(t, db, "bob", "UZS", "0.00")
+	session := models.Session{ID: "alice-session", UserID: "alice", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	alice := Actor{UserID: "alice", Role: models.RoleCustomer, SessionID: session.ID}
+
+	if _, err := transfers.TransferMoney(alice, TransferRequest{FromAccountID: from.ID, ToAccountID: to.ID, Amount: "1000.00"}); err != nil {
+		t.Fatalf("transfer at the threshold: %v", err)
+	}
+	large := TransferRequest{FromAccountID: from.ID, ToAccountID: to.ID, Amount: "1000.01"}
+	if _, err := transfers.TransferMoney(alice, large); !errors.Is(err, ErrStepUpRequired) {
+		t.Fatalf("large transfer without step-up = %v, want ErrStepUpRequired", err)
+	}
+	stale := time.Now().Add(-10 * time.Minute)
+	alice.StepUpAt = &stale
+	if _, err := transfers.TransferMoney(alice, large); !errors.Is(err, ErrStepUpRequired) {
+		t.Fatalf("large transfer with stale step-up = %v, want ErrStepUpRequired", err)
+	}
+
+	enrollment, _ := service.Enroll(alice)
+	code, _ := totp.Code(enrollment.Secret, time.Now())
+	recoveryCodes, err := service.Confirm(alice, code)
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	at, err := service.StepUp(alice, recoveryCodes[0])
+	if err != nil {
+		t.Fatalf("step-up: %v", err)
+	}
+	var stored models.Session
+	db.First(&stored, "id = ?", session.ID)
+	if stored.StepUpAt == nil {
+		t.Fatal("step-up not recorded on the session")
+	}
+
+	alice.StepUpAt = &at
+	if _, err := transfers.TransferMoney(alice, large); err != nil {
+		t.Fatalf("large transfer after step-up: %v", err)
+	}
+	if got := balanceOf(t, db, to.ID); got.Cmp(money.MustParse("2000.01")) != 0 {
+		t.Fatalf("recipient balance = %s, want 2000.01", got)
+	}
+	assertLedgerBalanced(t, db)
+}
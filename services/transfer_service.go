@@ -3,98 +3,144 @@ package services
 import (
 	"errors"
 	"fmt"
-	"math/big"
+	"strings"
 
-	"gorm.io/gorm"
 	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransferService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	journal *JournalService
+	fx      *FXService
+	stepUp  StepUpPolicy
 }
 
-func NewTransferService(db *gorm.DB) *TransferService {
-	return &TransferService{db: db}
+func NewTransferService(db *gorm.DB, journal *JournalService, fx *FXService, stepUp StepUpPolicy) *TransferService {
+	return &TransferService{db: db, journal: journal, fx: fx, stepUp: stepUp}
 }
 
+// TransferRequest moves Amount, in the sender's currency, between two
+// accounts. Accounts in different currencies require the ID of an unexpired
+// FX quote for exactly that amount.
 type TransferRequest struct {
 	FromAccountID uint   `json:"from_account_id" binding:"required"`
 	ToAccountID   uint   `json:"to_account_id" binding:"required"`
 	Amount        string `json:"amount" binding:"required"`
+	QuoteID       string `json:"quote_id"`
 }
 
+// UserTransferRequest sends money from one of the sender's accounts to
+// another user, credited to the recipient's current account in ToCurrency,
+// which defaults to the sender account's currency.
 type UserTransferRequest struct {
-	FromUserID string `json:"from_user_id" binding:"required"`
-	ToUserID   string `json:"to_user_id" binding:"required"`
-	Amount     string `json:"amount" binding:"required"`
+	FromAccountID uint   `json:"from_account_id" binding:"required"`
+	ToUserID      string `json:"to_user_id" binding:"required"`
+	ToCurrency    string `json:"to_currency"`
+	Amount        string `json:"amount" binding:"required"`
+	QuoteID       string `json:"quote_id"`
 }
 
 type TransferResponse struct {
-	TransferID uint   `json:"transfer_id"`
-	Status     string `json:"status"`
-	Message    string `json:"message"`
+	TransferID     uint   `json:"transfer_id"`
+	Status         string `json:"status"`
+	Message        string `json:"message"`
+	StepUpRequired bool   `json:"step_up_required,omitempty"`
 }
 
-func (s *TransferService) TransferMoney(req TransferRequest) (*TransferResponse, error) {
+func (s *TransferService) TransferMoney(actor Actor, req TransferRequest) (*TransferResponse, error) {
 	if req.FromAccountID == req.ToAccountID {
-		return nil, errors.New("cannot transfer to the same account")
+		return nil, reject(errors.New("cannot transfer to the same account"))
 	}
 
 	var result *TransferResponse
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		var fromAccount, toAccount models.Account
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		result, err = s.transferMoney(tx, actor, req)
+		return err
+	})
 
-		if err := tx.First(&fromAccount, req.FromAccountID).Error; err != nil {
-			return fmt.Errorf("sender account not found: %w", err)
-		}
+	if err != nil {
+		return &TransferResponse{
+			Status:  "failed",
+			Message: err.Error(),
+		}, err
+	}
 
-		if err := tx.First(&toAccount, req.ToAccountID).Error; err != nil {
-			return fmt.Errorf("recipient account not found: %w", err)
-		}
+	return result, nil
+}
 
-		if fromAccount.Currency != toAccount.Currency {
-			return errors.New("currency mismatch between accounts")
-		}
+// transferMoney is TransferMoney inside the caller's transaction.
+func (s *TransferService) transferMoney(tx *gorm.DB, actor Actor, req TransferRequest) (*TransferResponse, error) {
+	fromAccount, toAccount, err := lockAccountPair(tx, req.FromAccountID, req.ToAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if err := actor.Authorize(tx, fromAccount.UserID, "transfer money"); err != nil {
+		return nil, err
+	}
 
-		fromBalance, err := parseDecimal(fromAccount.Balance)
-		if err != nil {
-			return fmt.Errorf("invalid sender balance: %w", err)
-		}
+	transfer, err := s.executeTransfer(tx, actor, fromAccount, toAccount, req.Amount, req.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordAudit(tx, actor, AuditRecord{Action: models.AuditTransferCreate, EntityType: "transfer", EntityID: transfer.ID, After: auditTransfer(transfer)}); err != nil {
+		return nil, err
+	}
 
-		amount, err := parseDecimal(req.Amount)
-		if err != nil {
-			return fmt.Errorf("invalid transfer amount: %w", err)
-		}
+	return &TransferResponse{
+		TransferID: transfer.ID,
+		Status:     string(transfer.Status),
+		Message:    "Transfer completed successfully",
+	}, nil
+}
 
-		if fromBalance.Cmp(amount) < 0 {
-			return errors.New("insufficient funds")
+func (s *TransferService) TransferMoneyByUserIDs(actor Actor, req UserTransferRequest) (*TransferResponse, error) {
+	var result *TransferResponse
+
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var fromAccount models.Account
+		if err := tx.First(&fromAccount, req.FromAccountID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return reject(errors.New("sender account not found"))
+			}
+			return fmt.Errorf("failed to find sender account: %w", err)
+		}
+		if err := actor.permits(fromAccount.UserID, "transfer money"); err != nil {
+			return err
+		}
+		if fromAccount.UserID == req.ToUserID {
+			return reject(errors.New("cannot transfer to the same user, address your own accounts by ID"))
 		}
 
-		newFromBalance := new(big.Float).Sub(fromBalance, amount)
-		newToBalance, err := parseDecimal(toAccount.Balance)
+		currency := strings.ToUpper(req.ToCurrency)
+		if currency == "" {
+			currency = fromAccount.Currency
+		}
+		toAccount, err := primaryAccount(tx, req.ToUserID, currency)
 		if err != nil {
-			return fmt.Errorf("invalid recipient balance: %w", err)
+			return err
 		}
-		newToBalance = new(big.Float).Add(newToBalance, amount)
 
-		if err := tx.Model(&fromAccount).Update("balance", newFromBalance.String()).Error; err != nil {
-			return fmt.Errorf("failed to update sender balance: %w", err)
+		// Re-read both accounts under row locks in a deadlock-free order
+		lockedFrom, lockedTo, err := lockAccountPair(tx, fromAccount.ID, toAccount.ID)
+		if err != nil {
+			return err
 		}
-
-		if err := tx.Model(&toAccount).Update("balance", newToBalance.String()).Error; err != nil {
-			return fmt.Errorf("failed to update recipient balance: %w", err)
+		if err := actor.Authorize(tx, lockedFrom.UserID, "transfer money"); err != nil {
+			return err
 		}
 
-		transfer := models.Transfer{
-			FromAccountID: req.FromAccountID,
-			ToAccountID:   req.ToAccountID,
-			Amount:        req.Amount,
-			Status:        models.TransferStatusCompleted,
+		transfer, err := s.executeTransfer(tx, actor, lockedFrom, lockedTo, req.Amount, req.QuoteID)
+		if err != nil {
+			return err
 		}
-
-		if err := tx.Create(&transfer).Error; err != nil {
-			return fmt.Errorf("failed to create transfer record: %w", err)
+		if err := recordAudit(tx, actor, AuditRecord{Action: models.AuditTransferCreate, EntityType: "transfer", EntityID: transfer.ID, After: auditTransfer(transfer)}); err != nil {
+			return err
 		}
 
 		result = &TransferResponse{
@@ -116,97 +162,207 @@ func (s *TransferService) TransferMoney(req TransferRequest) (*TransferResponse,
 	return result, nil
 }
 
-func (s *TransferService) TransferMoneyByUserIDs(req UserTransferRequest) (*TransferResponse, error) {
-	if req.FromUserID == req.ToUserID {
-		return nil, errors.New("cannot transfer to the same user")
+// executeTransfer validates the movement, records the Transfer row and posts
+// the matching journal entry inside tx. Both accounts must already be locked.
+func (s *TransferService) executeTransfer(tx *gorm.DB, actor Actor, fromAccount, toAccount *models.Account, rawAmount, quoteID string) (*models.Transfer, error) {
+	if err := checkMovement(fromAccount, toAccount); err != nil {
+		return nil, err
 	}
 
-	var result *TransferResponse
+	crossCurrency := fromAccount.Currency != toAccount.Currency
+	if crossCurrency && quoteID == "" {
+		return nil, reject(errors.New("currency mismatch between accounts: a quote_id is required"))
+	}
+	if !crossCurrency && quoteID != "" {
+		return nil, reject(errors.New("quote_id is only valid for cross-currency transfers"))
+	}
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// Find from account
-		var fromAccount models.Account
-		if err := tx.Where("user_id = ?", req.FromUserID).First(&fromAccount).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return errors.New("sender account not found")
-			}
-			return fmt.Errorf("failed to find sender account: %w", err)
-		}
+	amount, err := money.ParsePositive(rawAmount, fromAccount.Currency)
+	if err != nil {
+		return nil, reject(fmt.Errorf("invalid transfer amount: %w", err))
+	}
+	if err := s.stepUp.check(actor, amount, fromAccount.Currency); err != nil {
+		return nil, err
+	}
 
-		// Find to account
-		var toAccount models.Account
-		if err := tx.Where("user_id = ?", req.ToUserID).First(&toAccount).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return errors.New("recipient account not found")
-			}
-			return fmt.Errorf("failed to find recipient account: %w", err)
-		}
+	if fromAccount.AvailableBalance().Cmp(amount) < 0 {
+		return nil, reject(errors.New("insufficient funds"))
+	}
 
-		// Check currency match
-		if fromAccount.Currency != toAccount.Currency {
-			return errors.New("currency mismatch between accounts")
-		}
+	transfer := models.Transfer{
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Amount:        amount,
+		Currency:      fromAccount.Currency,
+		Status:        models.TransferStatusCompleted,
+	}
 
-		// Parse amounts
-		fromBalance, err := parseDecimal(fromAccount.Balance)
-		if err != nil {
-			return fmt.Errorf("invalid sender balance: %w", err)
-		}
+	if crossCurrency {
+		return s.executeFXTransfer(tx, &transfer, fromAccount, toAccount, quoteID)
+	}
 
-		amount, err := parseDecimal(req.Amount)
-		if err != nil {
-			return fmt.Errorf("invalid transfer amount: %w", err)
-		}
+	if err := tx.Create(&transfer).Error; err != nil {
+		return nil, fmt.Errorf("failed to create transfer record: %w", err)
+	}
+
+	description := fmt.Sprintf("Transfer from account %d to account %d", fromAccount.ID, toAccount.ID)
+	if _, err := s.journal.Move(tx, models.EntryTypeTransfer, &transfer.ID, fromAccount.ID, toAccount.ID, amount, description); err != nil {
+		return nil, err
+	}
 
-		// Check sufficient funds
-		if fromBalance.Cmp(amount) < 0 {
-			return errors.New("insufficient funds")
+	return &transfer, nil
+}
+
+// executeFXTransfer debits the sender in the source currency and credits the
+// recipient in the destination currency at the quoted rate. The FX position
+// accounts of both currencies absorb the two legs so that each currency
+// balances on its own.
+func (s *TransferService) executeFXTransfer(tx *gorm.DB, transfer *models.Transfer, fromAccount, toAccount *models.Account, quoteID string) (*models.Transfer, error) {
+	quote, err := s.fx.consumeQuote(tx, quoteID, fromAccount, toAccount, transfer.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer.DestinationAmount = &quote.TargetAmount
+	transfer.DestinationCurrency = quote.ToCurrency
+	transfer.FXRate = &quote.Rate
+	transfer.FXSpreadBps = &quote.SpreadBps
+	transfer.FXQuoteID = &quote.ID
+
+	if err := tx.Create(transfer).Error; err != nil {
+		return nil, fmt.Errorf("failed to create transfer record: %w", err)
+	}
+	if err := s.fx.markQuoteUsed(tx, quote, transfer.ID); err != nil {
+		return nil, err
+	}
+
+	sourcePosition, err := s.journal.systemAccount(tx, models.FXUserID, fromAccount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	destinationPosition, err := s.journal.systemAccount(tx, models.FXUserID, toAccount.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &models.JournalEntry{
+		Type:       models.EntryTypeTransfer,
+		TransferID: &transfer.ID,
+		Description: fmt.Sprintf("FX transfer from account %d to account %d at %s (quote %s)",
+			fromAccount.ID, toAccount.ID, quote.Rate, quote.ID),
+	}
+	err = s.journal.Post(tx, entry, []PostingLine{
+		{AccountID: fromAccount.ID, Direction: models.PostingDebit, Amount: transfer.Amount},
+		{AccountID: sourcePosition.ID, Direction: models.PostingCredit, Amount: transfer.Amount},
+		{AccountID: destinationPosition.ID, Direction: models.PostingDebit, Amount: quote.TargetAmount},
+		{AccountID: toAccount.ID, Direction: models.PostingCredit, Amount: quote.TargetAmount},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+var ErrTransferNotFound = errors.New("transfer not found")
+
+// ReverseTransfer undoes a completed transfer by posting the opposite of each
+// of its journal postings, so cross-currency transfers are unwound at the
+// original amounts. The reversal is recorded as a new transfer linked to the
+// original, which is marked reversed.
+func (s *TransferService) ReverseTransfer(actor Actor, transferID uint) (*models.Transfer, error) {
+	var reversal *models.Transfer
+
+	err := inTransaction(s.db, func(tx *gorm.DB) error {
+		var original models.Transfer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&original, transferID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTransferNotFound
+			}
+			return fmt.Errorf("failed to lock transfer: %w", err)
 		}
 
-		// Calculate new balances
-		newFromBalance := new(big.Float).Sub(fromBalance, amount)
-		newToBalance, err := parseDecimal(toAccount.Balance)
-		if err != nil {
-			return fmt.Errorf("invalid recipient balance: %w", err)
+		switch {
+		case original.Status == models.TransferStatusReversed:
+			return reject(errors.New("transfer has already been reversed"))
+		case original.Status != models.TransferStatusCompleted:
+			return reject(fmt.Errorf("transfer with status %s cannot be reversed", original.Status))
+		case original.ReversalOfID != nil:
+			return reject(errors.New("a reversal or refund cannot be reversed"))
 		}
-		newToBalance = new(big.Float).Add(newToBalance, amount)
 
-		// Update balances
-		if err := tx.Model(&fromAccount).Update("balance", newFromBalance.String()).Error; err != nil {
-			return fmt.Errorf("failed to update sender balance: %w", err)
+		var orders int64
+		if err := tx.Model(&models.Order{}).Where("transfer_id = ?", original.ID).Count(&orders).Error; err != nil {
+			return fmt.Errorf("failed to check orders: %w", err)
+		}
+		if orders > 0 {
+			return reject(errors.New("order payments are refunded through the order"))
 		}
 
-		if err := tx.Model(&toAccount).Update("balance", newToBalance.String()).Error; err != nil {
-			return fmt.Errorf("failed to update recipient balance: %w", err)
+		var entries []models.JournalEntry
+		if err := tx.Preload("Postings").Where("transfer_id = ?", original.ID).Find(&entries).Error; err != nil {
+			return fmt.Errorf("failed to load journal entries: %w", err)
+		}
+		var lines []PostingLine
+		for _, entry := range entries {
+			for _, posting := range entry.Postings {
+				direction := models.PostingDebit
+				if posting.Direction == models.PostingDebit {
+					direction = models.PostingCredit
+				}
+				lines = append(lines, PostingLine{AccountID: posting.AccountID, Direction: direction, Amount: posting.Amount})
+			}
+		}
+		if len(lines) == 0 {
+			return reject(errors.New("transfer has no journal postings to reverse"))
 		}
 
-		// Create transfer record
-		transfer := models.Transfer{
-			FromAccountID: fromAccount.ID,
-			ToAccountID:   toAccount.ID,
-			Amount:        amount.String(),
+		reversal = &models.Transfer{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        original.Amount,
+			Currency:      original.Currency,
 			Status:        models.TransferStatusCompleted,
+			ReversalOfID:  &original.ID,
 		}
-
-		if err := tx.Create(&transfer).Error; err != nil {
-			return fmt.Errorf("failed to create transfer record: %w", err)
+		if original.DestinationAmount != nil {
+			// The recipient gives back what they were credited
+			reversal.Amount = *original.DestinationAmount
+			reversal.Currency = original.DestinationCurrency
+			reversal.DestinationAmount = &original.Amount
+			reversal.DestinationCurrency = original.Currency
+		}
+		if err := tx.Create(reversal).Error; err != nil {
+			return fmt.Errorf("failed to create reversal transfer: %w", err)
 		}
 
-		result = &TransferResponse{
-			TransferID: transfer.ID,
-			Status:     string(transfer.Status),
-			Message:    "Transfer completed successfully",
+		entry := &models.JournalEntry{
+			Type:        models.EntryTypeReversal,
+			TransferID:  &reversal.ID,
+			Description: fmt.Sprintf("Reversal of transfer %d", original.ID),
+		}
+		if err := s.journal.Post(tx, entry, lines); err != nil {
+			return err
 		}
 
-		return nil
+		before := original
+		if err := tx.Model(&original).Update("status", models.TransferStatusReversed).Error; err != nil {
+			return fmt.Errorf("failed to mark transfer reversed: %w", err)
+		}
+		return recordAudit(tx, actor, AuditRecord{
+			Action:     models.AuditTransferReverse,
+			EntityType: "transfer",
+			EntityID:   original.ID,
+			Before:     auditTransfer(&before),
+			After:      map[string]interface{}{"transfer": auditTransfer(&original), "reversal": auditTransfer(reversal)},
+		})
 	})
-
 	if err != nil {
-		return &TransferResponse{
-			Status:  "failed",
-			Message: err.Error(),
-		}, err
+		return nil, err
 	}
 
-	return result, nil
+	if err := s.db.Preload("FromAccount").Preload("ToAccount").First(reversal, reversal.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load reversal: %w", err)
+	}
+	return reversal, nil
 }
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bank-ledger-core/models"
+	"bank-ledger-core/money"
+
+	"gorm.io/gorm"
+)
+
+func TestConcurrentTransfersFromOneAccount(t *testing.T) {
+	db := newTestDB(t)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	source := createFundedAccount(t, db, "source", "UZS", "1000.00")
+	recipients := make([]*models.Account, 5)
+	for i := range recipients {
+		recipients[i] = createFundedAccount(t, db, fmt.Sprintf("recipient-%d", i), "UZS", "0")
+	}
+
+	const workers = 50
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := transfers.TransferMoney(testOperator, TransferRequest{
+				FromAccountID: source.ID,
+				ToAccountID:   recipients[i%len(recipients)].ID,
+				Amount:        "30.00",
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else if err.Error() != "insufficient funds" {
+				t.Errorf("unexpected transfer error: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if succeeded != 33 {
+		t.Fatalf("%d transfers succeeded, want 33", succeeded)
+	}
+	if got := balanceOf(t, db, source.ID); got.String() != "10.00" {
+		t.Fatalf("source balance = %s, want 10.00", got)
+	}
+
+	received := money.FromMinor(0, "UZS")
+	for _, recipient := range recipients {
+		received = received.Add(balanceOf(t, db, recipient.ID))
+	}
+	if received.String() != "990.00" {
+		t.Fatalf("recipients received %s, want 990.00", received)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestConcurrentTransfersInBothDirections(t *testing.T) {
+	db := newTestDB(t)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "500.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "500.00")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			from, to := alice.ID, bob.ID
+			if i%2 == 1 {
+				from, to = to, from
+			}
+			if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: from, ToAccountID: to, Amount: "7.25"}); err != nil {
+				t.Errorf("transfer %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := balanceOf(t, db, alice.ID).Add(balanceOf(t, db, bob.ID))
+	if total.String() != "1000.00" {
+		t.Fatalf("alice + bob = %s, want 1000.00", total)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestConcurrentOrdersDoNotOversell(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	orders := NewOrderService(db, NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{}), journal)
+
+	createFundedAccount(t, db, models.SystemUserID, "UZS", "0")
+	buyer := createFundedAccount(t, db, "buyer", "UZS", "10000.00")
+	product := models.Product{Name: "Limited", Price: money.MustParse("100.00"), Stock: 10}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orders.CreateOrder(Actor{UserID: "buyer"}, CreateOrderRequest{UserID: "buyer", ProductID: product.ID, Quantity: 1})
+		}()
+	}
+	wg.Wait()
+
+	var stored models.Product
+	db.First(&stored, product.ID)
+	if stored.Stock != 0 {
+		t.Fatalf("stock = %d, want 0", stored.Stock)
+	}
+	if got := balanceOf(t, db, buyer.ID); got.String() != "9000.00" {
+		t.Fatalf("buyer balance = %s, want 9000.00", got)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestStaleVersionIsRejected(t *testing.T) {
+	db := newTestDB(t)
+	account := createFundedAccount(t, db, "alice", "UZS", "100.00")
+
+	var stale models.Account
+	db.First(&stale, account.ID)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		locked, err := lockAccounts(tx, account.ID)
+		if err != nil {
+			return err
+		}
+		locked[account.ID].Balance = money.MustParse("90.00")
+		return updateBalance(tx, locked[account.ID])
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale.Balance = money.MustParse("80.00")
+	err = db.Transaction(func(tx *gorm.DB) error { return updateBalance(tx, &stale) })
+	if !errors.Is(err, ErrConcurrentUpdate) {
+		t.Fatalf("updateBalance with stale version = %v, want ErrConcurrentUpdate", err)
+	}
+
+	attempts := 0
+	err = inTransaction(db, func(tx *gorm.DB) error {
+		attempts++
+		if attempts < 3 {
+			return ErrConcurrentUpdate
+		}
+		return nil
+	})
+	if err != nil || attempts != 3 {
+		t.Fatalf("inTransaction = %v after %d attempts, want success after 3", err, attempts)
+	}
+}
+
+func TestLockOrderPutsLedgerAccountsLast(t *testing.T) {
+	db := newTestDB(t)
+	issuance := createFundedAccount(t, db, models.IssuanceUserID, "UZS", "0")
+	marketplace := createFundedAccount(t, db, models.SystemUserID, "UZS", "0")
+	alice := createFundedAccount(t, db, "alice", "UZS", "0")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	order, err := lockOrder(db, marketplace.ID, bob.ID, issuance.ID, alice.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint{alice.ID, bob.ID, issuance.ID, marketplace.ID}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Fatalf("lock order = %v, want %v", order, want)
+	}
+}
+
+func TestReverseTransfer(t *testing.T) {
+	db := newTestDB(t)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	resp, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "40.00"})
+	if err != nil {
+		t.Fatalf("TransferMoney: %v", err)
+	}
+
+	reversal, err := transfers.ReverseTransfer(testOperator, resp.TransferID)
+	if err != nil {
+		t.Fatalf("ReverseTransfer: %v", err)
+	}
+	if reversal.ReversalOfID == nil || *reversal.ReversalOfID != resp.TransferID || reversal.FromAccountID != bob.ID {
+		t.Fatalf("reversal = %+v", reversal)
+	}
+
+	var original models.Transfer
+	db.First(&original, resp.TransferID)
+	if original.Status != models.TransferStatusReversed {
+		t.Fatalf("original status = %s, want reversed", original.Status)
+	}
+	if got := balanceOf(t, db, alice.ID); got.String() != "100.00" {
+		t.Fatalf("alice balance = %s, want 100.00", got)
+	}
+	if got := balanceOf(t, db, bob.ID); !got.IsZero() {
+		t.Fatalf("bob balance = %s, want 0", got)
+	}
+
+	if _, err := transfers.ReverseTransfer(testOperator, resp.TransferID); err == nil {
+		t.Fatal("reversing twice succeeded")
+	}
+	if _, err := transfers.ReverseTransfer(testOperator, reversal.ID); err == nil {
+		t.Fatal("reversing a reversal succeeded")
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestReverseTransferNeedsRecipientFunds(t *testing.T) {
+	db := newTestDB(t)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+	carol := createFundedAccount(t, db, "carol", "UZS", "0")
+
+	resp, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "40.00"})
+	if err != nil {
+		t.Fatalf("TransferMoney: %v", err)
+	}
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: bob.ID, ToAccountID: carol.ID, Amount: "30.00"}); err != nil {
+		t.Fatalf("TransferMoney: %v", err)
+	}
+
+	if _, err := transfers.ReverseTransfer(testOperator, resp.TransferID); err == nil || err.Error() != "insufficient funds" {
+		t.Fatalf("ReverseTransfer error = %v, want insufficient funds", err)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestAccountStatusEnforcement(t *testing.T) {
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	transfers := NewTransferService(db, NewJournalService(db), NewFXService(db, 50, time.Minute), StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	if _, err := accounts.ChangeStatus(testOperator, alice.ID, ChangeStatusRequest{Status: models.AccountStatusFrozen, Reason: "fraud review"}); err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); !errors.Is(err, ErrAccountFrozen) {
+		t.Fatalf("transfer from frozen account = %v, want ErrAccountFrozen", err)
+	}
+
+	// Frozen accounts still receive
+	if _, err := accounts.ChangeStatus(testOperator, alice.ID, ChangeStatusRequest{Status: models.AccountStatusActive, Reason: "cleared"}); err != nil {
+		t.Fatalf("unfreeze: %v", err)
+	}
+	if _, err := accounts.ChangeStatus(testOperator, bob.ID, ChangeStatusRequest{Status: models.AccountStatusFrozen, Reason: "kyc"}); err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); err != nil {
+		t.Fatalf("transfer to frozen account: %v", err)
+	}
+
+	if _, err := accounts.ChangeStatus(testOperator, bob.ID, ChangeStatusRequest{Status: models.AccountStatusClosed, Reason: "customer request"}); err == nil {
+		t.Fatal("closing a frozen account succeeded")
+	}
+	if _, err := accounts.ChangeStatus(testOperator, alice.ID, ChangeStatusRequest{Status: models.AccountStatusClosed, Reason: "customer request"}); err == nil {
+		t.Fatal("closing an account with a balance succeeded")
+	}
+
+	carol := createFundedAccount(t, db, "carol", "UZS", "0")
+	closed, err := accounts.ChangeStatus(testOperator, carol.ID, ChangeStatusRequest{Status: models.AccountStatusClosed, Reason: "customer request"})
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if closed.StatusReason != "customer request" || closed.StatusChangedAt == nil {
+		t.Fatalf("closed account = %+v", closed)
+	}
+	if _, err := transfers.TransferMoney(testOperator, TransferRequest{FromAccountID: alice.ID, ToAccountID: carol.ID, Amount: "10.00"}); !errors.Is(err, ErrAccountClosed) {
+		t.Fatalf("transfer to closed account = %v, want ErrAccountClosed", err)
+	}
+	if _, err := accounts.ChangeStatus(testOperator, carol.ID, ChangeStatusRequest{Status: models.AccountStatusActive, Reason: "reopen"}); err == nil {
+		t.Fatal("reopening a closed account succeeded")
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestOwnershipEnforcement(t *testing.T) {
+	db := newTestDB(t)
+	journal := NewJournalService(db)
+	transfers := NewTransferService(db, journal, NewFXService(db, 50, time.Minute), StepUpPolicy{})
+	holds := NewHoldService(db, journal, time.Hour, StepUpPolicy{})
+
+	alice := createFundedAccount(t, db, "alice", "UZS", "100.00")
+	bob := createFundedAccount(t, db, "bob", "UZS", "0")
+
+	mallory := Actor{UserID: "bob", Role: models.RoleCustomer}
+	if _, err := transfers.TransferMoney(mallory, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("debit of another user's account = %v, want ErrForbidden", err)
+	}
+	if _, err := transfers.TransferMoneyByUserIDs(mallory, UserTransferRequest{FromAccountID: alice.ID, ToUserID: "carol", Amount: "10.00"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("debit to a user = %v, want ErrForbidden", err)
+	}
+	if _, err := holds.Authorize(mallory, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "10.00"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("hold on another user's account = %v, want ErrForbidden", err)
+	}
+	if got := balanceOf(t, db, alice.ID); got.String() != "100.00" {
+		t.Fatalf("alice balance = %s, want 100.00", got)
+	}
+
+	owner := Actor{UserID: "alice", Role: models.RoleCustomer}
+	if _, err := transfers.TransferMoneyByUserIDs(owner, UserTransferRequest{FromAccountID: alice.ID, ToUserID: "bob", Amount: "10.00"}); err != nil {
+		t.Fatalf("transfer to a user: %v", err)
+	}
+
+	// Either side of a hold may see it, outsiders may not
+	hold, err := holds.Authorize(owner, AuthorizeRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "20.00"})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	createFundedAccount(t, db, "carol", "UZS", "0")
+	if _, err := holds.GetHold(Actor{UserID: "carol"}, hold.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("outsider viewing a hold = %v, want ErrForbidden", err)
+	}
+	if _, err := holds.Capture(Actor{UserID: "bob"}, hold.ID, CaptureRequest{}); err != nil {
+		t.Fatalf("payee capture: %v", err)
+	}
+
+	// An operator has to ask for the override, and every use is audited
+	operator := Actor{UserID: "operator", Role: models.RoleOperator}
+	if _, err := transfers.TransferMoney(operator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "5.00"}); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("operator transfer without an override = %v, want ErrForbidden", err)
+	}
+	operator.OverrideReason = "customer called support"
+	if _, err := transfers.TransferMoney(operator, TransferRequest{FromAccountID: alice.ID, ToAccountID: bob.ID, Amount: "5.00"}); err != nil {
+		t.Fatalf("operator override: %v", err)
+	}
+	if got := balanceOf(t, db, alice.ID); got.String() != "65.00" {
+		t.Fatalf("alice balance = %s, want 65.00", got)
+	}
+
+	var overrides []models.AuditEvent
+	db.Where("action = ?", models.AuditOwnershipOverride).Find(&overrides)
+	if len(overrides) != 1 || overrides[0].ActorUserID != "operator" || overrides[0].EntityID != "alice" ||
+		!strings.Contains(overrides[0].After, "customer called support") {
+		t.Fatalf("override audit events = %+v, want one for alice with the reason", overrides)
+	}
+	assertLedgerBalanced(t, db)
+}
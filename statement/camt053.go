@@ -0,0 +1,147 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+
+	"bank-ledger-core/money"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"
+
+type camtDocument struct {
+	XMLName   xml.Name `xml:"Document"`
+	Namespace string   `xml:"xmlns,attr"`
+	Statement struct {
+		GroupHeader struct {
+			MessageID string `xml:"MsgId"`
+			CreatedAt string `xml:"CreDtTm"`
+		} `xml:"GrpHdr"`
+		Stmt camtStatement `xml:"Stmt"`
+	} `xml:"BkToCstmrStmt"`
+}
+
+type camtStatement struct {
+	ID        string `xml:"Id"`
+	CreatedAt string `xml:"CreDtTm"`
+	Period    struct {
+		From string `xml:"FrDtTm"`
+		To   string `xml:"ToDtTm"`
+	} `xml:"FrToDt"`
+	Account struct {
+		Other struct {
+			ID string `xml:"Id"`
+		} `xml:"Id>Othr"`
+		Currency string `xml:"Ccy"`
+	} `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>DtTm"`
+}
+
+type camtEntry struct {
+	Reference   string     `xml:"NtryRef"`
+	Amount      camtAmount `xml:"Amt"`
+	Indicator   string     `xml:"CdtDbtInd"`
+	Status      string     `xml:"Sts>Cd"`
+	BookingDate string     `xml:"BookgDt>DtTm"`
+	ValueDate   string     `xml:"ValDt>DtTm"`
+	ServicerRef string     `xml:"AcctSvcrRef"`
+	BankCode    string     `xml:"BkTxCd>Prtry>Cd"`
+	Details     struct {
+		EndToEndID string     `xml:"Refs>EndToEndId"`
+		Debtor     *camtParty `xml:"RltdPties>Dbtr,omitempty"`
+		Creditor   *camtParty `xml:"RltdPties>Cdtr,omitempty"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+type camtParty struct {
+	Name string `xml:"Pty>Nm"`
+}
+
+// WriteCamt053 writes an ISO 20022 camt.053.001.08 statement with opening
+// (OPBD) and closing (CLBD) booked balances.
+func WriteCamt053(w io.Writer, s *Statement) error {
+	doc := camtDocument{Namespace: camt053Namespace}
+	doc.Statement.GroupHeader.MessageID = s.ID()
+	doc.Statement.GroupHeader.CreatedAt = camtTime(s.GeneratedAt)
+
+	stmt := &doc.Statement.Stmt
+	stmt.ID = s.ID()
+	stmt.CreatedAt = camtTime(s.GeneratedAt)
+	stmt.Period.From = camtTime(s.From)
+	stmt.Period.To = camtTime(s.To)
+	stmt.Account.Other.ID = strconv.FormatUint(uint64(s.AccountID), 10)
+	stmt.Account.Currency = s.Currency
+	stmt.Balances = []camtBalance{
+		newCamtBalance("OPBD", s.OpeningBalance, s.Currency, s.From),
+		newCamtBalance("CLBD", s.ClosingBalance, s.Currency, s.To),
+	}
+
+	for _, line := range s.Lines {
+		entry := camtEntry{
+			Reference:   line.ID,
+			Amount:      camtAmount{Currency: line.Currency, Value: line.Amount.String()},
+			Indicator:   camtIndicator(line.Signed()),
+			Status:      "BOOK",
+			BookingDate: camtTime(line.Date),
+			ValueDate:   camtTime(line.Date),
+			ServicerRef: line.ID,
+			BankCode:    line.Operation,
+		}
+		entry.Details.EndToEndID = line.Reference
+		party := &camtParty{Name: line.Counterparty}
+		if line.Direction == Debit {
+			entry.Details.Creditor = party
+		} else {
+			entry.Details.Debtor = party
+		}
+		stmt.Entries = append(stmt.Entries, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// newCamtBalance reports a balance as an absolute amount with a credit or
+// debit indicator, as ISO 20022 does not allow negative amounts.
+func newCamtBalance(code string, balance money.Amount, currency string, at time.Time) camtBalance {
+	return camtBalance{
+		Code:      code,
+		Amount:    camtAmount{Currency: currency, Value: balance.Abs().String()},
+		Indicator: camtIndicator(balance),
+		Date:      camtTime(at),
+	}
+}
+
+func camtIndicator(amount money.Amount) string {
+	if amount.IsNegative() {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+func camtTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
@@ -0,0 +1,56 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+)
+
+var csvHeader = []string{
+	"transaction_id", "date", "type", "operation", "amount", "currency",
+	"counterparty", "reference", "balance_after",
+}
+
+// WriteCSV writes one row per line with signed amounts, framed by
+// opening_balance and closing_balance rows.
+func WriteCSV(w io.Writer, s *Statement) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{
+		csvHeader,
+		{"", s.From.UTC().Format(time.RFC3339), "opening_balance", "", "", s.Currency, "", "", s.OpeningBalance.String()},
+	}
+	for _, line := range s.Lines {
+		balanceAfter := ""
+		if line.BalanceAfter != nil {
+			balanceAfter = line.BalanceAfter.String()
+		}
+		rows = append(rows, []string{
+			line.ID,
+			line.Date.UTC().Format(time.RFC3339),
+			string(line.Direction),
+			line.Operation,
+			line.Signed().String(),
+			line.Currency,
+			csvText(line.Counterparty),
+			csvText(line.Reference),
+			balanceAfter,
+		})
+	}
+	rows = append(rows, []string{"", s.To.UTC().Format(time.RFC3339), "closing_balance", "", "", s.Currency, "", "", s.ClosingBalance.String()})
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// csvText quotes free text that a spreadsheet would read as a formula by
+// prefixing it with an apostrophe, which spreadsheets show as plain text.
+func csvText(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
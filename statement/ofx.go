@@ -0,0 +1,117 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ofxBankID identifies this ledger in BANKACCTFROM.
+const ofxBankID = "BANKLEDGER"
+
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+`
+
+type ofxDocument struct {
+	XMLName xml.Name `xml:"OFX"`
+	SignOn  struct {
+		Response struct {
+			Status   ofxStatus `xml:"STATUS"`
+			DTServer string    `xml:"DTSERVER"`
+			Language string    `xml:"LANGUAGE"`
+		} `xml:"SONRS"`
+	} `xml:"SIGNONMSGSRSV1"`
+	Bank struct {
+		Transaction struct {
+			TrnUID    string       `xml:"TRNUID"`
+			Status    ofxStatus    `xml:"STATUS"`
+			Statement ofxStatement `xml:"STMTRS"`
+		} `xml:"STMTTRNRS"`
+	} `xml:"BANKMSGSRSV1"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxStatement struct {
+	Currency string `xml:"CURDEF"`
+	Account  struct {
+		BankID string `xml:"BANKID"`
+		AcctID string `xml:"ACCTID"`
+		Type   string `xml:"ACCTTYPE"`
+	} `xml:"BANKACCTFROM"`
+	TransactionList struct {
+		Start        string           `xml:"DTSTART"`
+		End          string           `xml:"DTEND"`
+		Transactions []ofxTransaction `xml:"STMTTRN"`
+	} `xml:"BANKTRANLIST"`
+	LedgerBalance ofxBalance `xml:"LEDGERBAL"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	FITID  string `xml:"FITID"`
+	Name   string `xml:"NAME,omitempty"`
+	Memo   string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+// WriteOFX writes an OFX 2.2 bank statement. OFX has no opening balance, so
+// only the closing balance is reported as LEDGERBAL.
+func WriteOFX(w io.Writer, s *Statement) error {
+	var doc ofxDocument
+	doc.SignOn.Response.Status = ofxStatus{Code: 0, Severity: "INFO"}
+	doc.SignOn.Response.DTServer = ofxTime(s.GeneratedAt)
+	doc.SignOn.Response.Language = "ENG"
+
+	doc.Bank.Transaction.TrnUID = s.ID()
+	doc.Bank.Transaction.Status = ofxStatus{Code: 0, Severity: "INFO"}
+
+	stmt := &doc.Bank.Transaction.Statement
+	stmt.Currency = s.Currency
+	stmt.Account.BankID = ofxBankID
+	stmt.Account.AcctID = strconv.FormatUint(uint64(s.AccountID), 10)
+	stmt.Account.Type = "CHECKING"
+	stmt.TransactionList.Start = ofxTime(s.From)
+	stmt.TransactionList.End = ofxTime(s.To)
+	for _, line := range s.Lines {
+		trnType := "CREDIT"
+		if line.Direction == Debit {
+			trnType = "DEBIT"
+		}
+		stmt.TransactionList.Transactions = append(stmt.TransactionList.Transactions, ofxTransaction{
+			Type:   trnType,
+			Posted: ofxTime(line.Date),
+			Amount: line.Signed().String(),
+			FITID:  line.ID,
+			Name:   line.Counterparty,
+			Memo:   line.Reference,
+		})
+	}
+	stmt.LedgerBalance = ofxBalance{Amount: s.ClosingBalance.String(), AsOf: ofxTime(s.To)}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
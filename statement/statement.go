@@ -0,0 +1,113 @@
+// Package statement renders account statements for import into accounting
+// tools.
+package statement
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+
+	"bank-ledger-core/money"
+)
+
+// Direction of a statement line from the account holder's point of view.
+type Direction string
+
+const (
+	Credit Direction = "credit"
+	Debit  Direction = "debit"
+)
+
+type Statement struct {
+	AccountID      uint
+	UserID         string
+	Currency       string
+	From           time.Time
+	To             time.Time
+	OpeningBalance money.Amount
+	ClosingBalance money.Amount
+	GeneratedAt    time.Time
+	Lines          []Line
+}
+
+// Line is one booked operation. ID is stable across exports of overlapping
+// periods so importers can deduplicate on it.
+type Line struct {
+	ID           string
+	Date         time.Time
+	Direction    Direction
+	Amount       money.Amount
+	Currency     string
+	Operation    string
+	Counterparty string
+	Reference    string
+	BalanceAfter *money.Amount
+}
+
+// Signed returns the line amount with debits negative.
+func (l Line) Signed() money.Amount {
+	if l.Direction == Debit {
+		return l.Amount.Neg()
+	}
+	return l.Amount
+}
+
+// ID returns the statement's own identifier, derived from the account and
+// the period so that re-exporting the same period yields the same ID.
+func (s *Statement) ID() string {
+	return fmt.Sprintf("STMT-%d-%s-%s", s.AccountID, s.From.UTC().Format("20060102T150405"), s.To.UTC().Format("20060102T150405"))
+}
+
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	Write       func(w io.Writer, s *Statement) error
+}
+
+var (
+	CSV = Format{Name: "csv", ContentType: "text/csv; charset=utf-8", Extension: "csv", Write: WriteCSV}
+	OFX = Format{Name: "ofx", ContentType: "application/x-ofx", Extension: "ofx", Write: WriteOFX}
+	// Camt053 is an ISO 20022 bank-to-customer statement.
+	Camt053 = Format{Name: "camt053", ContentType: "application/xml", Extension: "xml", Write: WriteCamt053}
+)
+
+var formats = []Format{CSV, OFX, Camt053}
+
+// mediaTypes maps Accept header media types to formats.
+var mediaTypes = map[string]Format{
+	"text/csv":             CSV,
+	"application/csv":      CSV,
+	"application/x-ofx":    OFX,
+	"application/ofx":      OFX,
+	"application/xml":      Camt053,
+	"text/xml":             Camt053,
+	"application/camt+xml": Camt053,
+}
+
+// Negotiate picks a format from an explicit name, which wins, or else from
+// the Accept header. It falls back to CSV when the header names nothing
+// supported and reports false only for an unknown explicit name.
+func Negotiate(name, accept string) (Format, bool) {
+	if name != "" {
+		for _, format := range formats {
+			if strings.EqualFold(format.Name, name) {
+				return format, true
+			}
+		}
+		return Format{}, false
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if format, ok := mediaTypes[mediaType]; ok {
+			return format, true
+		}
+	}
+	return CSV, true
+}
@@ -0,0 +1,159 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"bank-ledger-core/money"
+)
+
+func testStatement() *Statement {
+	after1 := money.MustParse("90.00")
+	after2 := money.MustParse("115.50")
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	return &Statement{
+		AccountID:      7,
+		UserID:         "alice",
+		Currency:       "UZS",
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		OpeningBalance: money.MustParse("100.00"),
+		ClosingBalance: money.MustParse("115.50"),
+		GeneratedAt:    from.AddDate(0, 1, 1),
+		Lines: []Line{
+			{ID: "T1", Date: from.Add(time.Hour), Direction: Debit, Amount: money.MustParse("10.00"), Currency: "UZS", Operation: "transfer", Counterparty: "bob", Reference: "transfer_1", BalanceAfter: &after1},
+			{ID: "T2", Date: from.Add(2 * time.Hour), Direction: Credit, Amount: money.MustParse("25.50"), Currency: "UZS", Operation: "refund", Counterparty: "marketplace <&>", Reference: "transfer_2", BalanceAfter: &after2},
+		},
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testStatement()); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want header, opening, 2 lines, closing", len(rows))
+	}
+	if rows[1][2] != "opening_balance" || rows[1][8] != "100.00" {
+		t.Fatalf("opening row = %v", rows[1])
+	}
+	if rows[2][0] != "T1" || rows[2][4] != "-10.00" || rows[2][8] != "90.00" {
+		t.Fatalf("debit row = %v", rows[2])
+	}
+	if rows[4][2] != "closing_balance" || rows[4][8] != "115.50" {
+		t.Fatalf("closing row = %v", rows[4])
+	}
+}
+
+// Free text that a spreadsheet would evaluate is written as text.
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	s := testStatement()
+	s.Lines[0].Counterparty = "=HYPERLINK(\"http://evil\")"
+	s.Lines[0].Reference = "@SUM(A1)"
+	s.Lines[1].Counterparty = "+1"
+	s.Lines[1].Reference = "-2"
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v", err)
+	}
+	for _, cell := range []struct{ got, want string }{
+		{rows[2][6], "'=HYPERLINK(\"http://evil\")"},
+		{rows[2][7], "'@SUM(A1)"},
+		{rows[3][6], "'+1"},
+		{rows[3][7], "'-2"},
+	} {
+		if cell.got != cell.want {
+			t.Fatalf("cell = %q, want %q", cell.got, cell.want)
+		}
+	}
+	if rows[2][4] != "-10.00" {
+		t.Fatalf("amount = %q, negative amounts must stay numbers", rows[2][4])
+	}
+}
+
+func TestWriteOFX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteOFX(&buf, testStatement()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`<?OFX OFXHEADER="200" VERSION="220"`,
+		"<TRNTYPE>DEBIT</TRNTYPE>",
+		"<TRNAMT>-10.00</TRNAMT>",
+		"<ACCTID>7</ACCTID>",
+		"<FITID>T2</FITID>",
+		"<NAME>marketplace &lt;&amp;&gt;</NAME>",
+		"<BALAMT>115.50</BALAMT>",
+		"<DTSTART>20261001000000.000[0:GMT]</DTSTART>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("OFX output is missing %s", want)
+		}
+	}
+}
+
+func TestWriteCamt053(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCamt053(&buf, testStatement()); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc camtDocument
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("output is not valid XML: %v", err)
+	}
+	stmt := doc.Statement.Stmt
+	if doc.Namespace != camt053Namespace || len(stmt.Entries) != 2 || len(stmt.Balances) != 2 {
+		t.Fatalf("document = %+v", doc)
+	}
+	if stmt.Account.Other.ID != "7" {
+		t.Fatalf("account ID = %q, want 7", stmt.Account.Other.ID)
+	}
+	if stmt.Balances[0].Code != "OPBD" || stmt.Balances[0].Amount.Value != "100.00" || stmt.Balances[1].Code != "CLBD" {
+		t.Fatalf("balances = %+v", stmt.Balances)
+	}
+	debit := stmt.Entries[0]
+	if debit.Indicator != "DBIT" || debit.Amount.Value != "10.00" || debit.Details.Creditor == nil || debit.Details.Creditor.Name != "bob" {
+		t.Fatalf("debit entry = %+v", debit)
+	}
+	if stmt.Entries[1].Indicator != "CRDT" || stmt.Entries[1].Details.Debtor == nil {
+		t.Fatalf("credit entry = %+v", stmt.Entries[1])
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name, accept string
+		want         string
+		ok           bool
+	}{
+		{"ofx", "text/csv", "ofx", true},
+		{"", "application/xml;q=0.9", "camt053", true},
+		{"", "text/html, application/x-ofx", "ofx", true},
+		{"", "*/*", "csv", true},
+		{"pdf", "", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Negotiate(tt.name, tt.accept)
+		if ok != tt.ok || got.Name != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %s, %v, want %s, %v", tt.name, tt.accept, got.Name, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestRFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got := hotp(rfcSecret, uint64(Step(time.Unix(tt.unix, 0))), 8)
+		if got != tt.want {
+			t.Errorf("T=%d: got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString(rfcSecret)
+	at := time.Unix(1111111111, 0)
+
+	code, err := Code(secret, at)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	if code != "050471" {
+		t.Fatalf("Code = %s, want the last 6 digits of the RFC vector", code)
+	}
+
+	if step, ok := Validate(secret, code, at); !ok || step != Step(at) {
+		t.Fatalf("Validate at issue time = %d, %v", step, ok)
+	}
+	if _, ok := Validate(secret, code, at.Add(Period)); !ok {
+		t.Fatal("code rejected one step later")
+	}
+	if _, ok := Validate(secret, code, at.Add(2*Period)); ok {
+		t.Fatal("code accepted two steps later")
+	}
+	for _, bad := range []string{"", "05047", "0504711", "123456"} {
+		if _, ok := Validate(secret, bad, at); ok {
+			t.Fatalf("Validate accepted %q", bad)
+		}
+	}
+	if _, ok := Validate("not base32!", code, at); ok {
+		t.Fatal("Validate accepted an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret: %v", err)
+	}
+	if key, err := decodeSecret(secret); err != nil || len(key) != secretSize {
+		t.Fatalf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+
+	uri := ProvisioningURI("Bank Ledger", "alice", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/Bank%20Ledger:alice?") || !strings.Contains(uri, "secret="+secret) {
+		t.Fatalf("ProvisioningURI = %s", uri)
+	}
+}